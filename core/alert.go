@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 告警路由类型，wallets[].alerts 的每一项写作 <类型>:<目标>
+const (
+	AlertRouteTelegram = "telegram" // telegram:<chat_id>，需要配置 alert.telegram_bot_token
+	AlertRouteWebhook  = "webhook"  // webhook:<url>，以 JSON POST 告警内容
+
+	DefaultTelegramAPIURL = "https://api.telegram.org"
+	DefaultAlertTimeout   = 10 * time.Second
+)
+
+type AlertConfig struct {
+	TelegramBotToken string `yaml:"telegram_bot_token" secret:"true"`
+	TelegramAPIURL   string `yaml:"telegram_api_url"` // 默认 https://api.telegram.org
+	TimeoutSeconds   int    `yaml:"timeout_seconds"`  // 单次发送超时，默认 10
+}
+
+// Alert 表示一条交易告警，同时作为 webhook 的 JSON 内容
+type Alert struct {
+	Wallet    string    `json:"wallet"`
+	Label     string    `json:"label"`
+	Signature string    `json:"signature"`
+	Type      string    `json:"type"` // buy / sell
+	Mint      string    `json:"mint"`
+	Amount    string    `json:"amount"` // 代币数量，按精度换算后的字符串
+	Time      time.Time `json:"time"`
+}
+
+// text 返回 Telegram 消息正文
+func (a Alert) text() string {
+	return fmt.Sprintf("%s %s %s\n数量: %s\n签名: %s", a.Label, a.Type, a.Mint, a.Amount, a.Signature)
+}
+
+// ParseAlertRoute 把 <类型>:<目标> 拆分为类型与目标
+func ParseAlertRoute(route string) (kind, target string, err error) {
+	kind, target, ok := strings.Cut(route, ":")
+	if !ok || target == "" {
+		return "", "", fmt.Errorf("格式应为 telegram:<chat_id> 或 webhook:<url>: %q", route)
+	}
+	switch kind {
+	case AlertRouteTelegram:
+	case AlertRouteWebhook:
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", "", fmt.Errorf("webhook 地址必须是 http(s) URL: %q", target)
+		}
+	default:
+		return "", "", fmt.Errorf("不支持的告警类型 %q，只能是 %s 或 %s", kind, AlertRouteTelegram, AlertRouteWebhook)
+	}
+	return kind, target, nil
+}
+
+// validateAlerts 校验 alert 配置与各地址的告警路由
+func validateAlerts(add func(string, error), c AlertConfig, system SystemConfig) {
+	if c.TimeoutSeconds < 0 {
+		add("alert.timeout_seconds", fmt.Errorf("不能为负数: %d", c.TimeoutSeconds))
+	}
+	if c.TelegramAPIURL != "" {
+		if u, err := url.Parse(c.TelegramAPIURL); err != nil || u.Host == "" {
+			add("alert.telegram_api_url", fmt.Errorf("不是合法的 URL: %q", c.TelegramAPIURL))
+		}
+	}
+	for i, wallet := range system.Wallets {
+		for j, route := range wallet.Alerts {
+			key := fmt.Sprintf("system.wallets[%d].alerts[%d]", i, j)
+			kind, _, err := ParseAlertRoute(route)
+			if err != nil {
+				add(key, err)
+				continue
+			}
+			if kind == AlertRouteTelegram && c.TelegramBotToken == "" {
+				add(key, errors.New("使用 telegram 告警时 alert.telegram_bot_token 不能为空"))
+			}
+		}
+	}
+}
+
+// Alerter 把交易告警发送到地址配置的告警路由。nil *Alerter 的方法均为空操作
+type Alerter struct {
+	config AlertConfig
+	client *http.Client
+	logger *slog.Logger
+}
+
+// NewAlerter 创建告警发送器
+func NewAlerter(config AlertConfig, logger *slog.Logger) *Alerter {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultAlertTimeout
+	}
+	if config.TelegramAPIURL == "" {
+		config.TelegramAPIURL = DefaultTelegramAPIURL
+	}
+	return &Alerter{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+		logger: loggerOrDiscard(logger),
+	}
+}
+
+// Send 把告警依次发送到 routes，单个路由失败只记录日志，返回失败的路由数
+func (a *Alerter) Send(ctx context.Context, routes []string, alert Alert) int {
+	if a == nil {
+		return 0
+	}
+	failed := 0
+	for _, route := range routes {
+		if err := a.send(ctx, route, alert); err != nil {
+			failed++
+			AlertsSent.WithLabelValues(alertKind(route), AlertFailure).Inc()
+			a.logger.Warn("发送告警失败", "route", redactAlertRoute(route), LogKeySignature, alert.Signature, LogKeyError, err)
+			continue
+		}
+		AlertsSent.WithLabelValues(alertKind(route), AlertSuccess).Inc()
+	}
+	return failed
+}
+
+// send 把告警发送到单个路由
+func (a *Alerter) send(ctx context.Context, route string, alert Alert) error {
+	kind, target, err := ParseAlertRoute(route)
+	if err != nil {
+		return err
+	}
+	var endpoint string
+	var body interface{}
+	switch kind {
+	case AlertRouteTelegram:
+		if a.config.TelegramBotToken == "" {
+			return errors.New("未配置 alert.telegram_bot_token")
+		}
+		endpoint = strings.TrimRight(a.config.TelegramAPIURL, "/") + "/bot" + a.config.TelegramBotToken + "/sendMessage"
+		body = map[string]string{"chat_id": target, "text": alert.text()}
+	case AlertRouteWebhook:
+		endpoint, body = target, alert
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := a.client.Do(request)
+	if err != nil {
+		// 请求错误中包含完整 URL，Telegram 的 URL 带有 bot token，只返回原因
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// alertKind 返回路由类型，用作指标标签
+func alertKind(route string) string {
+	kind, _, _ := strings.Cut(route, ":")
+	return kind
+}
+
+// redactAlertRoute 隐藏 webhook 地址中的查询参数与用户信息
+func redactAlertRoute(route string) string {
+	kind, target, ok := strings.Cut(route, ":")
+	if !ok || kind != AlertRouteWebhook {
+		return route
+	}
+	return kind + ":" + redactURL(target)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAlerterRoutes 校验告警按路由发送到 webhook 与 Telegram，失败的路由不影响其它路由
+func TestAlerterRoutes(t *testing.T) {
+	var webhook Alert
+	var telegram map[string]string
+	var telegramPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/hook":
+			json.NewDecoder(r.Body).Decode(&webhook)
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			telegramPath = r.URL.Path
+			json.NewDecoder(r.Body).Decode(&telegram)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	alerter := NewAlerter(AlertConfig{TelegramBotToken: "token", TelegramAPIURL: server.URL}, nil)
+	alert := Alert{Wallet: "wallet", Label: "whale-1", Signature: "sig", Type: "buy", Mint: "mint", Amount: "12.50", Time: time.Unix(1_700_000_000, 0)}
+	routes := []string{"webhook:" + server.URL + "/hook", "telegram:-100123", "webhook:" + server.URL + "/missing"}
+	if failed := alerter.Send(context.Background(), routes, alert); failed != 1 {
+		t.Errorf("失败 %d 个路由，期望 1 个", failed)
+	}
+	if webhook.Signature != "sig" || webhook.Label != "whale-1" || webhook.Amount != "12.50" {
+		t.Errorf("webhook 收到 %+v", webhook)
+	}
+	if telegramPath != "/bottoken/sendMessage" || telegram["chat_id"] != "-100123" || !strings.Contains(telegram["text"], "whale-1 buy mint") {
+		t.Errorf("Telegram 收到 %s %v", telegramPath, telegram)
+	}
+
+	// nil Alerter 为空操作
+	var disabled *Alerter
+	if failed := disabled.Send(context.Background(), routes, alert); failed != 0 {
+		t.Errorf("nil Alerter 返回 %d", failed)
+	}
+}
+
+func TestValidateAlerts(t *testing.T) {
+	system := SystemConfig{Wallets: []WalletConfig{{
+		Address: "HXvUJoQuDvpZ4oNNFF5itafDfwMUCAFijLnjCwKVJ5rg",
+		Alerts:  []string{"telegram:123", "webhook:ftp://example.com", "email:a@b.c", "webhook:https://example.com/hook"},
+	}}}
+	var keys []string
+	validateAlerts(func(key string, err error) { keys = append(keys, key) }, AlertConfig{}, system)
+	want := []string{"system.wallets[0].alerts[0]", "system.wallets[0].alerts[1]", "system.wallets[0].alerts[2]"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("校验错误为 %v，期望 %v", keys, want)
+	}
+
+	keys = nil
+	validateAlerts(func(key string, err error) { keys = append(keys, key) }, AlertConfig{TelegramBotToken: "token"}, SystemConfig{Wallets: system.Wallets[:1]})
+	if len(keys) != 2 {
+		t.Errorf("配置 bot token 后校验错误为 %v，期望只剩 webhook 与 email 两项", keys)
+	}
+}
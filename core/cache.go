@@ -0,0 +1,271 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type CacheKind string
+
+const (
+	CacheDecimals    CacheKind = "decimals"    // 代币精度，创建后不可变
+	CacheMetadata    CacheKind = "metadata"    // 代币元数据，极少变化
+	CacheTransaction CacheKind = "transaction" // 已确认交易，finalized 交易永久缓存
+	CachePool        CacheKind = "pool"        // 池子账户布局与交易对对应的池子
+
+	DefaultCacheSize   = 10_000
+	cacheRedisPrefix   = "cache:"
+	cacheStatsInterval = time.Minute
+)
+
+// DefaultCacheTTL 各类数据的默认缓存时间，0 表示永久缓存
+var DefaultCacheTTL = map[CacheKind]time.Duration{
+	CacheDecimals:    0,
+	CacheMetadata:    24 * time.Hour,
+	CacheTransaction: 10 * time.Minute,
+	CachePool:        5 * time.Minute,
+}
+
+type CacheConfig struct {
+	Size       int            `yaml:"size"`        // 进程内 LRU 的条目数，默认 10000
+	TTLSeconds map[string]int `yaml:"ttl_seconds"` // 按数据类型覆盖缓存时间，0 表示永久缓存
+}
+
+// validateCache 校验 cache 配置
+func validateCache(add func(string, error), c CacheConfig) {
+	if c.Size < 0 {
+		add("cache.size", fmt.Errorf("不能为负数: %d", c.Size))
+	}
+	for kind, seconds := range c.TTLSeconds {
+		if _, ok := DefaultCacheTTL[CacheKind(kind)]; !ok {
+			add("cache.ttl_seconds."+kind, fmt.Errorf("未知的数据类型，只能是 %s、%s、%s 或 %s", CacheDecimals, CacheMetadata, CacheTransaction, CachePool))
+		}
+		if seconds < 0 {
+			add("cache.ttl_seconds."+kind, fmt.Errorf("不能为负数: %d", seconds))
+		}
+	}
+}
+
+// CacheStats 表示某类数据的缓存命中情况
+type CacheStats struct {
+	LocalHits uint64
+	RedisHits uint64
+	Misses    uint64
+}
+
+type cacheCounters struct {
+	localHits atomic.Uint64
+	redisHits atomic.Uint64
+	misses    atomic.Uint64
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	expires time.Time // 零值表示永不过期
+}
+
+// Cache 两级只读数据缓存: 进程内 LRU 在前，Redis 在后，值以 JSON 保存。
+// Redis 不可用时只使用进程内缓存；nil *Cache 的所有方法均视为未命中
+type Cache struct {
+	redis  *RedisClient
+	logger *slog.Logger
+	size   int
+	ttl    map[CacheKind]time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+
+	counters map[CacheKind]*cacheCounters
+}
+
+// NewCache 创建缓存
+func NewCache(cfg CacheConfig, client *RedisClient, logger *slog.Logger) *Cache {
+	size := cfg.Size
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+	c := &Cache{
+		redis:    client,
+		logger:   loggerOrDiscard(logger),
+		size:     size,
+		ttl:      make(map[CacheKind]time.Duration, len(DefaultCacheTTL)),
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		counters: make(map[CacheKind]*cacheCounters, len(DefaultCacheTTL)),
+	}
+	for kind, ttl := range DefaultCacheTTL {
+		c.ttl[kind] = ttl
+		c.counters[kind] = &cacheCounters{}
+	}
+	for kind, seconds := range cfg.TTLSeconds {
+		c.ttl[CacheKind(kind)] = time.Duration(seconds) * time.Second
+	}
+	return c
+}
+
+// Get 依次查询进程内缓存与 Redis，命中时把值解析到 out
+func (c *Cache) Get(ctx context.Context, kind CacheKind, key string, out interface{}) bool {
+	if c == nil {
+		return false
+	}
+	counters := c.counter(kind)
+	fullKey := cacheRedisPrefix + string(kind) + ":" + key
+
+	if data, ok := c.getLocal(fullKey); ok && json.Unmarshal(data, out) == nil {
+		counters.localHits.Add(1)
+		CacheLookups.WithLabelValues(string(kind), CacheLocalHit).Inc()
+		return true
+	}
+
+	if c.redis.Available() {
+		data, err := c.redis.Get(ctx, fullKey).Bytes()
+		if err == nil && json.Unmarshal(data, out) == nil {
+			counters.redisHits.Add(1)
+			CacheLookups.WithLabelValues(string(kind), CacheRedisHit).Inc()
+			var expires time.Time
+			if ttl, err := c.redis.TTL(ctx, fullKey).Result(); err == nil && ttl > 0 {
+				expires = time.Now().Add(ttl)
+			}
+			c.setLocal(fullKey, data, expires)
+			return true
+		}
+		if err != nil && err != redis.Nil {
+			c.logger.Warn("读取 Redis 缓存失败", "key", fullKey, LogKeyError, err)
+		}
+	}
+
+	counters.misses.Add(1)
+	CacheLookups.WithLabelValues(string(kind), CacheMiss).Inc()
+	return false
+}
+
+// Set 按数据类型的默认缓存时间写入缓存
+func (c *Cache) Set(ctx context.Context, kind CacheKind, key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.SetTTL(ctx, kind, key, value, c.ttl[kind])
+}
+
+// SetTTL 以指定的缓存时间写入缓存，ttl 为 0 表示永久缓存
+func (c *Cache) SetTTL(ctx context.Context, kind CacheKind, key string, value interface{}, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.logger.Warn("缓存序列化失败", "kind", kind, "key", key, LogKeyError, err)
+		return
+	}
+	fullKey := cacheRedisPrefix + string(kind) + ":" + key
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.setLocal(fullKey, data, expires)
+
+	if c.redis.Available() {
+		if err := c.redis.Set(ctx, fullKey, data, ttl).Err(); err != nil {
+			c.logger.Warn("写入 Redis 缓存失败", "key", fullKey, LogKeyError, err)
+		}
+	}
+}
+
+// Stats 返回各类数据的缓存命中计数
+func (c *Cache) Stats() map[CacheKind]CacheStats {
+	stats := make(map[CacheKind]CacheStats)
+	if c == nil {
+		return stats
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for kind, counters := range c.counters {
+		stats[kind] = CacheStats{
+			LocalHits: counters.localHits.Load(),
+			RedisHits: counters.redisHits.Load(),
+			Misses:    counters.misses.Load(),
+		}
+	}
+	return stats
+}
+
+// ReportStats 定期把缓存命中计数写入日志，直到 ctx 结束
+func (c *Cache) ReportStats(ctx context.Context) {
+	ticker := time.NewTicker(cacheStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			stats := c.Stats()
+			kinds := make([]string, 0, len(stats))
+			for kind := range stats {
+				kinds = append(kinds, string(kind))
+			}
+			sort.Strings(kinds)
+			for _, kind := range kinds {
+				s := stats[CacheKind(kind)]
+				c.logger.Info("缓存命中统计", "kind", kind, "local_hits", s.LocalHits, "redis_hits", s.RedisHits, "misses", s.Misses)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// counter 返回数据类型对应的计数器，未知类型在首次使用时创建
+func (c *Cache) counter(kind CacheKind) *cacheCounters {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counters, ok := c.counters[kind]
+	if !ok {
+		counters = &cacheCounters{}
+		c.counters[kind] = counters
+	}
+	return counters
+}
+
+func (c *Cache) getLocal(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.data, true
+}
+
+func (c *Cache) setLocal(key string, data []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value = &cacheEntry{key: key, data: data, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, data: data, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
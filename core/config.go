@@ -1,6 +1,143 @@
 package core
 
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+const DefaultConfigPath = "config.yml"
+
 type Config struct {
-	Redis        RedisConfig  `yaml:"redis"`
-	SystemConfig SystemConfig `yaml:"system"`
+	Redis        RedisConfig   `yaml:"redis"`
+	RPC          RPCConfig     `yaml:"rpc"`
+	Cache        CacheConfig   `yaml:"cache"`
+	Queue        QueueConfig   `yaml:"queue"`
+	Metrics      MetricsConfig `yaml:"metrics"`
+	Health       HealthConfig  `yaml:"health"`
+	Log          LogConfig     `yaml:"log"`
+	Store        StoreConfig   `yaml:"store"`
+	Record       RecordConfig  `yaml:"record"`
+	Alert        AlertConfig   `yaml:"alert"`
+	SystemConfig SystemConfig  `yaml:"system"`
+	FollowConfig FollowConfig  `yaml:"follow"`
+}
+
+// ConfigError 表示某个配置项的错误
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfig 读取配置文件并依次应用环境变量与命令行参数覆盖，最后统一校验。
+// 未知字段与非法取值都会返回错误。flags 为 nil 时只应用环境变量。
+func LoadConfig(path string, flags *pflag.FlagSet) (Config, error) {
+	var config Config
+	if path == "" {
+		path = DefaultConfigPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	if err := applyOverrides(&config, flags); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("配置文件 %s 校验失败:\n%w", path, err)
+	}
+	return config, nil
+}
+
+// Validate 校验全部配置项，返回的错误包含每个非法配置项的完整键名
+func (c Config) Validate() error {
+	var errs []error
+	add := func(key string, err error) {
+		errs = append(errs, &ConfigError{Key: key, Err: err})
+	}
+
+	validateRedis(add, c.Redis)
+	validateRPC(add, c.RPC)
+	validateCache(add, c.Cache)
+	validateQueue(add, c.Queue)
+	validateMetrics(add, c.Metrics)
+	validateHealth(add, c.Health)
+	validateLog(add, c.Log)
+	validateStore(add, c.Store)
+
+	if c.SystemConfig.SelfAddress == "" && c.SystemConfig.MonitorAddress == "" && len(c.SystemConfig.Wallets) == 0 {
+		add("system.wallets", errors.New("wallets、self_address 与 monitor_address 至少配置一个"))
+	}
+	validateAddress(add, "system.self_address", c.SystemConfig.SelfAddress)
+	validateAddress(add, "system.monitor_address", c.SystemConfig.MonitorAddress)
+	validateWallets(add, c.SystemConfig)
+	validateAlerts(add, c.Alert, c.SystemConfig)
+	if c.SystemConfig.PrivateKey != "" {
+		if _, err := solana.PrivateKeyFromBase58(c.SystemConfig.PrivateKey); err != nil {
+			add("system.private_key", errors.New("不是合法的 base58 私钥"))
+		}
+	}
+
+	if c.FollowConfig.MaxSlippageBps > 10_000 {
+		add("follow.max_slippage_bps", fmt.Errorf("不能超过 10000: %d", c.FollowConfig.MaxSlippageBps))
+	}
+	if c.FollowConfig.MaxPriceDeviationBps > 10_000 {
+		add("follow.max_price_deviation_bps", fmt.Errorf("不能超过 10000: %d", c.FollowConfig.MaxPriceDeviationBps))
+	}
+
+	switch c.FollowConfig.Router {
+	case "", SwapRouterJupiter, SwapRouterRaydium:
+	default:
+		add("follow.router", fmt.Errorf("只能是 %s 或 %s: %q", SwapRouterJupiter, SwapRouterRaydium, c.FollowConfig.Router))
+	}
+
+	exit := c.FollowConfig.Exit
+	if exit.StopLossPct < 0 || exit.StopLossPct >= 100 {
+		add("follow.exit.stop_loss_pct", fmt.Errorf("取值范围为 [0, 100): %v", exit.StopLossPct))
+	}
+	if exit.TrailingStopPct < 0 || exit.TrailingStopPct >= 100 {
+		add("follow.exit.trailing_stop_pct", fmt.Errorf("取值范围为 [0, 100): %v", exit.TrailingStopPct))
+	}
+	if exit.IntervalSeconds < 0 {
+		add("follow.exit.interval_seconds", fmt.Errorf("不能为负数: %d", exit.IntervalSeconds))
+	}
+	for i, level := range exit.TakeProfit {
+		if level.Multiple <= 1 {
+			add(fmt.Sprintf("follow.exit.take_profit[%d].multiple", i), fmt.Errorf("必须大于 1: %v", level.Multiple))
+		}
+		if level.SellPct <= 0 || level.SellPct > 100 {
+			add(fmt.Sprintf("follow.exit.take_profit[%d].sell_pct", i), fmt.Errorf("取值范围为 (0, 100]: %v", level.SellPct))
+		}
+	}
+	if exit.Enabled && c.SystemConfig.PrivateKey == "" {
+		add("system.private_key", errors.New("启用 follow.exit 时不能为空"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateAddress 校验非空地址是否为合法的 base58 公钥
+func validateAddress(add func(string, error), key, address string) {
+	if address == "" {
+		return
+	}
+	if _, err := solana.PublicKeyFromBase58(address); err != nil {
+		add(key, fmt.Errorf("不是合法的 base58 地址 %q: %v", address, err))
+	}
 }
@@ -0,0 +1,27 @@
+package core
+
+const (
+	SwapRouterJupiter = "jupiter" // 通过 Jupiter 兼容 API 报价并构造兑换
+	SwapRouterRaydium = "raydium" // 直接构造 Raydium AMM v4 兑换指令
+)
+
+type FollowConfig struct {
+	MaxSlippageBps       uint16     `yaml:"max_slippage_bps"`        // 相对最新报价的最大滑点 (基点)
+	MaxPriceDeviationBps uint16     `yaml:"max_price_deviation_bps"` // 跟单地址成交价与当前价格的最大偏离 (基点)
+	JupiterAPIURL        string     `yaml:"jupiter_api_url"`         // Jupiter 兼容的报价与兑换指令 API 地址
+	Router               string     `yaml:"router"`                  // jupiter / raydium，默认 jupiter
+	Exit                 ExitConfig `yaml:"exit"`
+}
+
+type ExitConfig struct {
+	Enabled         bool              `yaml:"enabled"`
+	StopLossPct     float64           `yaml:"stop_loss_pct"`     // 相对成本价下跌百分比，触发后全部卖出
+	TrailingStopPct float64           `yaml:"trailing_stop_pct"` // 相对持仓期间最高价回撤百分比，触发后全部卖出
+	TakeProfit      []TakeProfitLevel `yaml:"take_profit"`       // 止盈阶梯，按倍数从低到高执行
+	IntervalSeconds int               `yaml:"interval_seconds"`  // 持仓检查间隔
+}
+
+type TakeProfitLevel struct {
+	Multiple float64 `yaml:"multiple"` // 相对成本价的倍数
+	SellPct  float64 `yaml:"sell_pct"` // 卖出初始持仓的百分比，最后一档卖出剩余全部
+}
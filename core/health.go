@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxMessageAge = 600 // 秒
+	DefaultMaxPongAge    = 30  // 秒
+	DefaultHealthListen  = "127.0.0.1:8081"
+
+	readinessTimeout     = 5 * time.Second
+	statusServerShutdown = 5 * time.Second
+)
+
+type HealthConfig struct {
+	Listen               string `yaml:"listen"`                  // /healthz、/readyz 与 /pnl（配置 store 时）的监听地址，默认 127.0.0.1:8081，与 metrics.listen 相同时共用同一服务
+	MaxMessageAgeSeconds int    `yaml:"max_message_age_seconds"` // 超过该时间未收到任何 WebSocket 消息时未就绪，默认 600
+	MaxPongAgeSeconds    int    `yaml:"max_pong_age_seconds"`    // 超过该时间未收到 Pong 时未就绪，默认 30
+}
+
+// ListenAddr 返回健康检查服务的监听地址
+func (c HealthConfig) ListenAddr() string {
+	if c.Listen == "" {
+		return DefaultHealthListen
+	}
+	return c.Listen
+}
+
+// MaxMessageAge 返回允许的最长无消息时间
+func (c HealthConfig) MaxMessageAge() time.Duration {
+	if c.MaxMessageAgeSeconds == 0 {
+		return DefaultMaxMessageAge * time.Second
+	}
+	return time.Duration(c.MaxMessageAgeSeconds) * time.Second
+}
+
+// MaxPongAge 返回允许的最长无 Pong 时间
+func (c HealthConfig) MaxPongAge() time.Duration {
+	if c.MaxPongAgeSeconds == 0 {
+		return DefaultMaxPongAge * time.Second
+	}
+	return time.Duration(c.MaxPongAgeSeconds) * time.Second
+}
+
+// validateHealth 校验 health 配置
+func validateHealth(add func(string, error), c HealthConfig) {
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			add("health.listen", fmt.Errorf("不是合法的监听地址 host:port: %v", err))
+		}
+	}
+	if c.MaxMessageAgeSeconds < 0 {
+		add("health.max_message_age_seconds", fmt.Errorf("不能为负数: %d", c.MaxMessageAgeSeconds))
+	}
+	if c.MaxPongAgeSeconds < 0 {
+		add("health.max_pong_age_seconds", fmt.Errorf("不能为负数: %d", c.MaxPongAgeSeconds))
+	}
+}
+
+// CheckResult 表示一项就绪检查的结果
+type CheckResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReadinessFunc 返回全部就绪检查的结果
+type ReadinessFunc func(ctx context.Context) []CheckResult
+
+// ReadyCheck 检查 Redis 连接。未要求 Redis 必须可用时，断开只记为降级，不影响就绪
+func (r *RedisClient) ReadyCheck(required bool) CheckResult {
+	result := CheckResult{Name: "redis", OK: true}
+	if !r.Available() {
+		result.OK = !required
+		result.Detail = "Redis 不可用"
+		if !required {
+			result.Detail += "，降级运行"
+		}
+	}
+	return result
+}
+
+// ReadyCheck 检查节点池，至少有一个节点健康时就绪
+func (p *RPCPool) ReadyCheck() CheckResult {
+	var unhealthy []string
+	for _, endpoint := range p.endpoints {
+		if !endpoint.healthy.Load() {
+			unhealthy = append(unhealthy, endpoint.config.Name)
+		}
+	}
+	result := CheckResult{Name: "rpc", OK: len(unhealthy) < len(p.endpoints)}
+	if len(unhealthy) > 0 {
+		result.Detail = "不健康的节点: " + strings.Join(unhealthy, ", ")
+	}
+	return result
+}
+
+// HealthHandlers 返回 /healthz 与 /readyz。/healthz 只表示进程存活；/readyz 在任一检查失败时返回 503
+func HealthHandlers(ready ReadinessFunc) map[string]http.Handler {
+	return map[string]http.Handler{
+		"/healthz": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok\n"))
+		}),
+		"/readyz": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			checkCtx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+			checks := ready(checkCtx)
+
+			status := http.StatusOK
+			for _, check := range checks {
+				if !check.OK {
+					status = http.StatusServiceUnavailable
+					break
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(struct {
+				Ready  bool          `json:"ready"`
+				Checks []CheckResult `json:"checks"`
+			}{status == http.StatusOK, checks})
+		}),
+	}
+}
+
+// ServeStatus 在 listen 上提供 handlers 中的接口，直到 ctx 结束
+func ServeStatus(ctx context.Context, listen string, handlers map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	for pattern, handler := range handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), statusServerShutdown)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
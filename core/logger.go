@@ -0,0 +1,149 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	DefaultLogDir        = "logs"
+	DefaultLogFile       = "monitor.log"
+	DefaultLogMaxSizeMB  = 100
+	DefaultLogMaxAgeDays = 7
+	DefaultLogMaxBackups = 10
+)
+
+// 日志字段名，各组件统一使用
+const (
+	LogKeyComponent = "component"
+	LogKeyAddress   = "address"
+	LogKeyLabel     = "label"
+	LogKeySignature = "signature"
+	LogKeySlot      = "slot"
+	LogKeyMint      = "mint"
+	LogKeyError     = "err"
+
+	// 跟单发出的交易，与被跟单交易的 signature、slot 区分
+	LogKeyCopySignature = "copy_signature"
+	LogKeyCopySlot      = "copy_slot"
+)
+
+type LogConfig struct {
+	Level      string `yaml:"level"`        // debug / info / warn / error，默认 info；debug 级别输出原始消息与交易 JSON
+	Format     string `yaml:"format"`       // text / json，默认 text
+	Dir        string `yaml:"dir"`          // 日志目录，默认 logs
+	File       string `yaml:"file"`         // 日志文件名，默认 monitor.log
+	MaxSizeMB  int    `yaml:"max_size_mb"`  // 单个文件最大大小，超过后轮转，默认 100
+	MaxAgeDays int    `yaml:"max_age_days"` // 轮转文件保留天数，默认 7
+	MaxBackups int    `yaml:"max_backups"`  // 轮转文件保留个数，默认 10
+	Compress   bool   `yaml:"compress"`     // 是否 gzip 压缩轮转文件
+	Stderr     bool   `yaml:"stderr"`       // 是否同时输出到标准错误
+}
+
+// validateLog 校验 log 配置
+func validateLog(add func(string, error), c LogConfig) {
+	if _, err := parseLogLevel(c.Level); err != nil {
+		add("log.level", err)
+	}
+	switch c.Format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		add("log.format", fmt.Errorf("只能是 %s 或 %s: %q", LogFormatText, LogFormatJSON, c.Format))
+	}
+	if c.MaxSizeMB < 0 {
+		add("log.max_size_mb", fmt.Errorf("不能为负数: %d", c.MaxSizeMB))
+	}
+	if c.MaxAgeDays < 0 {
+		add("log.max_age_days", fmt.Errorf("不能为负数: %d", c.MaxAgeDays))
+	}
+	if c.MaxBackups < 0 {
+		add("log.max_backups", fmt.Errorf("不能为负数: %d", c.MaxBackups))
+	}
+}
+
+// NewLogger 按配置创建写入轮转文件的日志记录器，返回的 io.Closer 用于退出时关闭文件
+func NewLogger(cfg LogConfig) (*slog.Logger, io.Closer, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = DefaultLogDir
+	}
+	file := cfg.File
+	if file == "" {
+		file = DefaultLogFile
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("创建日志目录 %s 失败: %w", dir, err)
+	}
+
+	rotator := &lumberjack.Logger{
+		Filename:   filepath.Join(dir, file),
+		MaxSize:    orDefault(cfg.MaxSizeMB, DefaultLogMaxSizeMB),
+		MaxAge:     orDefault(cfg.MaxAgeDays, DefaultLogMaxAgeDays),
+		MaxBackups: orDefault(cfg.MaxBackups, DefaultLogMaxBackups),
+		Compress:   cfg.Compress,
+	}
+	var w io.Writer = rotator
+	if cfg.Stderr {
+		w = io.MultiWriter(rotator, os.Stderr)
+	}
+
+	level, err := parseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.New(newLogHandler(w, cfg.Format, level)), rotator, nil
+}
+
+// NewConsoleLogger 创建输出到标准错误的文本日志记录器，供子命令使用
+func NewConsoleLogger(level slog.Level) *slog.Logger {
+	return slog.New(newLogHandler(os.Stderr, LogFormatText, level))
+}
+
+// discardLogger 丢弃全部日志，供未传入日志记录器的组件使用
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// loggerOrDiscard 返回 logger，为 nil 时返回丢弃全部日志的记录器
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("只能是 debug、info、warn 或 error: %q", level)
+}
+
+func orDefault(value, def int) int {
+	if value == 0 {
+		return def
+	}
+	return value
+}
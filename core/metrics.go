@@ -0,0 +1,155 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "monitor"
+
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // /metrics 的监听地址，同时提供 /healthz、/readyz，例如 127.0.0.1:9090，为空时不启动
+}
+
+// validateMetrics 校验 metrics 配置
+func validateMetrics(add func(string, error), c MetricsConfig) {
+	if c.Listen == "" {
+		return
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		add("metrics.listen", fmt.Errorf("不是合法的监听地址 host:port: %v", err))
+	}
+}
+
+// 交易解析结果
+const (
+	ParseSuccess = "success" // 解析出代币买卖
+	ParseSkipped = "skipped" // 不是代币交易，已过滤
+	ParseFailure = "failure" // 获取或解析失败
+)
+
+// 缓存查询结果
+const (
+	CacheLocalHit = "local_hit" // 进程内 LRU 命中
+	CacheRedisHit = "redis_hit" // Redis 命中
+	CacheMiss     = "miss"      // 未命中
+)
+
+// 告警发送结果
+const (
+	AlertSuccess = "success"
+	AlertFailure = "failure"
+)
+
+// 跟单交易类型
+const (
+	TradeKindFollow = "follow" // 跟随被跟单地址开仓
+	TradeKindExit   = "exit"   // 止盈止损卖出
+)
+
+var (
+	NotificationsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "notifications_received_total",
+		Help:      "按监控地址统计收到的交易通知数",
+	}, []string{"address"})
+
+	TransactionsParsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "transactions_parsed_total",
+		Help:      "按结果统计交易解析次数 (success / skipped / failure)",
+	}, []string{"result"})
+
+	TransactionFetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "transaction_fetch_duration_seconds",
+		Help:      "获取交易详情的总耗时，包含交易未找到与限流时的重试",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 16, 32, 60},
+	}, []string{"result"})
+
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_errors_total",
+		Help:      "按方法与错误码统计 RPC 调用失败次数，code 为 JSON-RPC 错误码、HTTP 状态码或 network",
+	}, []string{"method", "code"})
+
+	WSReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "ws_reconnects_total",
+		Help:      "WebSocket 断线后的重连次数",
+	})
+
+	WSConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "ws_connected",
+		Help:      "WebSocket 当前是否已连接",
+	})
+
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_lookups_total",
+		Help:      "按数据类型与结果统计缓存查询次数 (local_hit / redis_hit / miss)",
+	}, []string{"kind", "result"})
+
+	CopyTradesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "copy_trades_sent_total",
+		Help:      "按类型统计提交发送的跟单交易数 (follow / exit)",
+	}, []string{"kind"})
+
+	CopyTradesLanded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "copy_trades_landed_total",
+		Help:      "按类型统计已确认且执行成功的跟单交易数 (follow / exit)",
+	}, []string{"kind"})
+
+	AlertsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "alerts_sent_total",
+		Help:      "按路由类型与结果统计发送的交易告警数 (telegram / webhook, success / failure)",
+	}, []string{"route", "result"})
+)
+
+// RegisterWorkerPool 注册工作池的队列深度、容量、处理中与丢弃任务数指标，name 作为 queue 标签
+func RegisterWorkerPool(name string, p *WorkerPool) error {
+	labels := prometheus.Labels{"queue": name}
+	collectors := []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace, Name: "queue_depth", Help: "队列中等待处理的任务数", ConstLabels: labels,
+		}, func() float64 { return float64(p.Stats().Queued) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace, Name: "queue_capacity", Help: "队列容量", ConstLabels: labels,
+		}, func() float64 { return float64(p.Stats().Capacity) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace, Name: "queue_busy_workers", Help: "正在执行任务的 worker 数", ConstLabels: labels,
+		}, func() float64 { return float64(p.Stats().Busy) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace, Name: "queue_dropped_total", Help: "因队列已满被丢弃的任务数", ConstLabels: labels,
+		}, func() float64 { return float64(p.Stats().Dropped) }),
+	}
+	for _, collector := range collectors {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rpcErrorCode 返回用于 rpc_errors_total 的错误码标签
+func rpcErrorCode(err error) string {
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		return strconv.Itoa(rpcErr.Code)
+	}
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return strconv.Itoa(httpErr.Code)
+	}
+	return "network"
+}
@@ -0,0 +1,22 @@
+CREATE TABLE trades (
+    signature      TEXT             NOT NULL,
+    leg            INTEGER          NOT NULL,
+    slot           BIGINT           NOT NULL,
+    block_time     BIGINT           NOT NULL, -- Unix 秒，0 表示未知
+    wallet         TEXT             NOT NULL,
+    mint           TEXT             NOT NULL,
+    direction      TEXT             NOT NULL, -- buy / sell
+    token_amount   BIGINT           NOT NULL, -- 最小单位
+    token_decimals SMALLINT         NOT NULL,
+    quote_mint     TEXT             NOT NULL,
+    quote_amount   BIGINT           NOT NULL, -- 最小单位
+    quote_decimals SMALLINT         NOT NULL,
+    price          DOUBLE PRECISION NOT NULL, -- 每个代币对应的计价代币数量
+    dex            TEXT             NOT NULL,
+    fee            BIGINT           NOT NULL, -- lamports
+    updated_at     BIGINT           NOT NULL,
+    PRIMARY KEY (signature, leg)
+);
+
+CREATE INDEX trades_wallet_time ON trades (wallet, block_time);
+CREATE INDEX trades_mint_time ON trades (mint, block_time);
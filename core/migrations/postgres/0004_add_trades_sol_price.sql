@@ -0,0 +1,2 @@
+-- 记录交易时的 SOL/USD 价格，用于按交易时价格折算 USD 盈亏，0 表示未知
+ALTER TABLE trades ADD COLUMN sol_price_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
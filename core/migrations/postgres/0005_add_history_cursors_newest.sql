@@ -0,0 +1,6 @@
+-- 回填的最新签名：newest_signature 为已完整回填范围内的最新签名，之后的运行只回填它之后的交易；
+-- pending_signature 为进行中的运行开始时的最新签名，运行完成后成为 newest_signature
+ALTER TABLE history_cursors ADD COLUMN newest_signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE history_cursors ADD COLUMN newest_slot BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE history_cursors ADD COLUMN pending_signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE history_cursors ADD COLUMN pending_slot BIGINT NOT NULL DEFAULT 0;
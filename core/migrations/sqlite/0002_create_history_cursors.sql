@@ -0,0 +1,8 @@
+-- history 子命令的回填进度，每个地址一行
+CREATE TABLE history_cursors (
+    wallet           TEXT    NOT NULL PRIMARY KEY,
+    before_signature TEXT    NOT NULL, -- 已处理的最早签名，下次从它之前继续
+    slot             INTEGER NOT NULL, -- before_signature 所在 slot
+    processed        INTEGER NOT NULL, -- 累计处理的签名数
+    updated_at       INTEGER NOT NULL
+);
@@ -0,0 +1,9 @@
+-- history 子命令获取或解析失败的交易，下次回填时先重试，成功后删除
+CREATE TABLE history_failures (
+    wallet     TEXT    NOT NULL,
+    signature  TEXT    NOT NULL,
+    error      TEXT    NOT NULL, -- 最近一次失败的原因
+    attempts   INTEGER NOT NULL, -- 累计失败次数
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (wallet, signature)
+);
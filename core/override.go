@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// 配置项覆盖优先级: 命令行参数 > 环境变量 > 配置文件。
+// 每个配置项都有对应的环境变量与命令行参数，例如 redis.password 对应
+// 环境变量 MONITOR_REDIS_PASSWORD 与参数 --redis.password。
+// 列表等复合类型的取值使用 YAML/JSON 写法，例如
+// MONITOR_FOLLOW_EXIT_TAKE_PROFIT='[{multiple: 2, sell_pct: 50}]'。
+
+const (
+	EnvPrefix     = "MONITOR_"
+	redactedValue = "******"
+)
+
+// configField 表示一个可覆盖的配置项
+type configField struct {
+	Key    string
+	Value  reflect.Value
+	Secret bool
+}
+
+// EnvName 返回配置项对应的环境变量名
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// RegisterFlags 为每个配置项注册同名命令行参数
+func RegisterFlags(flags *pflag.FlagSet) {
+	var config Config
+	for _, field := range configFields(&config) {
+		flags.String(field.Key, "", fmt.Sprintf("覆盖配置项 %s (环境变量 %s)", field.Key, EnvName(field.Key)))
+	}
+}
+
+// applyOverrides 依次应用环境变量与命令行参数
+func applyOverrides(config *Config, flags *pflag.FlagSet) error {
+	for _, field := range configFields(config) {
+		if value, ok := os.LookupEnv(EnvName(field.Key)); ok {
+			if err := setField(field.Value, value); err != nil {
+				return &ConfigError{Key: field.Key, Err: fmt.Errorf("环境变量 %s 取值无效: %w", EnvName(field.Key), err)}
+			}
+		}
+		if flags == nil {
+			continue
+		}
+		if flag := flags.Lookup(field.Key); flag != nil && flag.Changed {
+			if err := setField(field.Value, flag.Value.String()); err != nil {
+				return &ConfigError{Key: field.Key, Err: fmt.Errorf("参数 --%s 取值无效: %w", field.Key, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// Redacted 返回隐藏敏感字段后的配置副本
+func (c Config) Redacted() Config {
+	redacted := c
+	for _, field := range configFields(&redacted) {
+		if field.Secret && !field.Value.IsZero() {
+			field.Value.SetString(redactedValue)
+		}
+	}
+	// 节点请求头与 URL 中的查询参数、用户信息通常携带 API Key，全部隐藏
+	redacted.FollowConfig.JupiterAPIURL = redactURL(c.FollowConfig.JupiterAPIURL)
+	redacted.RPC.Endpoints = make([]EndpointConfig, len(c.RPC.Endpoints))
+	for i, endpoint := range c.RPC.Endpoints {
+		endpoint.URL = redactURL(endpoint.URL)
+		endpoint.WSURL = redactURL(endpoint.WSURL)
+		if len(endpoint.Headers) > 0 {
+			headers := make(map[string]string, len(endpoint.Headers))
+			for name := range endpoint.Headers {
+				headers[name] = redactedValue
+			}
+			endpoint.Headers = headers
+		}
+		redacted.RPC.Endpoints[i] = endpoint
+	}
+	// webhook 告警地址同样可能携带 token
+	redacted.SystemConfig.Wallets = make([]WalletConfig, len(c.SystemConfig.Wallets))
+	for i, wallet := range c.SystemConfig.Wallets {
+		if len(wallet.Alerts) > 0 {
+			alerts := make([]string, len(wallet.Alerts))
+			for j, route := range wallet.Alerts {
+				alerts[j] = redactAlertRoute(route)
+			}
+			wallet.Alerts = alerts
+		}
+		redacted.SystemConfig.Wallets[i] = wallet
+	}
+	return redacted
+}
+
+// redactURL 隐藏 URL 中的用户信息与查询参数取值，例如 https://rpc.helius.xyz/?api-key=******。
+// 无法解析时整体隐藏
+func redactURL(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	user := u.User != nil
+	u.User = nil
+	if u.RawQuery != "" {
+		query := u.Query()
+		keys := make([]string, 0, len(query))
+		for key := range query {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		params := make([]string, 0, len(keys))
+		for _, key := range keys {
+			params = append(params, url.QueryEscape(key)+"="+redactedValue)
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	if user {
+		// url.User 会转义 *，直接拼接
+		return strings.Replace(u.String(), "://", "://"+redactedValue+"@", 1)
+	}
+	return u.String()
+}
+
+// configFields 按 yaml 标签展开全部叶子配置项
+func configFields(config *Config) []configField {
+	var fields []configField
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			key := name
+			if prefix != "" {
+				key = prefix + "." + name
+			}
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key)
+				continue
+			}
+			fields = append(fields, configField{Key: key, Value: v.Field(i), Secret: sf.Tag.Get("secret") == "true"})
+		}
+	}
+	walk(reflect.ValueOf(config).Elem(), "")
+	return fields
+}
+
+// setField 把字符串取值写入配置项
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		target := reflect.New(field.Type())
+		if err := yaml.UnmarshalStrict([]byte(value), target.Interface()); err != nil {
+			return err
+		}
+		field.Set(target.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+const (
+	DefaultPublicRPS        = 10 // 公共主网节点限制为每 10 秒 100 次请求
+	DefaultRetryAfter       = 2 * time.Second
+	rateLimitStatusCode     = http.StatusTooManyRequests
+	defaultMethodWeight     = 1
+	maxRetryAfter           = time.Minute
+	rateLimitMethodBatchKey = "batch"
+)
+
+// DefaultMethodWeights 为开销较大的 RPC 方法设置的默认权重，可通过 rpc.method_weights 覆盖
+var DefaultMethodWeights = map[string]float64{
+	"getProgramAccounts":          10,
+	"getSignaturesForAddress":     2,
+	"getTransaction":              2,
+	"getMultipleAccounts":         2,
+	"getTokenAccountsByOwner":     2,
+	"getTokenLargestAccounts":     2,
+	"getRecentPrioritizationFees": 2,
+}
+
+// ErrRateLimited 表示请求被限流，可用 errors.Is 判断
+var ErrRateLimited = errors.New("rpc rate limited")
+
+// RateLimitError 表示节点返回 429 或本地预算不足，RetryAfter 为建议的等待时间
+type RateLimitError struct {
+	Endpoint   string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("节点 %s 限流，%v 后重试: %v", e.Endpoint, e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("节点 %s 限流，%v 后重试", e.Endpoint, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// TokenBucket 令牌桶限流器，rate 为每秒补充的令牌数，burst 为桶容量
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket 创建令牌桶，rate 为 0 时返回 nil 表示不限流
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve 预扣 n 个令牌，返回需要等待的时间
+func (b *TokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refund 归还预扣的令牌
+func (b *TokenBucket) refund(n float64) {
+	b.mu.Lock()
+	b.tokens = math.Min(b.burst, b.tokens+n)
+	b.mu.Unlock()
+}
+
+// Wait 等待 n 个令牌，ctx 结束时归还令牌并返回错误
+func (b *TokenBucket) Wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+	wait := b.reserve(n)
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.refund(n)
+		return ctx.Err()
+	}
+}
+
+// methodWeights 合并默认权重与配置权重
+func methodWeights(overrides map[string]float64) map[string]float64 {
+	weights := make(map[string]float64, len(DefaultMethodWeights)+len(overrides))
+	for method, weight := range DefaultMethodWeights {
+		weights[method] = weight
+	}
+	for method, weight := range overrides {
+		weights[method] = weight
+	}
+	return weights
+}
+
+// retryAfterClient 包装节点的 HTTP 客户端，记录 429 响应中的 Retry-After
+type retryAfterClient struct {
+	client   *http.Client
+	endpoint *rpcEndpoint
+}
+
+func (c *retryAfterClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err == nil && resp.StatusCode == rateLimitStatusCode {
+		c.endpoint.pause(parseRetryAfter(resp.Header.Get("Retry-After")))
+	}
+	return resp, err
+}
+
+func (c *retryAfterClient) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After，缺省时使用 DefaultRetryAfter
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return DefaultRetryAfter
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	} else {
+		return DefaultRetryAfter
+	}
+	if wait <= 0 {
+		return DefaultRetryAfter
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// isRateLimited 判断节点返回的错误是否为 429 限流
+func isRateLimited(err error) bool {
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == rateLimitStatusCode {
+		return true
+	}
+	var rpcErr *jsonrpc.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == rateLimitStatusCode
+}
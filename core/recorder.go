@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 录制记录类型
+const (
+	RecordNotification = "notification" // WebSocket 收到的原始 logsNotification
+	RecordTransaction  = "transaction"  // 获取到的 GetTransactionResult
+)
+
+const (
+	maxRecordSize    = 16 << 20 // 回放文件单行最大长度，交易 JSON 通常远小于该值
+	recorderSeenSize = 10_000   // 记住的最近录制的交易签名数
+)
+
+type RecordConfig struct {
+	File string `yaml:"file"` // 录制文件路径，为空时不录制；已存在时追加
+}
+
+// Record 表示录制文件中的一行
+type Record struct {
+	Time      time.Time       `json:"time"`
+	Kind      string          `json:"kind"`
+	Address   string          `json:"address,omitempty"`   // 通知对应的监控地址
+	Signature string          `json:"signature,omitempty"` // 交易签名
+	Data      json.RawMessage `json:"data"`
+}
+
+// Recorder 把收到的通知与获取到的交易按 JSON Lines 写入文件，供 replay 子命令回放。
+// nil *Recorder 的方法均为空操作
+type Recorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	seen   map[string]*list.Element // 最近录制的交易签名，同一交易短时间内只录制一次
+	order  *list.List               // seen 中的签名，最近录制的在前
+	logger *slog.Logger
+}
+
+// NewRecorder 打开录制文件，不存在时创建
+func NewRecorder(path string, logger *slog.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件 %s 失败: %w", path, err)
+	}
+	return &Recorder{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		seen:   make(map[string]*list.Element),
+		order:  list.New(),
+		logger: loggerOrDiscard(logger),
+	}, nil
+}
+
+// RecordNotification 录制监控地址收到的原始通知
+func (r *Recorder) RecordNotification(address string, msg []byte) {
+	if r == nil {
+		return
+	}
+	r.write(Record{Time: time.Now(), Kind: RecordNotification, Address: address, Data: msg})
+}
+
+// RecordTransaction 录制获取到的交易。只记住最近 recorderSeenSize 个签名，
+// 更早的交易再次获取时会重复录制，回放时后出现的记录覆盖先前的记录
+func (r *Recorder) RecordTransaction(signature string, tx interface{}) {
+	if r == nil {
+		return
+	}
+	if !r.markSeen(signature) {
+		return
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		r.logger.Warn("序列化交易失败", LogKeySignature, signature, LogKeyError, err)
+		return
+	}
+	r.write(Record{Time: time.Now(), Kind: RecordTransaction, Signature: signature, Data: data})
+}
+
+// markSeen 记录签名，签名不在最近录制的记录中时返回 true
+func (r *Recorder) markSeen(signature string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if element, ok := r.seen[signature]; ok {
+		r.order.MoveToFront(element)
+		return false
+	}
+	r.seen[signature] = r.order.PushFront(signature)
+	for r.order.Len() > recorderSeenSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.seen, oldest.Value.(string))
+	}
+	return true
+}
+
+// write 写入一行并立即刷新，进程异常退出时不丢失已录制的内容
+func (r *Recorder) write(record Record) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		r.logger.Warn("序列化录制记录失败", LogKeyError, err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writer.Write(line)
+	r.writer.WriteByte('\n')
+	if err := r.writer.Flush(); err != nil {
+		r.logger.Warn("写入录制文件失败", LogKeyError, err)
+	}
+}
+
+// Close 刷新并关闭录制文件
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// ReadRecords 按顺序读取录制文件中的全部记录
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行解析失败: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestRecorderSeenBounded 校验只记住最近录制的签名，被淘汰的交易再次获取时重新录制
+func TestRecorderSeenBounded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(path, nil)
+	if err != nil {
+		t.Fatalf("创建录制文件失败: %v", err)
+	}
+	tx := map[string]int{"slot": 1}
+	recorder.RecordTransaction("first", tx)
+	recorder.RecordTransaction("first", tx)
+	for i := 0; i < recorderSeenSize; i++ {
+		recorder.RecordTransaction(strconv.Itoa(i), tx)
+	}
+	if len(recorder.seen) != recorderSeenSize || recorder.order.Len() != recorderSeenSize {
+		t.Errorf("记住 %d 个签名，期望不超过 %d", len(recorder.seen), recorderSeenSize)
+	}
+	recorder.RecordTransaction("first", tx)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("关闭录制文件失败: %v", err)
+	}
+
+	records, err := ReadRecords(path)
+	if err != nil {
+		t.Fatalf("读取录制文件失败: %v", err)
+	}
+	if len(records) != recorderSeenSize+2 {
+		t.Errorf("录制了 %d 条记录，期望 %d 条", len(records), recorderSeenSize+2)
+	}
+	var first int
+	for _, record := range records {
+		if record.Signature == "first" {
+			first++
+		}
+	}
+	if first != 2 {
+		t.Errorf("first 录制了 %d 次，期望淘汰前去重、淘汰后重新录制共 2 次", first)
+	}
+}
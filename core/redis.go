@@ -1,41 +1,255 @@
 package core
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
-	"github.com/redis/go-redis/v9"
-	"gopkg.in/yaml.v2"
+	"log/slog"
 	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+
+	DefaultRedisDialTimeout = 5
+	RedisHealthInterval     = 10 * time.Second
+	WatchListRedisKey       = "monitor:addresses" // 配置文件之外的监控地址集合
 )
 
 type RedisConfig struct {
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	Password string `yaml:"password" `
-	DB       int    `yaml:"db"`
+	Mode               string         `yaml:"mode"` // standalone / sentinel / cluster，默认 standalone
+	Host               string         `yaml:"host"`
+	Port               string         `yaml:"port"`
+	Addrs              []string       `yaml:"addrs"`       // sentinel 与 cluster 模式的节点地址 host:port
+	MasterName         string         `yaml:"master_name"` // sentinel 模式的主节点名称
+	Username           string         `yaml:"username"`    // Redis 6 ACL 用户名
+	Password           string         `yaml:"password" secret:"true"`
+	SentinelUsername   string         `yaml:"sentinel_username"`
+	SentinelPassword   string         `yaml:"sentinel_password" secret:"true"`
+	DB                 int            `yaml:"db"`
+	TLS                RedisTLSConfig `yaml:"tls"`
+	DialTimeoutSeconds int            `yaml:"dial_timeout_seconds"` // 默认 5
+	Required           bool           `yaml:"required"`             // 为 true 时启动时连接失败直接退出，否则进入降级模式
 }
 
-func InitRedis() *redis.Client {
-	cfg := readRedisConfig()
-	return redis.NewClient(&redis.Options{
-		Addr:     cfg.Host + ":" + cfg.Port,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	ServerName         string `yaml:"server_name"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"` // 客户端证书，与 key_file 同时配置
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 
-func readRedisConfig() RedisConfig {
-	data, err := os.ReadFile("config.yml")
+// RedisClient 封装 Redis 客户端并跟踪连接状态。
+// Redis 不可用时 Available 返回 false，依赖 Redis 的功能应跳过，监控本身不受影响
+type RedisClient struct {
+	redis.UniversalClient
+	logger    *slog.Logger
+	available atomic.Bool
+}
+
+// InitRedis 按配置创建客户端并检查连接，连接失败时同时返回客户端与错误，调用方可选择降级运行
+func InitRedis(cfg RedisConfig, logger *slog.Logger) (*RedisClient, error) {
+	tlsConfig, err := cfg.TLS.tlsConfig()
 	if err != nil {
-		fmt.Printf("Failed to read config file: %v\n", err)
-		return RedisConfig{}
+		return nil, err
+	}
+	dialTimeout := cfg.DialTimeoutSeconds
+	if dialTimeout == 0 {
+		dialTimeout = DefaultRedisDialTimeout
 	}
 
-	var config Config
-	err = yaml.Unmarshal(data, &config)
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+		TLSConfig:        tlsConfig,
+		DialTimeout:      time.Duration(dialTimeout) * time.Second,
+	}
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case RedisModeSentinel:
+		client = redis.NewFailoverClient(opts.Failover())
+	case RedisModeCluster:
+		client = redis.NewClusterClient(opts.Cluster())
+	default:
+		if len(opts.Addrs) == 0 {
+			opts.Addrs = []string{cfg.Host + ":" + cfg.Port}
+		}
+		client = redis.NewClient(opts.Simple())
+	}
+
+	r := &RedisClient{UniversalClient: client, logger: loggerOrDiscard(logger)}
+	if err := r.Check(context.Background()); err != nil {
+		return r, fmt.Errorf("连接 Redis (%s) 失败: %w", cfg.describe(), err)
+	}
+	return r, nil
+}
+
+// WatchList 读取 Redis 集合中的监控地址，跳过非法地址，按地址排序返回
+func (r *RedisClient) WatchList(ctx context.Context) ([]string, error) {
+	members, err := r.SMembers(ctx, WatchListRedisKey).Result()
 	if err != nil {
-		fmt.Printf("Failed to unmarshal config file: %v\n", err)
-		return RedisConfig{}
+		return nil, err
+	}
+	var addresses []string
+	for _, member := range members {
+		if _, err := solana.PublicKeyFromBase58(member); err != nil {
+			r.logger.Warn("Redis 监控列表中的地址无效", LogKeyAddress, member, LogKeyError, err)
+			continue
+		}
+		addresses = append(addresses, member)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// Check 检查连接并更新连接状态
+func (r *RedisClient) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, RedisHealthInterval)
+	defer cancel()
+	err := r.UniversalClient.Ping(ctx).Err()
+	r.available.Store(err == nil)
+	return err
+}
+
+// Available 返回 Redis 当前是否可用，nil 客户端视为不可用
+func (r *RedisClient) Available() bool {
+	return r != nil && r.available.Load()
+}
+
+// RunHealthCheck 定期检查 Redis 连接，状态变化时记录日志，直到 ctx 结束
+func (r *RedisClient) RunHealthCheck(ctx context.Context) {
+	ticker := time.NewTicker(RedisHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			before := r.Available()
+			err := r.Check(ctx)
+			switch {
+			case before && err != nil:
+				r.logger.Warn("Redis 连接断开，进入降级模式", LogKeyError, err)
+			case !before && err == nil:
+				r.logger.Info("Redis 连接恢复")
+			}
+		case <-ctx.Done():
+			return
+		}
 	}
+}
 
-	return config.Redis
+// describe 返回用于错误信息的连接描述，不包含密码
+func (c RedisConfig) describe() string {
+	switch c.Mode {
+	case RedisModeSentinel:
+		return fmt.Sprintf("sentinel %s %v", c.MasterName, c.Addrs)
+	case RedisModeCluster:
+		return fmt.Sprintf("cluster %v", c.Addrs)
+	}
+	if len(c.Addrs) > 0 {
+		return c.Addrs[0]
+	}
+	return c.Host + ":" + c.Port
+}
+
+// tlsConfig 根据配置创建 TLS 配置，未启用时返回 nil
+func (c RedisTLSConfig) tlsConfig() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Redis CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Redis CA 证书 %s 中没有有效证书", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 Redis 客户端证书失败: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// validateRedis 校验 redis 配置
+func validateRedis(add func(string, error), c RedisConfig) {
+	switch c.Mode {
+	case "", RedisModeStandalone:
+		if len(c.Addrs) == 0 {
+			if c.Host == "" {
+				add("redis.host", errors.New("不能为空"))
+			}
+			if c.Port == "" {
+				add("redis.port", errors.New("不能为空"))
+			}
+		}
+	case RedisModeSentinel:
+		if c.MasterName == "" {
+			add("redis.master_name", errors.New("sentinel 模式下不能为空"))
+		}
+		if len(c.Addrs) == 0 {
+			add("redis.addrs", errors.New("sentinel 模式下至少配置一个哨兵地址"))
+		}
+	case RedisModeCluster:
+		if len(c.Addrs) == 0 {
+			add("redis.addrs", errors.New("cluster 模式下至少配置一个节点地址"))
+		}
+		if c.DB != 0 {
+			add("redis.db", errors.New("cluster 模式只支持 db 0"))
+		}
+	default:
+		add("redis.mode", fmt.Errorf("只能是 %s、%s 或 %s: %q", RedisModeStandalone, RedisModeSentinel, RedisModeCluster, c.Mode))
+	}
+	if c.DB < 0 {
+		add("redis.db", fmt.Errorf("不能为负数: %d", c.DB))
+	}
+	if c.DialTimeoutSeconds < 0 {
+		add("redis.dial_timeout_seconds", fmt.Errorf("不能为负数: %d", c.DialTimeoutSeconds))
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("redis.tls.cert_file", errors.New("cert_file 与 key_file 需要同时配置"))
+	}
+	files := []struct{ key, path string }{
+		{"redis.tls.ca_file", c.TLS.CAFile},
+		{"redis.tls.cert_file", c.TLS.CertFile},
+		{"redis.tls.key_file", c.TLS.KeyFile},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			add(file.key, fmt.Errorf("文件不可读: %v", err))
+		}
+	}
 }
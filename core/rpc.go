@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	DefaultRPCEndpoint         = rpc.MainNetBeta_RPC
+	DefaultWSEndpoint          = rpc.MainNetBeta_WS
+	DefaultHealthCheckInterval = 30
+	DefaultRPCTimeout          = 30
+
+	RPCStrategyFailover   = "failover"    // 始终优先使用排在前面的健康节点
+	RPCStrategyRoundRobin = "round_robin" // 在健康节点之间轮询
+)
+
+type RPCConfig struct {
+	Endpoints                  []EndpointConfig   `yaml:"endpoints"`
+	Strategy                   string             `yaml:"strategy"`                      // failover / round_robin，默认 failover
+	HealthCheckIntervalSeconds int                `yaml:"health_check_interval_seconds"` // 健康检查间隔，默认 30
+	TimeoutSeconds             int                `yaml:"timeout_seconds"`               // 单次请求超时，默认 30
+	MethodWeights              map[string]float64 `yaml:"method_weights"`                // 各方法消耗的令牌数，覆盖 DefaultMethodWeights
+}
+
+type EndpointConfig struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	WSURL   string            `yaml:"ws_url"`  // 为空时由 url 推导，http(s) 替换为 ws(s)
+	Headers map[string]string `yaml:"headers"` // 鉴权请求头，例如 x-api-key，同时用于 HTTP 与 WebSocket
+
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 每秒令牌数，0 表示不限流
+	Burst             int     `yaml:"burst"`               // 令牌桶容量，默认等于 requests_per_second
+}
+
+// EndpointList 返回补全默认值后的节点列表，未配置时使用公共主网节点
+func (c RPCConfig) EndpointList() []EndpointConfig {
+	if len(c.Endpoints) == 0 {
+		return []EndpointConfig{{Name: "mainnet-beta", URL: DefaultRPCEndpoint, WSURL: DefaultWSEndpoint, RequestsPerSecond: DefaultPublicRPS}}
+	}
+	endpoints := make([]EndpointConfig, 0, len(c.Endpoints))
+	for i, endpoint := range c.Endpoints {
+		if endpoint.Name == "" {
+			endpoint.Name = fmt.Sprintf("rpc-%d", i)
+		}
+		if endpoint.WSURL == "" {
+			endpoint.WSURL = wsURL(endpoint.URL)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// validateRPC 校验 rpc 配置
+func validateRPC(add func(string, error), c RPCConfig) {
+	switch c.Strategy {
+	case "", RPCStrategyFailover, RPCStrategyRoundRobin:
+	default:
+		add("rpc.strategy", fmt.Errorf("只能是 %s 或 %s: %q", RPCStrategyFailover, RPCStrategyRoundRobin, c.Strategy))
+	}
+	if c.HealthCheckIntervalSeconds < 0 {
+		add("rpc.health_check_interval_seconds", fmt.Errorf("不能为负数: %d", c.HealthCheckIntervalSeconds))
+	}
+	if c.TimeoutSeconds < 0 {
+		add("rpc.timeout_seconds", fmt.Errorf("不能为负数: %d", c.TimeoutSeconds))
+	}
+	for method, weight := range c.MethodWeights {
+		if weight < 0 {
+			add("rpc.method_weights."+method, fmt.Errorf("不能为负数: %v", weight))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, endpoint := range c.Endpoints {
+		key := fmt.Sprintf("rpc.endpoints[%d]", i)
+		if endpoint.URL == "" {
+			add(key+".url", errors.New("不能为空"))
+		} else {
+			validateURL(add, key+".url", endpoint.URL, "http", "https")
+		}
+		if endpoint.WSURL != "" {
+			validateURL(add, key+".ws_url", endpoint.WSURL, "ws", "wss")
+		}
+		if endpoint.RequestsPerSecond < 0 {
+			add(key+".requests_per_second", fmt.Errorf("不能为负数: %v", endpoint.RequestsPerSecond))
+		}
+		if endpoint.Burst < 0 {
+			add(key+".burst", fmt.Errorf("不能为负数: %d", endpoint.Burst))
+		}
+		if endpoint.Name != "" {
+			if names[endpoint.Name] {
+				add(key+".name", fmt.Errorf("名称重复: %s", endpoint.Name))
+			}
+			names[endpoint.Name] = true
+		}
+	}
+}
+
+// validateURL 校验地址格式与协议
+func validateURL(add func(string, error), key, rawURL string, schemes ...string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		add(key, fmt.Errorf("不是合法的地址: %q", rawURL))
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	add(key, fmt.Errorf("协议只能是 %s: %q", strings.Join(schemes, "/"), rawURL))
+}
+
+// wsURL 把 HTTP 节点地址转换为 WebSocket 地址
+func wsURL(httpURL string) string {
+	switch {
+	case strings.HasPrefix(httpURL, "https://"):
+		return "wss://" + strings.TrimPrefix(httpURL, "https://")
+	case strings.HasPrefix(httpURL, "http://"):
+		return "ws://" + strings.TrimPrefix(httpURL, "http://")
+	}
+	return httpURL
+}
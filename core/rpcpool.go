@@ -0,0 +1,279 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+// rpcEndpoint 表示节点池中的一个节点
+type rpcEndpoint struct {
+	config  EndpointConfig
+	client  jsonrpc.RPCClient
+	limiter *TokenBucket
+	healthy atomic.Bool
+
+	mu          sync.Mutex
+	pausedUntil time.Time // 收到 429 后在此之前不再向该节点发送请求
+}
+
+// pause 按 Retry-After 暂停向节点发送请求
+func (e *rpcEndpoint) pause(wait time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if until := time.Now().Add(wait); until.After(e.pausedUntil) {
+		e.pausedUntil = until
+	}
+}
+
+// pausedFor 返回节点剩余的暂停时间
+func (e *rpcEndpoint) pausedFor() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if wait := time.Until(e.pausedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// RPCPool 在多个 RPC 节点之间做故障切换或轮询，实现 rpc.JSONRPCClient，
+// 通过 rpc.NewWithCustomRPCClient 包装后即可替代单节点客户端
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+	strategy  string
+	weights   map[string]float64
+	interval  time.Duration
+	logger    *slog.Logger
+	next      atomic.Uint64
+	wsMu      sync.Mutex
+	wsIndex   int
+}
+
+// NewRPCPool 根据配置创建节点池，初始时所有节点均视为健康
+func NewRPCPool(cfg RPCConfig, logger *slog.Logger) *RPCPool {
+	timeout := cfg.TimeoutSeconds
+	if timeout == 0 {
+		timeout = DefaultRPCTimeout
+	}
+	interval := cfg.HealthCheckIntervalSeconds
+	if interval == 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = RPCStrategyFailover
+	}
+
+	pool := &RPCPool{
+		strategy: strategy,
+		weights:  methodWeights(cfg.MethodWeights),
+		interval: time.Duration(interval) * time.Second,
+		logger:   loggerOrDiscard(logger),
+	}
+	for _, endpoint := range cfg.EndpointList() {
+		e := &rpcEndpoint{
+			config:  endpoint,
+			limiter: NewTokenBucket(endpoint.RequestsPerSecond, endpoint.Burst),
+		}
+		e.client = jsonrpc.NewClientWithOpts(endpoint.URL, &jsonrpc.RPCClientOpts{
+			HTTPClient:    &retryAfterClient{client: &http.Client{Timeout: time.Duration(timeout) * time.Second}, endpoint: e},
+			CustomHeaders: endpoint.Headers,
+		})
+		e.healthy.Store(true)
+		pool.endpoints = append(pool.endpoints, e)
+	}
+	return pool
+}
+
+// InitRPC 创建节点池与共享的 RPC 客户端
+func InitRPC(cfg RPCConfig, logger *slog.Logger) (*rpc.Client, *RPCPool) {
+	pool := NewRPCPool(cfg, logger)
+	return rpc.NewWithCustomRPCClient(pool), pool
+}
+
+func (p *RPCPool) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	return p.do(ctx, method, p.weight(method), func(client jsonrpc.RPCClient) error {
+		return client.CallForInto(ctx, out, method, params)
+	})
+}
+
+func (p *RPCPool) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	return p.do(ctx, method, p.weight(method), func(client jsonrpc.RPCClient) error {
+		return client.CallWithCallback(ctx, method, params, callback)
+	})
+}
+
+func (p *RPCPool) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	var weight float64
+	for _, request := range requests {
+		weight += p.weight(request.Method)
+	}
+	var responses jsonrpc.RPCResponses
+	err := p.do(ctx, rateLimitMethodBatchKey, weight, func(client jsonrpc.RPCClient) error {
+		var err error
+		responses, err = client.CallBatch(ctx, requests)
+		return err
+	})
+	return responses, err
+}
+
+// weight 返回方法消耗的令牌数
+func (p *RPCPool) weight(method string) float64 {
+	if weight, ok := p.weights[method]; ok {
+		return weight
+	}
+	return defaultMethodWeight
+}
+
+// do 按策略依次尝试节点，调用前先从节点的令牌桶中取 weight 个令牌。
+// 被限流的节点按 Retry-After 暂停并切换到下一个节点，节点级错误时标记为不健康并切换，
+// 节点返回的 JSON-RPC 业务错误直接返回。全部节点都被限流时返回 *RateLimitError
+func (p *RPCPool) do(ctx context.Context, method string, weight float64, call func(jsonrpc.RPCClient) error) error {
+	var errs []error
+	var limited *RateLimitError
+	for _, endpoint := range p.candidates() {
+		if wait := endpoint.pausedFor(); wait > 0 {
+			if limited == nil || wait < limited.RetryAfter {
+				limited = &RateLimitError{Endpoint: endpoint.config.Name, RetryAfter: wait}
+			}
+			continue
+		}
+		if err := endpoint.limiter.Wait(ctx, weight); err != nil {
+			return err
+		}
+
+		err := call(endpoint.client)
+		if err == nil {
+			p.markHealthy(endpoint)
+			return nil
+		}
+		if ctx.Err() == nil {
+			RPCErrors.WithLabelValues(method, rpcErrorCode(err)).Inc()
+		}
+		if isRateLimited(err) {
+			wait := endpoint.pausedFor()
+			if wait == 0 {
+				// 只有 JSON-RPC 错误码 429 而没有 HTTP 429 时使用默认等待时间
+				wait = DefaultRetryAfter
+				endpoint.pause(wait)
+			}
+			p.logger.Warn("调用节点被限流，暂停并切换节点", "method", method, "endpoint", endpoint.config.Name, "retry_after", wait)
+			if limited == nil || wait < limited.RetryAfter {
+				limited = &RateLimitError{Endpoint: endpoint.config.Name, RetryAfter: wait, Err: err}
+			}
+			continue
+		}
+		var rpcErr *jsonrpc.RPCError
+		if errors.As(err, &rpcErr) || ctx.Err() != nil {
+			return err
+		}
+		p.markUnhealthy(endpoint, err)
+		p.logger.Warn("调用节点失败，切换节点", "method", method, "endpoint", endpoint.config.Name, LogKeyError, err)
+		errs = append(errs, err)
+	}
+	if limited != nil {
+		return limited
+	}
+	return errors.Join(errs...)
+}
+
+// candidates 返回本次调用的节点顺序，健康节点在前，不健康节点作为最后的兜底
+func (p *RPCPool) candidates() []*rpcEndpoint {
+	start := 0
+	if p.strategy == RPCStrategyRoundRobin {
+		start = int(p.next.Add(1)-1) % len(p.endpoints)
+	}
+	healthy := make([]*rpcEndpoint, 0, len(p.endpoints))
+	var unhealthy []*rpcEndpoint
+	for i := range p.endpoints {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		if endpoint.healthy.Load() {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// WSEndpoints 返回 WebSocket 连接的候选节点顺序。
+// failover 策略始终从排在最前的健康节点开始，round_robin 策略每次重连换一个节点
+func (p *RPCPool) WSEndpoints() []EndpointConfig {
+	p.wsMu.Lock()
+	start := 0
+	if p.strategy == RPCStrategyRoundRobin {
+		start = p.wsIndex % len(p.endpoints)
+		p.wsIndex++
+	}
+	p.wsMu.Unlock()
+
+	var healthy, unhealthy []EndpointConfig
+	for i := range p.endpoints {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		if endpoint.healthy.Load() {
+			healthy = append(healthy, endpoint.config)
+		} else {
+			unhealthy = append(unhealthy, endpoint.config)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// RunHealthCheck 定期调用 getHealth 检查全部节点，直到 ctx 结束
+func (p *RPCPool) RunHealthCheck(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.checkAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close 关闭全部节点的空闲连接，rpc.Client.Close 会调用它
+func (p *RPCPool) Close() error {
+	var errs []error
+	for _, endpoint := range p.endpoints {
+		errs = append(errs, endpoint.client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// checkAll 检查全部节点的健康状态
+func (p *RPCPool) checkAll(ctx context.Context) {
+	for _, endpoint := range p.endpoints {
+		var status string
+		err := endpoint.client.CallForInto(ctx, &status, "getHealth", nil)
+		if err == nil && status == rpc.HealthOk {
+			p.markHealthy(endpoint)
+			continue
+		}
+		if err == nil {
+			err = errors.New(status)
+		}
+		p.markUnhealthy(endpoint, err)
+	}
+}
+
+func (p *RPCPool) markHealthy(endpoint *rpcEndpoint) {
+	if !endpoint.healthy.Swap(true) {
+		p.logger.Info("节点恢复健康", "endpoint", endpoint.config.Name)
+	}
+}
+
+func (p *RPCPool) markUnhealthy(endpoint *rpcEndpoint, err error) {
+	if endpoint.healthy.Swap(false) {
+		p.logger.Warn("节点标记为不健康", "endpoint", endpoint.config.Name, LogKeyError, err)
+	}
+}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	StoreDriverSQLite   = "sqlite"
+	StoreDriverPostgres = "postgres"
+
+	TradeDirectionBuy  = "buy"
+	TradeDirectionSell = "sell"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+type StoreConfig struct {
+	Driver string `yaml:"driver"`            // sqlite / postgres，为空时不保存交易
+	DSN    string `yaml:"dsn" secret:"true"` // sqlite 为数据库文件路径，postgres 为连接串
+}
+
+// validateStore 校验 store 配置
+func validateStore(add func(string, error), c StoreConfig) {
+	switch c.Driver {
+	case "":
+		return
+	case StoreDriverSQLite, StoreDriverPostgres:
+	default:
+		add("store.driver", fmt.Errorf("只能是 %s 或 %s: %q", StoreDriverSQLite, StoreDriverPostgres, c.Driver))
+	}
+	if c.DSN == "" {
+		add("store.dsn", errors.New("配置 store.driver 时不能为空"))
+	}
+}
+
+// Trade 表示被监控地址在一笔交易中对某个代币的一次买卖，一笔交易可能包含多个 leg
+type Trade struct {
+	Signature     string
+	Leg           int // 同一笔交易内的序号，从 0 开始
+	Slot          uint64
+	BlockTime     time.Time // 零值表示未知
+	Wallet        string
+	Mint          string
+	Direction     string // buy / sell
+	TokenAmount   uint64 // 最小单位
+	TokenDecimals uint8
+	QuoteMint     string // 计价代币，通常为 SOL
+	QuoteAmount   uint64 // 最小单位
+	QuoteDecimals uint8
+	Price         float64 // 每个代币对应的计价代币数量
+	DEX           string
+	Fee           uint64  // lamports
+	SOLPriceUSD   float64 // 记录交易时的 SOL/USD 价格，0 表示未知
+}
+
+// TokenUI 返回按精度换算后的代币数量
+func (t Trade) TokenUI() float64 {
+	return float64(t.TokenAmount) / math.Pow10(int(t.TokenDecimals))
+}
+
+// QuoteUI 返回按精度换算后的计价代币数量
+func (t Trade) QuoteUI() float64 {
+	return float64(t.QuoteAmount) / math.Pow10(int(t.QuoteDecimals))
+}
+
+// TradeFilter 表示查询条件，零值字段不参与过滤
+type TradeFilter struct {
+	Wallet    string
+	Mint      string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Ascending bool // 按时间正序返回，默认倒序
+}
+
+// TradeStore 使用 SQLite 或 Postgres 保存解析后的交易。nil *TradeStore 的写入为空操作
+type TradeStore struct {
+	db     *sql.DB
+	driver string
+	logger *slog.Logger
+}
+
+// OpenStore 按配置连接数据库并执行未应用的迁移
+func OpenStore(ctx context.Context, cfg StoreConfig, logger *slog.Logger) (*TradeStore, error) {
+	s := &TradeStore{driver: cfg.Driver, logger: loggerOrDiscard(logger)}
+	var err error
+	switch cfg.Driver {
+	case StoreDriverSQLite:
+		if dir := filepath.Dir(cfg.DSN); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建数据库目录 %s 失败: %w", dir, err)
+			}
+		}
+		s.db, err = sql.Open("sqlite", cfg.DSN)
+		if err == nil {
+			// SQLite 只允许一个写连接，单连接同时避免 busy 错误
+			s.db.SetMaxOpenConns(1)
+			_, err = s.db.ExecContext(ctx, "PRAGMA journal_mode=WAL; PRAGMA busy_timeout=5000")
+		}
+	case StoreDriverPostgres:
+		s.db, err = sql.Open("pgx", cfg.DSN)
+		if err == nil {
+			err = s.db.PingContext(ctx)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型: %q", cfg.Driver)
+	}
+	if err != nil {
+		if s.db != nil {
+			s.db.Close()
+		}
+		return nil, fmt.Errorf("连接 %s 数据库失败: %w", cfg.Driver, err)
+	}
+
+	if err := s.migrate(ctx); err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close 关闭数据库连接
+func (s *TradeStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// SaveTrades 按 (signature, leg) 写入交易，已存在时覆盖，重复写入结果相同。
+// 新写入的 SOL 价格未知时保留已记录的价格，避免回填覆盖实时记录的价格
+func (s *TradeStore) SaveTrades(ctx context.Context, trades []Trade) error {
+	if s == nil || len(trades) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO trades (signature, leg, slot, block_time, wallet, mint, direction,
+			token_amount, token_decimals, quote_mint, quote_amount, quote_decimals, price, dex, fee, sol_price_usd, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (signature, leg) DO UPDATE SET
+			slot = excluded.slot, block_time = excluded.block_time, wallet = excluded.wallet,
+			mint = excluded.mint, direction = excluded.direction,
+			token_amount = excluded.token_amount, token_decimals = excluded.token_decimals,
+			quote_mint = excluded.quote_mint, quote_amount = excluded.quote_amount,
+			quote_decimals = excluded.quote_decimals, price = excluded.price,
+			dex = excluded.dex, fee = excluded.fee, updated_at = excluded.updated_at,
+			sol_price_usd = CASE WHEN excluded.sol_price_usd > 0 THEN excluded.sol_price_usd ELSE trades.sol_price_usd END`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	for _, t := range trades {
+		var blockTime int64
+		if !t.BlockTime.IsZero() {
+			blockTime = t.BlockTime.Unix()
+		}
+		_, err := stmt.ExecContext(ctx, t.Signature, t.Leg, int64(t.Slot), blockTime, t.Wallet, t.Mint, t.Direction,
+			int64(t.TokenAmount), int(t.TokenDecimals), t.QuoteMint, int64(t.QuoteAmount), int(t.QuoteDecimals),
+			t.Price, t.DEX, int64(t.Fee), t.SOLPriceUSD, now)
+		if err != nil {
+			return fmt.Errorf("写入交易 %s#%d 失败: %w", t.Signature, t.Leg, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Trades 按条件查询交易
+func (s *TradeStore) Trades(ctx context.Context, filter TradeFilter) ([]Trade, error) {
+	var where []string
+	var args []interface{}
+	if filter.Wallet != "" {
+		where = append(where, "wallet = ?")
+		args = append(args, filter.Wallet)
+	}
+	if filter.Mint != "" {
+		where = append(where, "mint = ?")
+		args = append(args, filter.Mint)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "block_time >= ?")
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "block_time < ?")
+		args = append(args, filter.Until.Unix())
+	}
+
+	query := `SELECT signature, leg, slot, block_time, wallet, mint, direction, token_amount, token_decimals,
+		quote_mint, quote_amount, quote_decimals, price, dex, fee, sol_price_usd FROM trades`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	order := "DESC"
+	if filter.Ascending {
+		order = "ASC"
+	}
+	query += fmt.Sprintf(" ORDER BY block_time %s, slot %s, signature, leg", order, order)
+	if filter.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []Trade
+	for rows.Next() {
+		var t Trade
+		var slot, blockTime, tokenAmount, quoteAmount, fee int64
+		var tokenDecimals, quoteDecimals int
+		err := rows.Scan(&t.Signature, &t.Leg, &slot, &blockTime, &t.Wallet, &t.Mint, &t.Direction,
+			&tokenAmount, &tokenDecimals, &t.QuoteMint, &quoteAmount, &quoteDecimals, &t.Price, &t.DEX, &fee, &t.SOLPriceUSD)
+		if err != nil {
+			return nil, err
+		}
+		t.Slot = uint64(slot)
+		if blockTime > 0 {
+			t.BlockTime = time.Unix(blockTime, 0)
+		}
+		t.TokenAmount = uint64(tokenAmount)
+		t.TokenDecimals = uint8(tokenDecimals)
+		t.QuoteAmount = uint64(quoteAmount)
+		t.QuoteDecimals = uint8(quoteDecimals)
+		t.Fee = uint64(fee)
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+// HistoryCursor 表示 history 子命令对某个地址的回填进度
+type HistoryCursor struct {
+	Wallet      string
+	Before      string // 进行中的运行已处理的最早签名，下次从它之前继续；为空表示没有未完成的运行
+	Slot        uint64 // Before 所在 slot
+	Processed   int64  // 累计处理的签名数
+	Newest      string // 已完整回填的最新签名，之后的运行回填到它为止
+	NewestSlot  uint64 // Newest 所在 slot
+	Pending     string // 进行中的运行开始时的最新签名，运行完成后成为 Newest
+	PendingSlot uint64 // Pending 所在 slot
+}
+
+// HistoryCursor 返回地址的回填进度，不存在时 ok 为 false
+func (s *TradeStore) HistoryCursor(ctx context.Context, wallet string) (cursor HistoryCursor, ok bool, err error) {
+	var slot, newestSlot, pendingSlot int64
+	err = s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT before_signature, slot, processed, newest_signature, newest_slot, pending_signature, pending_slot
+		FROM history_cursors WHERE wallet = ?`), wallet).
+		Scan(&cursor.Before, &slot, &cursor.Processed, &cursor.Newest, &newestSlot, &cursor.Pending, &pendingSlot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return HistoryCursor{Wallet: wallet}, false, nil
+	}
+	if err != nil {
+		return cursor, false, err
+	}
+	cursor.Wallet = wallet
+	cursor.Slot = uint64(slot)
+	cursor.NewestSlot = uint64(newestSlot)
+	cursor.PendingSlot = uint64(pendingSlot)
+	return cursor, true, nil
+}
+
+// SaveHistoryCursor 保存地址的回填进度
+func (s *TradeStore) SaveHistoryCursor(ctx context.Context, cursor HistoryCursor) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO history_cursors (wallet, before_signature, slot, processed, newest_signature, newest_slot,
+			pending_signature, pending_slot, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (wallet) DO UPDATE SET
+			before_signature = excluded.before_signature, slot = excluded.slot, processed = excluded.processed,
+			newest_signature = excluded.newest_signature, newest_slot = excluded.newest_slot,
+			pending_signature = excluded.pending_signature, pending_slot = excluded.pending_slot,
+			updated_at = excluded.updated_at`),
+		cursor.Wallet, cursor.Before, int64(cursor.Slot), cursor.Processed, cursor.Newest, int64(cursor.NewestSlot),
+		cursor.Pending, int64(cursor.PendingSlot), time.Now().Unix())
+	return err
+}
+
+// DeleteHistoryCursor 删除地址的回填进度，下次从最新交易开始
+func (s *TradeStore) DeleteHistoryCursor(ctx context.Context, wallet string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM history_cursors WHERE wallet = ?"), wallet)
+	return err
+}
+
+// HistoryFailure 表示回填时获取或解析失败的交易
+type HistoryFailure struct {
+	Wallet    string
+	Signature string
+	Error     string // 最近一次失败的原因
+	Attempts  int    // 累计失败次数
+}
+
+// SaveHistoryFailures 记录失败的交易，已存在时累加失败次数
+func (s *TradeStore) SaveHistoryFailures(ctx context.Context, failures []HistoryFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`
+		INSERT INTO history_failures (wallet, signature, error, attempts, updated_at) VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT (wallet, signature) DO UPDATE SET
+			error = excluded.error, attempts = history_failures.attempts + 1, updated_at = excluded.updated_at`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	for _, f := range failures {
+		if _, err := stmt.ExecContext(ctx, f.Wallet, f.Signature, f.Error, now); err != nil {
+			return fmt.Errorf("记录失败交易 %s 失败: %w", f.Signature, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// HistoryFailures 返回地址失败次数少于 maxAttempts 的交易，maxAttempts 为 0 时返回全部
+func (s *TradeStore) HistoryFailures(ctx context.Context, wallet string, maxAttempts int) ([]HistoryFailure, error) {
+	query := "SELECT signature, error, attempts FROM history_failures WHERE wallet = ?"
+	args := []interface{}{wallet}
+	if maxAttempts > 0 {
+		query += " AND attempts < ?"
+		args = append(args, maxAttempts)
+	}
+	rows, err := s.db.QueryContext(ctx, s.rebind(query+" ORDER BY signature"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []HistoryFailure
+	for rows.Next() {
+		f := HistoryFailure{Wallet: wallet}
+		if err := rows.Scan(&f.Signature, &f.Error, &f.Attempts); err != nil {
+			return nil, err
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// DeleteHistoryFailures 删除重试成功的交易
+func (s *TradeStore) DeleteHistoryFailures(ctx context.Context, wallet string, signatures []string) error {
+	for _, signature := range signatures {
+		if _, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM history_failures WHERE wallet = ? AND signature = ?"), wallet, signature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migration 表示一个版本的迁移脚本
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrate 按版本号顺序执行尚未应用的迁移，每个版本在单独的事务中执行并记录到 schema_migrations
+func (s *TradeStore) migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(s.driver)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("创建 schema_migrations 失败: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := s.db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("执行迁移 %s 失败: %w", m.name, err)
+		}
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			m.version, m.name, time.Now().Unix())
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("记录迁移 %s 失败: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		s.logger.Info("已执行数据库迁移", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+// loadMigrations 读取 migrations/<driver>/ 下以 "<版本号>_" 开头的 SQL 文件并按版本号排序
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 迁移脚本失败: %w", driver, err)
+	}
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("迁移脚本 %s 缺少版本号前缀", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("迁移脚本 %s 与 %s 版本号重复", name, other)
+		}
+		seen[version] = name
+		data, err := migrationFS.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// rebind 把 ? 占位符转换为 postgres 的 $n
+func (s *TradeStore) rebind(query string) string {
+	if s.driver != StoreDriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, path string) *TradeStore {
+	t.Helper()
+	store, err := OpenStore(context.Background(), StoreConfig{Driver: StoreDriverSQLite, DSN: path}, nil)
+	if err != nil {
+		t.Fatalf("打开交易存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func storeTrade(signature string, leg int, wallet, mint string, at time.Time) Trade {
+	return Trade{
+		Signature:     signature,
+		Leg:           leg,
+		Slot:          uint64(at.Unix()),
+		BlockTime:     at,
+		Wallet:        wallet,
+		Mint:          mint,
+		Direction:     TradeDirectionBuy,
+		TokenAmount:   1000,
+		TokenDecimals: 6,
+		QuoteMint:     "So11111111111111111111111111111111111111112",
+		QuoteAmount:   1_000_000_000,
+		QuoteDecimals: 9,
+		Price:         0.001,
+		DEX:           "raydium",
+		Fee:           5000,
+	}
+}
+
+// TestSaveTradesIdempotent 校验按 (signature, leg) 覆盖写入，重复保存不产生重复记录
+func TestSaveTradesIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t, filepath.Join(t.TempDir(), "trades.db"))
+	at := time.Unix(1_700_000_000, 0)
+	trades := []Trade{
+		storeTrade("sig1", 0, "wallet", "mintA", at),
+		storeTrade("sig1", 1, "wallet", "mintB", at),
+		storeTrade("sig2", 0, "wallet", "mintA", at.Add(time.Minute)),
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.SaveTrades(ctx, trades); err != nil {
+			t.Fatalf("第 %d 次保存失败: %v", i+1, err)
+		}
+	}
+	got, err := store.Trades(ctx, TradeFilter{})
+	if err != nil {
+		t.Fatalf("查询交易失败: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("重复保存后有 %d 笔交易，期望 3 笔", len(got))
+	}
+
+	// 同一 (signature, leg) 再次保存时覆盖原记录
+	updated := trades[1]
+	updated.TokenAmount, updated.Direction = 2000, TradeDirectionSell
+	if err := store.SaveTrades(ctx, []Trade{updated}); err != nil {
+		t.Fatalf("覆盖保存失败: %v", err)
+	}
+	got, err = store.Trades(ctx, TradeFilter{Mint: "mintB"})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("查询交易失败: %v, %+v", err, got)
+	}
+	if got[0] != updated {
+		t.Errorf("覆盖后为 %+v，期望 %+v", got[0], updated)
+	}
+}
+
+// TestMigrationsAppliedOnce 校验全部迁移按版本记录到 schema_migrations，重新打开时不再执行
+func TestMigrationsAppliedOnce(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "trades.db")
+	migrations, err := loadMigrations(StoreDriverSQLite)
+	if err != nil {
+		t.Fatalf("读取迁移脚本失败: %v", err)
+	}
+
+	applied := func(store *TradeStore) map[int]int64 {
+		t.Helper()
+		rows, err := store.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+		if err != nil {
+			t.Fatalf("查询 schema_migrations 失败: %v", err)
+		}
+		defer rows.Close()
+		versions := make(map[int]int64)
+		for rows.Next() {
+			var version int
+			var at int64
+			if err := rows.Scan(&version, &at); err != nil {
+				t.Fatalf("读取 schema_migrations 失败: %v", err)
+			}
+			versions[version] = at
+		}
+		return versions
+	}
+
+	store := openTestStore(t, path)
+	first := applied(store)
+	if len(first) != len(migrations) {
+		t.Fatalf("记录了 %d 个迁移，期望 %d 个", len(first), len(migrations))
+	}
+	for _, m := range migrations {
+		if _, ok := first[m.version]; !ok {
+			t.Errorf("迁移 %s 未记录", m.name)
+		}
+	}
+	if err := store.SaveTrades(ctx, []Trade{storeTrade("sig", 0, "wallet", "mint", time.Unix(1_700_000_000, 0))}); err != nil {
+		t.Fatalf("保存交易失败: %v", err)
+	}
+	store.Close()
+
+	// 重新打开时迁移不再执行，已有数据保留
+	store = openTestStore(t, path)
+	second := applied(store)
+	if len(second) != len(first) {
+		t.Fatalf("重新打开后记录了 %d 个迁移，期望 %d 个", len(second), len(first))
+	}
+	for version, at := range first {
+		if second[version] != at {
+			t.Errorf("迁移 %d 被重新执行", version)
+		}
+	}
+	if trades, err := store.Trades(ctx, TradeFilter{}); err != nil || len(trades) != 1 {
+		t.Errorf("重新打开后有 %d 笔交易, %v，期望 1 笔", len(trades), err)
+	}
+}
+
+// TestMigrationsMatchDrivers 校验 SQLite 与 Postgres 的迁移版本一一对应
+func TestMigrationsMatchDrivers(t *testing.T) {
+	sqlite, err := loadMigrations(StoreDriverSQLite)
+	if err != nil {
+		t.Fatalf("读取 sqlite 迁移脚本失败: %v", err)
+	}
+	postgres, err := loadMigrations(StoreDriverPostgres)
+	if err != nil {
+		t.Fatalf("读取 postgres 迁移脚本失败: %v", err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("sqlite 有 %d 个迁移，postgres 有 %d 个", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].version != postgres[i].version || sqlite[i].name != postgres[i].name {
+			t.Errorf("第 %d 个迁移不一致: %s / %s", i, sqlite[i].name, postgres[i].name)
+		}
+	}
+}
+
+func TestTradesFilter(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t, filepath.Join(t.TempDir(), "trades.db"))
+	start := time.Unix(1_700_000_000, 0)
+	err := store.SaveTrades(ctx, []Trade{
+		storeTrade("a1", 0, "walletA", "mint1", start),
+		storeTrade("a2", 0, "walletA", "mint2", start.Add(time.Hour)),
+		storeTrade("a3", 0, "walletA", "mint1", start.Add(2*time.Hour)),
+		storeTrade("b1", 0, "walletB", "mint1", start.Add(3*time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("保存交易失败: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter TradeFilter
+		want   []string
+	}{
+		{"全部倒序", TradeFilter{}, []string{"b1", "a3", "a2", "a1"}},
+		{"正序", TradeFilter{Ascending: true}, []string{"a1", "a2", "a3", "b1"}},
+		{"钱包", TradeFilter{Wallet: "walletA"}, []string{"a3", "a2", "a1"}},
+		{"代币", TradeFilter{Mint: "mint1"}, []string{"b1", "a3", "a1"}},
+		{"钱包与代币", TradeFilter{Wallet: "walletA", Mint: "mint1"}, []string{"a3", "a1"}},
+		{"起始时间含边界", TradeFilter{Since: start.Add(time.Hour)}, []string{"b1", "a3", "a2"}},
+		{"结束时间不含边界", TradeFilter{Until: start.Add(2 * time.Hour)}, []string{"a2", "a1"}},
+		{"数量", TradeFilter{Wallet: "walletA", Limit: 2}, []string{"a3", "a2"}},
+		{"正序数量", TradeFilter{Limit: 1, Ascending: true}, []string{"a1"}},
+		{"无匹配", TradeFilter{Wallet: "walletC"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trades, err := store.Trades(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("查询交易失败: %v", err)
+			}
+			var got []string
+			for _, trade := range trades {
+				got = append(got, trade.Signature)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("查询结果为 %v，期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("查询结果为 %v，期望 %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreRebind(t *testing.T) {
+	query := "SELECT * FROM trades WHERE wallet = ? AND mint = ? AND block_time >= ?"
+	if got := (&TradeStore{driver: StoreDriverSQLite}).rebind(query); got != query {
+		t.Errorf("sqlite 占位符被改写为 %q", got)
+	}
+	want := "SELECT * FROM trades WHERE wallet = $1 AND mint = $2 AND block_time >= $3"
+	if got := (&TradeStore{driver: StoreDriverPostgres}).rebind(query); got != want {
+		t.Errorf("postgres 占位符改写为 %q，期望 %q", got, want)
+	}
+	// 超过 9 个参数时编号为多位数
+	long := "VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if got := (&TradeStore{driver: StoreDriverPostgres}).rebind(long); got != "VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)" {
+		t.Errorf("postgres 多参数改写为 %q", got)
+	}
+}
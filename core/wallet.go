@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+const DefaultWalletCommitment = "confirmed"
+
+type WalletConfig struct {
+	Address    string             `yaml:"address"`
+	Label      string             `yaml:"label"`
+	Tags       []string           `yaml:"tags"`
+	Commitment string             `yaml:"commitment"` // processed / confirmed / finalized
+	Follow     WalletFollowConfig `yaml:"follow"`
+	Alerts     []string           `yaml:"alerts"` // 交易告警路由，例如 telegram:<chat_id>、webhook:<url>
+}
+
+type WalletFollowConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	Ratio          float64 `yaml:"ratio"`            // 跟单金额相对跟单地址成交额的比例，0 表示 1:1
+	MaxSlippageBps uint16  `yaml:"max_slippage_bps"` // 覆盖 follow.max_slippage_bps
+}
+
+// WatchList 返回全部监控地址，self_address 与 monitor_address 作为简写合并在最前面
+func (c SystemConfig) WatchList() []WalletConfig {
+	var wallets []WalletConfig
+	seen := make(map[string]bool)
+	add := func(wallet WalletConfig) {
+		if wallet.Address == "" || seen[wallet.Address] {
+			return
+		}
+		seen[wallet.Address] = true
+		if wallet.Label == "" {
+			wallet.Label = shortAddress(wallet.Address)
+		}
+		if wallet.Commitment == "" {
+			wallet.Commitment = DefaultWalletCommitment
+		}
+		wallets = append(wallets, wallet)
+	}
+
+	add(WalletConfig{Address: c.SelfAddress, Label: "self", Tags: []string{"self"}})
+	add(WalletConfig{Address: c.MonitorAddress, Label: "monitor", Tags: []string{"monitor"}})
+	for _, wallet := range c.Wallets {
+		add(wallet)
+	}
+	return wallets
+}
+
+// Wallet 根据地址查找监控地址配置
+func (c SystemConfig) Wallet(address string) (WalletConfig, bool) {
+	for _, wallet := range c.WatchList() {
+		if wallet.Address == address {
+			return wallet, true
+		}
+	}
+	return WalletConfig{}, false
+}
+
+// Label 返回地址的标签，未配置的地址返回缩写
+func (c SystemConfig) Label(address string) string {
+	if wallet, ok := c.Wallet(address); ok {
+		return wallet.Label
+	}
+	return shortAddress(address)
+}
+
+// validateWallets 校验 wallets 列表
+func validateWallets(add func(string, error), c SystemConfig) {
+	labels := make(map[string]string)
+	addresses := make(map[string]bool)
+	for i, wallet := range c.Wallets {
+		key := fmt.Sprintf("system.wallets[%d]", i)
+		if wallet.Address == "" {
+			add(key+".address", errors.New("不能为空"))
+		}
+		validateAddress(add, key+".address", wallet.Address)
+		if addresses[wallet.Address] {
+			add(key+".address", fmt.Errorf("地址重复: %s", wallet.Address))
+		}
+		addresses[wallet.Address] = true
+
+		switch wallet.Commitment {
+		case "", "processed", "confirmed", "finalized":
+		default:
+			add(key+".commitment", fmt.Errorf("只能是 processed、confirmed 或 finalized: %q", wallet.Commitment))
+		}
+		if wallet.Follow.Enabled && c.PrivateKey == "" {
+			add(key+".follow.enabled", errors.New("开启跟单时 system.private_key 不能为空"))
+		}
+		if wallet.Follow.Ratio < 0 {
+			add(key+".follow.ratio", fmt.Errorf("不能为负数: %v", wallet.Follow.Ratio))
+		}
+		if wallet.Follow.MaxSlippageBps > 10_000 {
+			add(key+".follow.max_slippage_bps", fmt.Errorf("不能超过 10000: %d", wallet.Follow.MaxSlippageBps))
+		}
+	}
+
+	for _, wallet := range c.WatchList() {
+		if other, ok := labels[wallet.Label]; ok && other != wallet.Address {
+			add("system.wallets", fmt.Errorf("标签 %q 重复: %s 与 %s", wallet.Label, other, wallet.Address))
+		}
+		labels[wallet.Label] = wallet.Address
+	}
+}
+
+// shortAddress 把地址缩写为 前4位...后4位
+func shortAddress(address string) string {
+	if len(address) <= 8 {
+		return address
+	}
+	return address[:4] + "..." + address[len(address)-4:]
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 队列已满时的处理策略
+const (
+	QueuePolicyBlock      = "block"       // 阻塞提交方直到队列有空位
+	QueuePolicyDropNewest = "drop_newest" // 丢弃新提交的任务
+	QueuePolicyDropOldest = "drop_oldest" // 丢弃队列中最早的任务，为新任务腾出位置
+
+	DefaultQueueWorkers = 8
+	DefaultQueueSize    = 1000
+	queueStatsInterval  = time.Minute
+)
+
+type QueueConfig struct {
+	Workers    int    `yaml:"workers"`     // 并发获取与解析交易的 worker 数，默认 8
+	Size       int    `yaml:"size"`        // 待处理交易队列容量，默认 1000
+	FullPolicy string `yaml:"full_policy"` // 队列已满时的策略: block / drop_newest / drop_oldest，默认 drop_oldest
+}
+
+// WorkersOrDefault 返回 worker 数，未配置时使用默认值
+func (c QueueConfig) WorkersOrDefault() int {
+	if c.Workers == 0 {
+		return DefaultQueueWorkers
+	}
+	return c.Workers
+}
+
+// SizeOrDefault 返回队列容量，未配置时使用默认值
+func (c QueueConfig) SizeOrDefault() int {
+	if c.Size == 0 {
+		return DefaultQueueSize
+	}
+	return c.Size
+}
+
+// FullPolicyOrDefault 返回队列已满时的策略，未配置时使用 drop_oldest
+func (c QueueConfig) FullPolicyOrDefault() string {
+	if c.FullPolicy == "" {
+		return QueuePolicyDropOldest
+	}
+	return c.FullPolicy
+}
+
+// validateQueue 校验 queue 配置
+func validateQueue(add func(string, error), c QueueConfig) {
+	if c.Workers < 0 {
+		add("queue.workers", fmt.Errorf("不能为负数: %d", c.Workers))
+	}
+	if c.Size < 0 {
+		add("queue.size", fmt.Errorf("不能为负数: %d", c.Size))
+	}
+	switch c.FullPolicy {
+	case "", QueuePolicyBlock, QueuePolicyDropNewest, QueuePolicyDropOldest:
+	default:
+		add("queue.full_policy", fmt.Errorf("只能是 %s、%s 或 %s: %q", QueuePolicyBlock, QueuePolicyDropNewest, QueuePolicyDropOldest, c.FullPolicy))
+	}
+}
+
+// Job 表示一个待执行的任务，Name 用于日志
+type Job struct {
+	Name string
+	Run  func(ctx context.Context)
+
+	enqueuedAt time.Time
+}
+
+// WorkerPoolStats 表示工作池的积压情况
+type WorkerPoolStats struct {
+	Queued    int           // 队列中等待的任务数
+	Capacity  int           // 队列容量
+	Busy      int64         // 正在执行的任务数
+	Submitted uint64        // 累计提交的任务数
+	Completed uint64        // 累计完成的任务数
+	Dropped   uint64        // 因队列已满被丢弃的任务数
+	AvgWait   time.Duration // 已开始执行的任务在队列中的平均等待时间
+	MaxWait   time.Duration // 已开始执行的任务在队列中的最长等待时间
+}
+
+// WorkerPool 固定数量的 worker 从有界队列中取任务执行
+type WorkerPool struct {
+	workers int
+	policy  string
+	logger  *slog.Logger
+	queue   chan Job
+
+	mu       sync.RWMutex
+	closed   bool
+	stopping chan struct{} // 关闭时先通知阻塞中的 Submit 放弃等待，再关闭队列
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	busy      atomic.Int64
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	dropped   atomic.Uint64
+	started   atomic.Uint64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+}
+
+// NewWorkerPool 按配置创建工作池，调用 Start 后开始执行任务
+func NewWorkerPool(cfg QueueConfig, logger *slog.Logger) *WorkerPool {
+	return &WorkerPool{
+		workers:  cfg.WorkersOrDefault(),
+		policy:   cfg.FullPolicyOrDefault(),
+		logger:   loggerOrDiscard(logger),
+		queue:    make(chan Job, cfg.SizeOrDefault()),
+		stopping: make(chan struct{}),
+	}
+}
+
+// Start 启动 worker，任务使用 ctx 执行
+func (p *WorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+}
+
+// Submit 提交任务，队列已满时按策略处理，任务被丢弃或工作池已关闭时返回 false
+func (p *WorkerPool) Submit(job Job) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.submitted.Add(1)
+	job.enqueuedAt = time.Now()
+
+	select {
+	case p.queue <- job:
+		return true
+	default:
+	}
+
+	switch p.policy {
+	case QueuePolicyBlock:
+		p.logger.Warn("任务队列已满，等待空位", "capacity", cap(p.queue), "job", job.Name)
+		select {
+		case p.queue <- job:
+			return true
+		case <-p.stopping:
+			p.drop(job)
+			return false
+		}
+	case QueuePolicyDropOldest:
+		for {
+			select {
+			case p.queue <- job:
+				return true
+			default:
+			}
+			select {
+			case oldest := <-p.queue:
+				p.drop(oldest)
+			default:
+			}
+		}
+	default:
+		p.drop(job)
+		return false
+	}
+}
+
+// Close 停止接收新任务，worker 执行完队列中剩余的任务后退出
+func (p *WorkerPool) Close() {
+	p.stopOnce.Do(func() { close(p.stopping) })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.queue)
+}
+
+// Wait 等待全部 worker 退出
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// Stats 返回当前的积压情况
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	stats := WorkerPoolStats{
+		Queued:    len(p.queue),
+		Capacity:  cap(p.queue),
+		Busy:      p.busy.Load(),
+		Submitted: p.submitted.Load(),
+		Completed: p.completed.Load(),
+		Dropped:   p.dropped.Load(),
+		MaxWait:   time.Duration(p.maxWait.Load()),
+	}
+	if started := p.started.Load(); started > 0 {
+		stats.AvgWait = time.Duration(p.totalWait.Load() / int64(started))
+	}
+	return stats
+}
+
+// ReportStats 定期把队列积压情况写入日志，直到 ctx 结束
+func (p *WorkerPool) ReportStats(ctx context.Context) {
+	ticker := time.NewTicker(queueStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s := p.Stats()
+			p.logger.Info("任务队列统计", "queued", s.Queued, "capacity", s.Capacity, "busy", s.Busy,
+				"submitted", s.Submitted, "completed", s.Completed, "dropped", s.Dropped,
+				"avg_wait", s.AvgWait.Round(time.Millisecond), "max_wait", s.MaxWait.Round(time.Millisecond))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	defer p.wg.Done()
+	for job := range p.queue {
+		wait := int64(time.Since(job.enqueuedAt))
+		p.started.Add(1)
+		p.totalWait.Add(wait)
+		for {
+			max := p.maxWait.Load()
+			if wait <= max || p.maxWait.CompareAndSwap(max, wait) {
+				break
+			}
+		}
+
+		p.busy.Add(1)
+		job.Run(ctx)
+		p.busy.Add(-1)
+		p.completed.Add(1)
+	}
+}
+
+func (p *WorkerPool) drop(job Job) {
+	p.dropped.Add(1)
+	p.logger.Warn("任务队列已满，丢弃任务", "capacity", cap(p.queue), "job", job.Name)
+}
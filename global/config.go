@@ -1,13 +1,37 @@
 package global
 
 import (
+	"sync/atomic"
+
 	"github.com/gagliardetto/solana-go/rpc"
-	"github.com/redis/go-redis/v9"
 	"meme/core"
 )
 
 var (
-	Redis        *redis.Client
+	Redis        *core.RedisClient
 	RpcClient    *rpc.Client
-	SystemConfig core.SystemConfig
+	RpcPool      *core.RPCPool
+	Cache        *core.Cache
+	Store        *core.TradeStore
+	StoreConfig  core.StoreConfig
+	RedisConfig  core.RedisConfig
+	Recorder     *core.Recorder
+	Alerter      *core.Alerter
+	FollowConfig core.FollowConfig
 )
+
+// systemConfig 在热更新时整体替换，工作池中的协程可以同时读取
+var systemConfig atomic.Pointer[core.SystemConfig]
+
+// SystemConfig 返回当前的 system 配置
+func SystemConfig() core.SystemConfig {
+	if config := systemConfig.Load(); config != nil {
+		return *config
+	}
+	return core.SystemConfig{}
+}
+
+// SetSystemConfig 替换 system 配置，调用后不能再修改传入配置中的切片
+func SetSystemConfig(config core.SystemConfig) {
+	systemConfig.Store(&config)
+}
@@ -1,26 +1,36 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/gagliardetto/solana-go"
-	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 
 	"log"
+	"log/slog"
 	"meme/core"
 	"meme/global"
 	"meme/service"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 const (
-	SolanaWebSocketURL = "wss://api.mainnet-beta.solana.com"
-	PingInterval       = 5 * time.Second
-	ReconnectInterval  = 5 * time.Second
+	PingInterval         = 5 * time.Second
+	ReconnectMinInterval = 500 * time.Millisecond // 首次重连前的等待，之后每次翻倍，订阅成功后重置
+	ReconnectMaxInterval = 30 * time.Second       // 重连等待的上限
+	UnsubscribeTimeout   = 3 * time.Second        // 停止时等待取消订阅响应的时间
+	ShutdownTimeout      = 30 * time.Second       // 停止时等待正在处理的交易完成的时间
+
 )
 
 // RPCRequest 表示 RPC 请求格式
@@ -51,46 +61,68 @@ type Notification struct {
 
 // SafeWebSocket 封装了线程安全的 WebSocket 连接
 type SafeWebSocket struct {
-	conn    *websocket.Conn
-	mu      sync.Mutex
-	writeCh chan []byte
-	stopCh  chan struct{}
-	logger  *log.Logger
+	conn     *websocket.Conn
+	mu       sync.Mutex
+	queueMu  sync.Mutex
+	queue    [][]byte      // 待写入的消息，不限长度，SendMessage 不会阻塞
+	notify   chan struct{} // 有新消息时唤醒写入循环
+	stopCh   chan struct{}
+	logger   *slog.Logger
+	lastPong atomic.Int64 // 最近一次收到 Pong 的时间 (UnixNano)，连接建立时视为已收到
 }
 
 // NewSafeWebSocket 创建一个新的线程安全 WebSocket 连接
-func NewSafeWebSocket(conn *websocket.Conn, logger *log.Logger) *SafeWebSocket {
+func NewSafeWebSocket(conn *websocket.Conn, logger *slog.Logger) *SafeWebSocket {
 	ws := &SafeWebSocket{
-		conn:    conn,
-		writeCh: make(chan []byte, 100),
-		stopCh:  make(chan struct{}),
-		logger:  logger,
+		conn:   conn,
+		notify: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+		logger: logger,
 	}
+	ws.lastPong.Store(time.Now().UnixNano())
+	// Pong 由读取循环中的 ReadMessage 处理
+	conn.SetPongHandler(func(string) error {
+		ws.lastPong.Store(time.Now().UnixNano())
+		return nil
+	})
 	go ws.writeLoop()
 	return ws
 }
 
-// writeLoop 持续处理写入操作
+// writeLoop 按顺序写入队列中的消息
 func (ws *SafeWebSocket) writeLoop() {
 	for {
 		select {
-		case msg := <-ws.writeCh:
+		case <-ws.notify:
+		case <-ws.stopCh:
+			return
+		}
+		ws.queueMu.Lock()
+		messages := ws.queue
+		ws.queue = nil
+		ws.queueMu.Unlock()
+
+		for _, msg := range messages {
 			ws.mu.Lock()
 			err := ws.conn.WriteMessage(websocket.TextMessage, msg)
 			ws.mu.Unlock()
 			if err != nil {
-				ws.logger.Printf("WebSocket 写入失败: %v", err)
+				ws.logger.Warn("WebSocket 写入失败", core.LogKeyError, err)
 				return
 			}
-		case <-ws.stopCh:
-			return
 		}
 	}
 }
 
-// SendMessage 发送消息到 WebSocket
+// SendMessage 把消息放入写入队列后立即返回，调用方持有锁时也可以安全调用
 func (ws *SafeWebSocket) SendMessage(msg []byte) {
-	ws.writeCh <- msg
+	ws.queueMu.Lock()
+	ws.queue = append(ws.queue, msg)
+	ws.queueMu.Unlock()
+	select {
+	case ws.notify <- struct{}{}:
+	default:
+	}
 }
 
 // Close 关闭 WebSocket
@@ -99,135 +131,24 @@ func (ws *SafeWebSocket) Close() {
 	ws.conn.Close()
 }
 
-// connect 建立 WebSocket 连接
-func connect() (*websocket.Conn, error) {
-	conn, _, err := websocket.DefaultDialer.Dial(SolanaWebSocketURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	return conn, nil
-}
-
-// createLogger 创建日志记录器
-func createLogger(address string) (*log.Logger, *os.File, error) {
-	logDir := "logs"
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, nil, fmt.Errorf("创建日志目录失败: %w", err)
-	}
-
-	filePath := fmt.Sprintf("%s/%s.log", logDir, address)
-	logFile, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return nil, nil, fmt.Errorf("打开日志文件失败: %w", err)
-	}
-
-	logger := log.New(logFile, "", log.LstdFlags)
-	return logger, logFile, nil
-}
-
-// subscribeToLogs 订阅日志
-func subscribeToLogs(ws *SafeWebSocket, address string) {
-	params := []interface{}{
-		map[string]interface{}{
-			"mentions": []string{address},
-		},
-		map[string]interface{}{
-			"commitment": "confirmed",
-		},
-	}
-
-	subscribeReq := RPCRequest{
-		Jsonrpc: "2.0",
-		Id:      1,
-		Method:  "logsSubscribe",
-		Params:  params,
-	}
-
-	subscribeReqBytes, err := json.Marshal(subscribeReq)
-	if err != nil {
-		ws.logger.Printf("订阅请求序列化失败: %v", err)
-		return
-	}
-
-	ws.SendMessage(subscribeReqBytes)
-	ws.logger.Printf("订阅请求发送成功: %s", string(subscribeReqBytes))
-
-	handleMessages(ws, address)
-}
-
-// handleMessages 处理 WebSocket 消息
-func handleMessages(ws *SafeWebSocket, address string) {
-	for {
-		_, msg, err := ws.conn.ReadMessage()
-		if err != nil {
-			ws.logger.Printf("读取消息失败: %v", err)
-			ws.logger.Printf("尝试重连...")
-			reconnect(ws, address)
-			return
+// connect 按节点池顺序建立 WebSocket 连接，失败时尝试下一个节点
+func connect(ctx context.Context, logger *slog.Logger) (*websocket.Conn, error) {
+	var errs []error
+	for _, endpoint := range global.RpcPool.WSEndpoints() {
+		header := http.Header{}
+		for name, value := range endpoint.Headers {
+			header.Set(name, value)
 		}
-
-		ws.logger.Printf("收到消息: %s", string(msg))
-
-		var notification Notification
-		err = json.Unmarshal(msg, &notification)
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, endpoint.WSURL, header)
 		if err != nil {
-			ws.logger.Printf("消息解析失败: %v", err)
+			logger.Warn("连接 WebSocket 节点失败", "endpoint", endpoint.Name, core.LogKeyError, err)
+			errs = append(errs, err)
 			continue
 		}
-
-		if notification.Method == "logsNotification" {
-			signature := notification.Params.Result.Value.Signature
-			ws.logger.Printf("交易签名: %s", signature)
-
-			transactionLogs, err := service.NewTransactionService(ws.logger).GetTransactionLogs(address, signature)
-			if err != nil {
-				ws.logger.Printf("获取交易日志失败: %v", err)
-				continue
-			}
-			transactionLogsJson, err := json.Marshal(transactionLogs)
-			if err != nil {
-				ws.logger.Printf("解析后交易原始日志: %v", transactionLogs)
-				ws.logger.Printf("解析后交易日志JSON序列化失败: %v", err)
-			} else {
-				ws.logger.Printf("解析后交易JSON日志: %s", transactionLogsJson)
-			}
-
-			if notification.Params.Result.Value.Err != nil {
-				ws.logger.Printf("交易失败: %v", notification.Params.Result.Value.Err)
-			} else {
-				ws.logger.Printf("交易成功")
-			}
-		}
-	}
-}
-
-// subscribeToSolanaLogs 订阅多个地址
-func subscribeToSolanaLogs(addresses []string) {
-	for _, address := range addresses {
-		go func(address string) {
-			logger, logFile, err := createLogger(address)
-			if err != nil {
-				fmt.Printf("初始化日志失败: %v", err)
-				return
-			}
-			defer logFile.Close()
-
-			for {
-				conn, err := connect()
-				if err != nil {
-					logger.Printf("WebSocket 连接失败，重试中: %v", err)
-					time.Sleep(ReconnectInterval)
-					continue
-				}
-
-				logger.Printf("WebSocket 连接成功")
-				ws := NewSafeWebSocket(conn, logger)
-				defer ws.Close()
-				go startPing(ws)
-				subscribeToLogs(ws, address)
-			}
-		}(address)
+		logger.Info("已连接 WebSocket 节点", "endpoint", endpoint.Name)
+		return conn, nil
 	}
+	return nil, errors.Join(errs...)
 }
 
 func startPing(ws *SafeWebSocket) {
@@ -241,72 +162,193 @@ func startPing(ws *SafeWebSocket) {
 			err := ws.conn.WriteMessage(websocket.PingMessage, nil)
 			ws.mu.Unlock()
 			if err != nil {
-				ws.logger.Printf("发送 Ping 消息失败: %v", err)
+				ws.logger.Warn("发送 Ping 消息失败", core.LogKeyError, err)
 				return
 			}
 		case <-ws.stopCh:
-			ws.logger.Println("心跳机制停止")
+			ws.logger.Debug("心跳机制停止")
 			return
 		}
 	}
 }
 
-func reconnect(ws *SafeWebSocket, address string) {
-	for {
-		conn, err := connect()
-		if err != nil {
-			ws.logger.Printf("WebSocket 重新连接失败，重试中: %v", err)
-			time.Sleep(ReconnectInterval)
-			continue
-		}
-
-		ws.logger.Printf("WebSocket 重新连接成功")
-		ws.conn = conn
-		go startPing(ws)
-		subscribeToLogs(ws, address)
-		return
-	}
+// startExitWorker 启动跟单持仓的止盈止损任务，任务结束时关闭返回的 channel
+func startExitWorker(ctx context.Context, logger *slog.Logger) <-chan struct{} {
+	done := make(chan struct{})
+	follow := service.NewFollowTransactionService(global.RpcClient, logger)
+	go func() {
+		defer close(done)
+		service.NewExitWorker(follow, logger).Run(ctx)
+	}()
+	return done
 }
 
 func main() {
-	global.SystemConfig = core.InitSystemConfig()
-	var addresses []string
-	selfAddress := global.SystemConfig.SelfAddress
-	if selfAddress != "" {
-		addresses = append(addresses, solana.MustPublicKeyFromBase58(selfAddress).String())
-	}
-	monitorAddress := global.SystemConfig.MonitorAddress
-	if monitorAddress != "" {
-		addresses = append(addresses, solana.MustPublicKeyFromBase58(monitorAddress).String())
-	}
+	var configPath string
+	var config core.Config
 
 	var rootCmd = &cobra.Command{
-		Use: "main",
+		Use:           "main",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// 所有子命令执行前统一加载并校验配置
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			config, err = core.LoadConfig(configPath, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			global.SetSystemConfig(config.SystemConfig)
+			global.FollowConfig = config.FollowConfig
+			global.StoreConfig = config.Store
+			global.RedisConfig = config.Redis
+
+			// 所有 RPC 调用共用同一个节点池
+			global.RpcClient, global.RpcPool = core.InitRPC(config.RPC, core.NewConsoleLogger(slog.LevelInfo).With(core.LogKeyComponent, "rpc"))
+			// 子命令未连接 Redis，只使用进程内缓存
+			global.Cache = core.NewCache(config.Cache, nil, nil)
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(addresses) == 0 {
+			ctx := cmd.Context()
+			logger, logCloser, err := core.NewLogger(config.Log)
+			if err != nil {
+				fmt.Printf("初始化日志失败: %v\n", err)
+				os.Exit(1)
+			}
+			defer logCloser.Close()
+			wallets := global.SystemConfig().WatchList()
+			if len(wallets) == 0 {
 				fmt.Println("请配置监控地址")
 				os.Exit(1)
 			}
-			// 初始化 Redis
-			global.Redis = core.InitRedis()
-			fmt.Printf("Redis 连接成功: %v\n", global.Redis)
+			// 初始化 Redis，连接失败且未要求必须可用时降级运行
+			redisLogger := logger.With(core.LogKeyComponent, "redis")
+			global.Redis, err = core.InitRedis(config.Redis, redisLogger)
+			switch {
+			case global.Redis == nil:
+				fmt.Printf("初始化 Redis 失败: %v\n", err)
+				os.Exit(1)
+			case err != nil && config.Redis.Required:
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			case err != nil:
+				fmt.Printf("%v\n降级运行: 跟单持仓、拒单记录、缓存共享与 Redis 监控列表暂不可用，恢复连接后自动启用\n", err)
+				redisLogger.Warn("Redis 不可用，进入降级模式", core.LogKeyError, err)
+			default:
+				fmt.Println("Redis 连接成功")
+			}
+			go global.Redis.RunHealthCheck(ctx)
+			defer global.Redis.Close()
+
+			// 初始化精度、元数据、交易与池子缓存
+			global.Cache = core.NewCache(config.Cache, global.Redis, logger.With(core.LogKeyComponent, "cache"))
+			go global.Cache.ReportStats(ctx)
+
+			// 使用正式日志重建节点池并启动健康检查，先关闭 PersistentPreRunE 创建的节点池
+			global.RpcClient.Close()
+			global.RpcClient, global.RpcPool = core.InitRPC(config.RPC, logger.With(core.LogKeyComponent, "rpc"))
+			go global.RpcPool.RunHealthCheck(ctx)
+			defer global.RpcClient.Close()
+			fmt.Printf("RPC 节点池初始化成功: %d 个节点\n", len(config.RPC.EndpointList()))
+
+			// 录制收到的通知与获取到的交易，供 replay 子命令回放
+			if config.Record.File != "" {
+				global.Recorder, err = core.NewRecorder(config.Record.File, logger.With(core.LogKeyComponent, "recorder"))
+				if err != nil {
+					fmt.Printf("初始化录制失败: %v\n", err)
+					os.Exit(1)
+				}
+				defer global.Recorder.Close()
+				fmt.Printf("录制通知与交易到: %s\n", config.Record.File)
+			}
+
+			// 按各地址的 alerts 发送交易告警
+			global.Alerter = core.NewAlerter(config.Alert, logger.With(core.LogKeyComponent, "alert"))
+
+			// 打开交易存储，未配置时不保存交易
+			if config.Store.Driver != "" {
+				global.Store, err = core.OpenStore(ctx, config.Store, logger.With(core.LogKeyComponent, "store"))
+				if err != nil {
+					fmt.Printf("初始化交易存储失败: %v\n", err)
+					os.Exit(1)
+				}
+				defer global.Store.Close()
+				fmt.Printf("交易存储初始化成功: %s\n", config.Store.Driver)
+			}
 
-			// 初始化 Solana RPC 客户端
-			global.RpcClient = rpc.New(rpc.MainNetBeta_RPC)
-			fmt.Printf("RPC 客户端初始化成功: %v\n", global.RpcClient)
+			// 启动持仓止盈止损任务
+			var exitDone <-chan struct{}
+			if global.FollowConfig.Exit.Enabled {
+				exitDone = startExitWorker(ctx, logger.With(core.LogKeyComponent, "exit"))
+			}
 
-			// 启动 Solana WebSocket 订阅
+			// 启动 Solana WebSocket 订阅，阻塞主线程
 			fmt.Println("启动 Solana WebSocket 订阅...")
-			subscribeToSolanaLogs(addresses)
+			monitor := NewMonitor(configPath, cmd.Flags(), config.Queue, logger)
 
-			// 阻塞主线程
-			select {}
+			// 启动健康检查服务，配置 store 时同时提供盈亏接口；配置 metrics.listen 时另启动 Prometheus 指标服务，两者地址相同时共用
+			ready := func(ctx context.Context) []core.CheckResult {
+				checks := []core.CheckResult{global.Redis.ReadyCheck(config.Redis.Required), global.RpcPool.ReadyCheck()}
+				return append(checks, monitor.ReadyChecks(config.Health)...)
+			}
+			health := core.HealthHandlers(ready)
+			if global.Store != nil {
+				health["/pnl"] = service.NewPnLService(global.Store, service.NewJupiterSwapBuilder(global.RpcClient, logger), logger.With(core.LogKeyComponent, "pnl"))
+			}
+			statusServers := map[string]map[string]http.Handler{config.Health.ListenAddr(): health}
+			if config.Metrics.Listen != "" {
+				handlers, ok := statusServers[config.Metrics.Listen]
+				if !ok {
+					handlers = core.HealthHandlers(ready)
+					statusServers[config.Metrics.Listen] = handlers
+				}
+				handlers["/metrics"] = promhttp.Handler()
+			}
+			for listen, handlers := range statusServers {
+				go func() {
+					if err := core.ServeStatus(ctx, listen, handlers); err != nil {
+						fmt.Printf("状态服务 %s 启动失败: %v\n", listen, err)
+					}
+				}()
+				paths := make([]string, 0, len(handlers))
+				for path := range handlers {
+					paths = append(paths, path)
+				}
+				sort.Strings(paths)
+				fmt.Printf("状态服务监听: %s (%s)\n", listen, strings.Join(paths, ", "))
+			}
+
+			monitor.Run(ctx)
+
+			// 等待止盈止损任务完成当前检查
+			if exitDone != nil {
+				select {
+				case <-exitDone:
+				case <-time.After(ShutdownTimeout):
+					fmt.Println("等待持仓退出任务超时")
+				}
+			}
+			fmt.Println("监控已停止")
 		},
 	}
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", core.DefaultConfigPath, "配置文件路径")
+	core.RegisterFlags(rootCmd.PersistentFlags())
 
 	rootCmd.AddCommand(service.BalanceCmd)
 	rootCmd.AddCommand(service.TokenCmd)
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.AddCommand(service.ConfigCmd)
+	rootCmd.AddCommand(service.TradesCmd)
+	rootCmd.AddCommand(service.HistoryCmd)
+	rootCmd.AddCommand(service.PnLCmd)
+	rootCmd.AddCommand(service.DiscoverCmd)
+	rootCmd.AddCommand(service.MockCmd)
+	rootCmd.AddCommand(replayCmd)
+	// 收到 SIGINT/SIGTERM 时取消根 context，各任务据此优雅停止
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
 		log.Fatal(err)
 	}
 }
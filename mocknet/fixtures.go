@@ -0,0 +1,276 @@
+// Package mocknet 提供进程内的模拟 Solana RPC 与 WebSocket 节点，
+// 从交易样本与手工登记的账户数据应答请求，并可按脚本推送通知、断开连接和返回限流错误，
+// 用于在不访问主网的情况下端到端运行监控流程
+package mocknet
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// tokenAccountSize SPL Token 账户数据长度
+const tokenAccountSize = 165
+
+// Account 表示一个登记的账户
+type Account struct {
+	Owner      solana.PublicKey
+	Lamports   uint64
+	Data       []byte
+	Executable bool
+}
+
+// TokenAccount 表示 owner 持有的一个代币账户
+type TokenAccount struct {
+	Pubkey solana.PublicKey
+	Mint   solana.PublicKey
+	Amount uint64
+}
+
+// TokenSupply 表示代币的总供应量
+type TokenSupply struct {
+	Amount   uint64
+	Decimals uint8
+}
+
+// transactionFixture 表示一笔登记的交易
+type transactionFixture struct {
+	data      json.RawMessage
+	slot      uint64
+	blockTime *solana.UnixTimeSeconds
+	failed    bool
+	accounts  map[string]bool // 交易涉及的账户，用于 getSignaturesForAddress
+}
+
+// Fixtures 保存模拟节点应答使用的数据，可在节点运行期间修改
+type Fixtures struct {
+	mu            sync.RWMutex
+	transactions  map[string]transactionFixture
+	order         []string        // 按登记顺序排列的交易签名
+	template      json.RawMessage // 未登记的签名返回该交易，签名替换为请求的签名
+	balances      map[string]uint64
+	accounts      map[string]Account
+	tokenAccounts map[string][]TokenAccount // owner -> 代币账户
+	supplies      map[string]TokenSupply
+}
+
+func NewFixtures() *Fixtures {
+	return &Fixtures{
+		transactions:  make(map[string]transactionFixture),
+		balances:      make(map[string]uint64),
+		accounts:      make(map[string]Account),
+		tokenAccounts: make(map[string][]TokenAccount),
+		supplies:      make(map[string]TokenSupply),
+	}
+}
+
+// AddTransaction 登记 getTransaction 的返回结果，返回交易的第一个签名
+func (f *Fixtures) AddTransaction(data []byte) (string, error) {
+	var tx rpc.GetTransactionResult
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return "", fmt.Errorf("交易解析失败: %w", err)
+	}
+	parsed, err := tx.Transaction.GetTransaction()
+	if err != nil {
+		return "", fmt.Errorf("交易解码失败: %w", err)
+	}
+	if len(parsed.Signatures) == 0 {
+		return "", fmt.Errorf("交易缺少签名")
+	}
+	signature := parsed.Signatures[0].String()
+	fixture := transactionFixture{
+		data:      json.RawMessage(data),
+		slot:      tx.Slot,
+		blockTime: tx.BlockTime,
+		failed:    tx.Meta != nil && tx.Meta.Err != nil,
+		accounts:  make(map[string]bool),
+	}
+	for _, key := range parsed.Message.AccountKeys {
+		fixture.accounts[key.String()] = true
+	}
+	if tx.Meta != nil {
+		for _, key := range tx.Meta.LoadedAddresses.Writable {
+			fixture.accounts[key.String()] = true
+		}
+		for _, key := range tx.Meta.LoadedAddresses.ReadOnly {
+			fixture.accounts[key.String()] = true
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.transactions[signature]; !ok {
+		f.order = append(f.order, signature)
+	}
+	f.transactions[signature] = fixture
+	return signature, nil
+}
+
+// LoadTransactions 登记文件或目录下全部 JSON 文件中的交易，返回按文件名排序的签名
+func (f *Fixtures) LoadTransactions(path string) ([]string, error) {
+	paths := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+	}
+	var signatures []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := f.AddTransaction(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// SetTemplate 设置未登记签名时返回的交易，nil 表示返回 null
+func (f *Fixtures) SetTemplate(data []byte) error {
+	if data != nil && !json.Valid(data) {
+		return fmt.Errorf("模板交易不是有效的 JSON")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.template = data
+	return nil
+}
+
+// Signatures 返回已登记交易的签名，按登记顺序排列
+func (f *Fixtures) Signatures() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]string(nil), f.order...)
+}
+
+// Mentions 判断已登记的交易是否涉及 address
+func (f *Fixtures) Mentions(signature, address string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.transactions[signature].accounts[address]
+}
+
+// SetBalance 设置 getBalance 返回的 lamports
+func (f *Fixtures) SetBalance(address solana.PublicKey, lamports uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[address.String()] = lamports
+}
+
+// SetAccount 登记 getAccountInfo 返回的账户
+func (f *Fixtures) SetAccount(address solana.PublicKey, account Account) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[address.String()] = account
+}
+
+// AddTokenAccount 为 owner 登记一个代币账户，同时登记为可通过 getAccountInfo 查询的账户
+func (f *Fixtures) AddTokenAccount(owner solana.PublicKey, account TokenAccount) {
+	data := make([]byte, tokenAccountSize)
+	copy(data[0:32], account.Mint.Bytes())
+	copy(data[32:64], owner.Bytes())
+	binary.LittleEndian.PutUint64(data[64:72], account.Amount)
+	data[108] = 1 // 已初始化
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokenAccounts[owner.String()] = append(f.tokenAccounts[owner.String()], account)
+	f.accounts[account.Pubkey.String()] = Account{Owner: solana.TokenProgramID, Lamports: 2039280, Data: data}
+}
+
+// SetTokenSupply 登记 getTokenSupply 返回的供应量
+func (f *Fixtures) SetTokenSupply(mint solana.PublicKey, supply TokenSupply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.supplies[mint.String()] = supply
+}
+
+// transaction 返回签名对应的交易，未登记时按模板生成，都没有时返回 nil
+func (f *Fixtures) transaction(signature string) (json.RawMessage, error) {
+	f.mu.RLock()
+	fixture, ok := f.transactions[signature]
+	template := f.template
+	f.mu.RUnlock()
+	if ok {
+		return fixture.data, nil
+	}
+	if template == nil {
+		return nil, nil
+	}
+
+	// 仅 JSON 编码的交易可以替换签名，其他编码原样返回
+	var tx map[string]interface{}
+	if err := json.Unmarshal(template, &tx); err != nil {
+		return nil, err
+	}
+	if body, ok := tx["transaction"].(map[string]interface{}); ok {
+		if signatures, ok := body["signatures"].([]interface{}); ok && len(signatures) > 0 {
+			signatures[0] = signature
+		}
+	}
+	return json.Marshal(tx)
+}
+
+// signaturesFor 返回涉及 address 的交易，按 slot 从新到旧排列
+func (f *Fixtures) signaturesFor(address string) []rpc.TransactionSignature {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var signatures []rpc.TransactionSignature
+	for _, signature := range f.order {
+		fixture := f.transactions[signature]
+		if !fixture.accounts[address] {
+			continue
+		}
+		entry := rpc.TransactionSignature{
+			Signature: solana.MustSignatureFromBase58(signature),
+			Slot:      fixture.slot,
+			BlockTime: fixture.blockTime,
+		}
+		if fixture.failed {
+			entry.Err = map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}
+		}
+		signatures = append(signatures, entry)
+	}
+	sort.SliceStable(signatures, func(i, j int) bool { return signatures[i].Slot > signatures[j].Slot })
+	return signatures
+}
+
+func (f *Fixtures) balance(address string) uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.balances[address]
+}
+
+func (f *Fixtures) account(address string) (Account, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	account, ok := f.accounts[address]
+	return account, ok
+}
+
+func (f *Fixtures) ownerTokenAccounts(owner string) []TokenAccount {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]TokenAccount(nil), f.tokenAccounts[owner]...)
+}
+
+func (f *Fixtures) supply(mint string) (TokenSupply, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	supply, ok := f.supplies[mint]
+	return supply, ok
+}
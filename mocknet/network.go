@@ -0,0 +1,60 @@
+package mocknet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"meme/core"
+)
+
+// Network 组合模拟的 RPC 与 WebSocket 节点，共享同一份 Fixtures
+type Network struct {
+	Fixtures *Fixtures
+	RPC      *RPCServer
+	WS       *WSServer
+}
+
+// Start 启动 RPC 与 WebSocket 节点，地址为空时监听随机端口
+func Start(fixtures *Fixtures, rpcAddr, wsAddr string) (*Network, error) {
+	if fixtures == nil {
+		fixtures = NewFixtures()
+	}
+	rpcServer, err := NewRPCServer(fixtures, rpcAddr)
+	if err != nil {
+		return nil, err
+	}
+	wsServer, err := NewWSServer(wsAddr)
+	if err != nil {
+		rpcServer.Close()
+		return nil, err
+	}
+	return &Network{Fixtures: fixtures, RPC: rpcServer, WS: wsServer}, nil
+}
+
+// Endpoint 返回指向模拟节点的 rpc.endpoints 配置
+func (n *Network) Endpoint() core.EndpointConfig {
+	return core.EndpointConfig{Name: "mocknet", URL: n.RPC.URL(), WSURL: n.WS.URL()}
+}
+
+// Close 关闭全部节点
+func (n *Network) Close() {
+	n.WS.Close()
+	n.RPC.Close()
+}
+
+// startServer 在 addr 上启动 HTTP 服务，addr 为空时监听 127.0.0.1 的随机端口
+func startServer(addr string, handler http.Handler) (*httptest.Server, error) {
+	if addr == "" {
+		return httptest.NewServer(handler), nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	return server, nil
+}
@@ -0,0 +1,364 @@
+package mocknet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// JSON-RPC 错误码
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInvalidRequest = -32600
+	codeInternalError  = -32603
+)
+
+type rpcRequest struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// RPCServer 是模拟的 Solana JSON-RPC HTTP 节点，支持批量请求
+type RPCServer struct {
+	server   *httptest.Server
+	fixtures *Fixtures
+	slot     atomic.Uint64
+
+	mu         sync.Mutex
+	calls      map[string]int
+	rateLimits int            // 剩余需要返回 429 的 HTTP 请求数
+	retryAfter time.Duration  // 429 响应的 Retry-After
+	failures   map[string]int // 各方法剩余需要返回内部错误的请求数
+}
+
+// NewRPCServer 在 addr 上启动 RPC 节点，addr 为空时监听随机端口
+func NewRPCServer(fixtures *Fixtures, addr string) (*RPCServer, error) {
+	s := &RPCServer{fixtures: fixtures, calls: make(map[string]int), failures: make(map[string]int)}
+	s.slot.Store(1)
+	server, err := startServer(addr, http.HandlerFunc(s.serveHTTP))
+	if err != nil {
+		return nil, err
+	}
+	s.server = server
+	return s, nil
+}
+
+// URL 返回节点的 HTTP 地址
+func (s *RPCServer) URL() string {
+	return s.server.URL
+}
+
+// Close 关闭节点
+func (s *RPCServer) Close() {
+	s.server.Close()
+}
+
+// RateLimit 使接下来的 n 个 HTTP 请求返回 429，retryAfter 大于 0 时附带 Retry-After 响应头
+func (s *RPCServer) RateLimit(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimits = n
+	s.retryAfter = retryAfter
+}
+
+// Fail 使接下来 n 个 method 请求返回 JSON-RPC 内部错误，批量请求中的每个请求单独计数
+func (s *RPCServer) Fail(method string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = n
+}
+
+// Calls 返回 method 被调用的次数，批量请求中的每个请求单独计数，限流的请求不计数
+func (s *RPCServer) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// SetSlot 设置响应 context 中的 slot
+func (s *RPCServer) SetSlot(slot uint64) {
+	s.slot.Store(slot)
+}
+
+// takeRateLimit 判断当前请求是否需要限流
+func (s *RPCServer) takeRateLimit() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rateLimits <= 0 {
+		return 0, false
+	}
+	s.rateLimits--
+	return s.retryAfter, true
+}
+
+func (s *RPCServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if retryAfter, ok := s.takeRateLimit(); ok {
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+		}
+		writeJSON(w, http.StatusTooManyRequests, rpcResponse{Jsonrpc: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: http.StatusTooManyRequests, Message: "Too many requests"}})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var requests []rpcRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			writeJSON(w, http.StatusOK, invalidRequest(err))
+			return
+		}
+		responses := make([]rpcResponse, 0, len(requests))
+		for _, req := range requests {
+			responses = append(responses, s.handle(req))
+		}
+		writeJSON(w, http.StatusOK, responses)
+		return
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusOK, invalidRequest(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.handle(req))
+}
+
+// handle 处理单个请求
+func (s *RPCServer) handle(req rpcRequest) rpcResponse {
+	s.mu.Lock()
+	s.calls[req.Method]++
+	fail := s.failures[req.Method] > 0
+	if fail {
+		s.failures[req.Method]--
+	}
+	s.mu.Unlock()
+
+	resp := rpcResponse{Jsonrpc: "2.0", ID: req.ID}
+	if fail {
+		resp.Error = &rpcError{Code: codeInternalError, Message: "Internal error"}
+		return resp
+	}
+	result, err := s.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+		return resp
+	}
+	if result == nil {
+		// 保留 null 结果，例如未找到的交易
+		result = json.RawMessage("null")
+	}
+	resp.Result = result
+	return resp
+}
+
+func (s *RPCServer) call(method string, params []json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "getHealth":
+		return rpc.HealthOk, nil
+	case "getSlot":
+		return s.slot.Load(), nil
+	case "getTransaction":
+		var signature string
+		if err := param(params, 0, &signature); err != nil {
+			return nil, err
+		}
+		tx, err := s.fixtures.transaction(signature)
+		if err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		if tx == nil {
+			return nil, nil
+		}
+		return tx, nil
+	case "getSignaturesForAddress":
+		return s.getSignaturesForAddress(params)
+	case "getBalance":
+		var address string
+		if err := param(params, 0, &address); err != nil {
+			return nil, err
+		}
+		return s.withContext(s.fixtures.balance(address)), nil
+	case "getAccountInfo":
+		var address string
+		if err := param(params, 0, &address); err != nil {
+			return nil, err
+		}
+		return s.withContext(s.accountValue(address)), nil
+	case "getMultipleAccounts":
+		var addresses []string
+		if err := param(params, 0, &addresses); err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(addresses))
+		for _, address := range addresses {
+			values = append(values, s.accountValue(address))
+		}
+		return s.withContext(values), nil
+	case "getTokenAccountsByOwner":
+		return s.getTokenAccountsByOwner(params)
+	case "getTokenSupply":
+		var mint string
+		if err := param(params, 0, &mint); err != nil {
+			return nil, err
+		}
+		supply, ok := s.fixtures.supply(mint)
+		if !ok {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "Invalid param: could not find mint"}
+		}
+		ui := float64(supply.Amount)
+		for i := uint8(0); i < supply.Decimals; i++ {
+			ui /= 10
+		}
+		return s.withContext(map[string]interface{}{
+			"amount":         strconv.FormatUint(supply.Amount, 10),
+			"decimals":       supply.Decimals,
+			"uiAmount":       ui,
+			"uiAmountString": strconv.FormatFloat(ui, 'f', -1, 64),
+		}), nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("Method not found: %s", method)}
+}
+
+func (s *RPCServer) getSignaturesForAddress(params []json.RawMessage) (interface{}, *rpcError) {
+	var address string
+	if err := param(params, 0, &address); err != nil {
+		return nil, err
+	}
+	var opts struct {
+		Limit  int    `json:"limit"`
+		Before string `json:"before"`
+		Until  string `json:"until"`
+	}
+	if len(params) > 1 {
+		if err := param(params, 1, &opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Limit <= 0 || opts.Limit > 1000 {
+		opts.Limit = 1000
+	}
+
+	all := s.fixtures.signaturesFor(address)
+	result := make([]rpc.TransactionSignature, 0, len(all))
+	started := opts.Before == ""
+	for _, entry := range all {
+		signature := entry.Signature.String()
+		if !started {
+			started = signature == opts.Before
+			continue
+		}
+		if signature == opts.Until || len(result) >= opts.Limit {
+			break
+		}
+		entry.ConfirmationStatus = rpc.ConfirmationStatusFinalized
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func (s *RPCServer) getTokenAccountsByOwner(params []json.RawMessage) (interface{}, *rpcError) {
+	var owner string
+	if err := param(params, 0, &owner); err != nil {
+		return nil, err
+	}
+	var filter struct {
+		Mint      string `json:"mint"`
+		ProgramID string `json:"programId"`
+	}
+	if err := param(params, 1, &filter); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0)
+	for _, account := range s.fixtures.ownerTokenAccounts(owner) {
+		if filter.Mint != "" && filter.Mint != account.Mint.String() {
+			continue
+		}
+		if filter.ProgramID != "" && filter.ProgramID != solana.TokenProgramID.String() {
+			continue
+		}
+		values = append(values, map[string]interface{}{
+			"pubkey":  account.Pubkey.String(),
+			"account": s.accountValue(account.Pubkey.String()),
+		})
+	}
+	return s.withContext(values), nil
+}
+
+// accountValue 返回 base64 编码的账户，未登记时返回 nil
+func (s *RPCServer) accountValue(address string) interface{} {
+	account, ok := s.fixtures.account(address)
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"data":       []string{base64.StdEncoding.EncodeToString(account.Data), "base64"},
+		"executable": account.Executable,
+		"lamports":   account.Lamports,
+		"owner":      account.Owner.String(),
+		"rentEpoch":  0,
+		"space":      len(account.Data),
+	}
+}
+
+// withContext 包装为带 context 的响应结果
+func (s *RPCServer) withContext(value interface{}) interface{} {
+	return map[string]interface{}{
+		"context": map[string]interface{}{"slot": s.slot.Load()},
+		"value":   value,
+	}
+}
+
+// param 解析第 i 个参数
+func param(params []json.RawMessage, i int, out interface{}) *rpcError {
+	if i >= len(params) {
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("缺少第 %d 个参数", i+1)}
+	}
+	if err := json.Unmarshal(params[i], out); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("第 %d 个参数无效: %v", i+1, err)}
+	}
+	return nil
+}
+
+func invalidRequest(err error) rpcResponse {
+	return rpcResponse{Jsonrpc: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeInvalidRequest, Message: err.Error()}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,263 @@
+package mocknet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn 表示一个客户端连接及其订阅
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex     // 保护写入
+	subs map[int]string // 订阅 id -> 地址
+}
+
+func (c *wsConn) write(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// WSServer 是模拟的 Solana WebSocket 节点，应答 logsSubscribe/logsUnsubscribe 并按脚本推送 logsNotification
+type WSServer struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	slot     atomic.Uint64
+
+	mu       sync.Mutex
+	conns    map[*wsConn]bool
+	nextID   int
+	changed  chan struct{} // 订阅变化时关闭并替换，用于 WaitSubscribed
+	connects int
+}
+
+// NewWSServer 在 addr 上启动 WebSocket 节点，addr 为空时监听随机端口
+func NewWSServer(addr string) (*WSServer, error) {
+	s := &WSServer{conns: make(map[*wsConn]bool), changed: make(chan struct{})}
+	s.slot.Store(1)
+	server, err := startServer(addr, http.HandlerFunc(s.serveWS))
+	if err != nil {
+		return nil, err
+	}
+	s.server = server
+	return s, nil
+}
+
+// URL 返回节点的 ws:// 地址
+func (s *WSServer) URL() string {
+	return "ws" + s.server.URL[len("http"):]
+}
+
+// Close 断开全部连接并关闭节点
+func (s *WSServer) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+// Connects 返回累计建立的连接数，可用于确认客户端已重连
+func (s *WSServer) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connects
+}
+
+// Subscribers 返回当前订阅 address 的数量
+func (s *WSServer) Subscribers(address string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribers(address)
+}
+
+func (s *WSServer) subscribers(address string) int {
+	n := 0
+	for c := range s.conns {
+		for _, subscribed := range c.subs {
+			if subscribed == address {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Subscribed 返回当前被订阅的地址
+func (s *WSServer) Subscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var addresses []string
+	for c := range s.conns {
+		for _, address := range c.subs {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// WaitSubscribed 等待 address 被订阅，ctx 结束时返回错误
+func (s *WSServer) WaitSubscribed(ctx context.Context, address string) error {
+	for {
+		s.mu.Lock()
+		n := s.subscribers(address)
+		changed := s.changed
+		s.mu.Unlock()
+		if n > 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Notify 向订阅 address 的全部连接推送 signature 的 logsNotification，txErr 非 nil 时表示交易失败。
+// 返回推送成功的连接数
+func (s *WSServer) Notify(address, signature string, txErr interface{}) int {
+	slot := s.slot.Add(1)
+	s.mu.Lock()
+	type target struct {
+		conn *wsConn
+		id   int
+	}
+	var targets []target
+	for c := range s.conns {
+		for id, subscribed := range c.subs {
+			if subscribed == address {
+				targets = append(targets, target{c, id})
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sent := 0
+	for _, t := range targets {
+		notification := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "logsNotification",
+			"params": map[string]interface{}{
+				"result": map[string]interface{}{
+					"context": map[string]interface{}{"slot": slot},
+					"value": map[string]interface{}{
+						"signature": signature,
+						"err":       txErr,
+						"logs":      []string{},
+					},
+				},
+				"subscription": t.id,
+			},
+		}
+		if t.conn.write(notification) == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// Disconnect 断开全部客户端连接，模拟节点掉线
+func (s *WSServer) Disconnect() {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.conn.Close()
+	}
+}
+
+func (s *WSServer) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &wsConn{conn: conn, subs: make(map[int]string)}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.connects++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.notifyChanged()
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			c.write(invalidRequest(err))
+			continue
+		}
+		c.write(s.handle(c, req))
+	}
+}
+
+// handle 处理订阅请求
+func (s *WSServer) handle(c *wsConn, req rpcRequest) rpcResponse {
+	resp := rpcResponse{Jsonrpc: "2.0", ID: req.ID}
+	switch req.Method {
+	case "logsSubscribe":
+		var filter struct {
+			Mentions []string `json:"mentions"`
+		}
+		if err := param(req.Params, 0, &filter); err != nil {
+			resp.Error = err
+			return resp
+		}
+		if len(filter.Mentions) != 1 {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: "Invalid Request: Only 1 address supported"}
+			return resp
+		}
+		s.mu.Lock()
+		s.nextID++
+		id := s.nextID
+		c.subs[id] = filter.Mentions[0]
+		s.notifyChanged()
+		s.mu.Unlock()
+		resp.Result = id
+	case "logsUnsubscribe":
+		var id int
+		if err := param(req.Params, 0, &id); err != nil {
+			resp.Error = err
+			return resp
+		}
+		s.mu.Lock()
+		_, ok := c.subs[id]
+		delete(c.subs, id)
+		s.notifyChanged()
+		s.mu.Unlock()
+		if !ok {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: "Invalid subscription id."}
+			return resp
+		}
+		resp.Result = true
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: "Method not found: " + req.Method}
+	}
+	return resp
+}
+
+// notifyChanged 唤醒等待订阅变化的调用方，调用方需持有锁
+func (s *WSServer) notifyChanged() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"meme/core"
+	"meme/global"
+	"meme/service"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/pflag"
+)
+
+const (
+	ReloadInterval      = 10 * time.Second
+	methodLogsSubscribe = "logsSubscribe"
+	methodLogsUnsub     = "logsUnsubscribe"
+)
+
+// RPCResponse 表示订阅与取消订阅请求的响应
+type RPCResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// walletSubscription 表示一个地址在当前连接上的订阅状态
+type walletSubscription struct {
+	wallet         core.WalletConfig
+	logger         *slog.Logger // 带 address 与 label 字段
+	subscriptionID int
+	subscribed     bool // 是否已收到订阅确认
+}
+
+// Monitor 在一条 WebSocket 连接上订阅全部监控地址，并支持热更新监控列表
+type Monitor struct {
+	configPath string
+	flags      *pflag.FlagSet
+	logger     *slog.Logger
+
+	mu            sync.Mutex
+	ws            *SafeWebSocket
+	nextID        int
+	wallets       map[string]*walletSubscription // address -> 订阅
+	pending       map[int]string                 // 请求 id -> address
+	subscriptions map[int]string                 // 订阅 id -> address
+
+	configModTime time.Time
+	redisMembers  []string
+	backoff       time.Duration // 下一次重连前的等待，订阅成功后重置
+
+	workers     *core.WorkerPool // 获取与解析交易的工作池，读取循环只负责入队
+	closing     bool             // 正在停止，不再处理新的通知
+	lastMessage atomic.Int64     // 最近一次收到 WebSocket 消息的时间 (UnixNano)
+}
+
+// NewMonitor 创建监控器，queue 为交易处理工作池配置
+func NewMonitor(configPath string, flags *pflag.FlagSet, queue core.QueueConfig, logger *slog.Logger) *Monitor {
+	logger = logger.With(core.LogKeyComponent, "monitor")
+	m := &Monitor{
+		configPath:    configPath,
+		flags:         flags,
+		logger:        logger,
+		wallets:       make(map[string]*walletSubscription),
+		pending:       make(map[int]string),
+		subscriptions: make(map[int]string),
+		workers:       core.NewWorkerPool(queue, logger.With(core.LogKeyComponent, "queue")),
+	}
+	if info, err := os.Stat(configPath); err == nil {
+		m.configModTime = info.ModTime()
+	}
+	return m
+}
+
+// Run 建立连接并订阅全部地址，断线后自动重连并重新订阅，直到 ctx 结束后优雅停止
+func (m *Monitor) Run(ctx context.Context) {
+	// 停止时先等待队列中的交易处理完成，超时后才取消处理
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	m.workers.Start(workCtx)
+	go m.workers.ReportStats(ctx)
+	if err := core.RegisterWorkerPool("transactions", m.workers); err != nil {
+		m.logger.Warn("注册队列指标失败", core.LogKeyError, err)
+	}
+
+	m.redisMembers = m.readRedisWatchList(ctx)
+	m.apply(m.watchList(global.SystemConfig()))
+	go m.watchReload(ctx)
+
+	for {
+		conn, err := connect(ctx, m.logger)
+		if err != nil {
+			wait := m.nextBackoff()
+			m.logger.Warn("WebSocket 连接失败，稍后重试", "wait", wait, core.LogKeyError, err)
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				m.shutdown(nil, nil, cancelWork)
+				return
+			}
+		}
+
+		m.logger.Info("WebSocket 连接成功")
+		core.WSConnected.Set(1)
+		m.lastMessage.Store(time.Now().UnixNano())
+		ws := NewSafeWebSocket(conn, m.logger)
+		go startPing(ws)
+
+		m.mu.Lock()
+		m.ws = ws
+		m.pending = make(map[int]string)
+		m.subscriptions = make(map[int]string)
+		for _, sub := range m.wallets {
+			sub.subscribed = false
+			m.subscribe(sub)
+		}
+		m.mu.Unlock()
+
+		done := make(chan struct{})
+		go func() {
+			handleMessages(m, ws.conn)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			m.shutdown(ws, done, cancelWork)
+			return
+		}
+
+		m.mu.Lock()
+		m.ws = nil
+		m.mu.Unlock()
+		ws.Close()
+		core.WSConnected.Set(0)
+		core.WSReconnects.Inc()
+		wait := m.nextBackoff()
+		m.logger.Info("连接断开，稍后重连", "wait", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			m.shutdown(nil, nil, cancelWork)
+			return
+		}
+	}
+}
+
+// nextBackoff 返回本次重连前的等待时间，并把下一次的等待翻倍，最多 ReconnectMaxInterval。
+// 实际等待在 [wait/2, wait) 之间随机，避免多个实例同时重连
+func (m *Monitor) nextBackoff() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wait := max(m.backoff, ReconnectMinInterval)
+	m.backoff = min(wait*2, ReconnectMaxInterval)
+	return wait/2 + rand.N(wait/2)
+}
+
+// shutdown 取消全部订阅并关闭连接，等待队列中的交易处理完成，超时后取消处理，最后关闭各地址的日志文件
+func (m *Monitor) shutdown(ws *SafeWebSocket, done <-chan struct{}, cancelWork context.CancelFunc) {
+	m.logger.Info("收到停止信号，开始停止监控")
+
+	m.mu.Lock()
+	m.closing = true
+	for _, sub := range m.wallets {
+		m.unsubscribe(sub)
+	}
+	m.mu.Unlock()
+
+	if ws != nil {
+		// 等待取消订阅的响应，确保请求已发出
+		deadline := time.Now().Add(UnsubscribeTimeout)
+		for time.Now().Before(deadline) {
+			m.mu.Lock()
+			remaining := len(m.pending)
+			m.mu.Unlock()
+			if remaining == 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		ws.mu.Lock()
+		ws.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		ws.mu.Unlock()
+		ws.Close()
+		<-done
+
+		m.mu.Lock()
+		m.ws = nil
+		m.mu.Unlock()
+		core.WSConnected.Set(0)
+	}
+
+	m.workers.Close()
+	drained := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		m.logger.Info("队列中的交易已全部处理完成")
+	case <-time.After(ShutdownTimeout):
+		stats := m.workers.Stats()
+		m.logger.Warn("等待队列中的交易超时，取消剩余处理", "timeout", ShutdownTimeout, "queued", stats.Queued, "busy", stats.Busy)
+		cancelWork()
+		<-drained
+	}
+
+	m.mu.Lock()
+	for address, sub := range m.wallets {
+		sub.logger.Info("监控停止")
+		delete(m.wallets, address)
+	}
+	m.mu.Unlock()
+	m.logger.Info("监控已停止")
+}
+
+// ReadyChecks 返回 WebSocket 连接、订阅、Pong 与消息时间的就绪检查结果
+func (m *Monitor) ReadyChecks(cfg core.HealthConfig) []core.CheckResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	connected := core.CheckResult{Name: "websocket", OK: m.ws != nil && !m.closing}
+	if !connected.OK {
+		connected.Detail = "未连接"
+	}
+
+	var unsubscribed []string
+	for _, sub := range m.wallets {
+		if !sub.subscribed {
+			unsubscribed = append(unsubscribed, sub.wallet.Label)
+		}
+	}
+	sort.Strings(unsubscribed)
+	subscriptions := core.CheckResult{Name: "subscriptions", OK: connected.OK && len(unsubscribed) == 0}
+	switch {
+	case len(unsubscribed) > 0:
+		subscriptions.Detail = fmt.Sprintf("%d/%d 个地址未订阅: %s", len(unsubscribed), len(m.wallets), strings.Join(unsubscribed, ", "))
+	default:
+		subscriptions.Detail = fmt.Sprintf("%d 个地址已订阅", len(m.wallets))
+	}
+
+	pong := core.CheckResult{Name: "pong", OK: connected.OK}
+	if m.ws != nil {
+		age := time.Since(time.Unix(0, m.ws.lastPong.Load()))
+		pong.OK = connected.OK && age <= cfg.MaxPongAge()
+		pong.Detail = fmt.Sprintf("%v 前", age.Round(time.Second))
+	}
+
+	messages := core.CheckResult{Name: "messages"}
+	if last := m.lastMessage.Load(); last > 0 {
+		age := time.Since(time.Unix(0, last))
+		messages.OK = age <= cfg.MaxMessageAge()
+		messages.Detail = fmt.Sprintf("%v 前", age.Round(time.Second))
+	} else {
+		messages.Detail = "尚未收到消息"
+	}
+
+	return []core.CheckResult{connected, subscriptions, pong, messages}
+}
+
+// messageReader 提供待处理的消息，*websocket.Conn 与回放读取器实现了该接口
+type messageReader interface {
+	ReadMessage() (messageType int, p []byte, err error)
+}
+
+// handleMessages 读取 WebSocket 消息，把交易通知放入工作池队列，不在读取循环中请求 RPC
+func handleMessages(m *Monitor, conn messageReader) {
+	for {
+		_, msg, err := conn.ReadMessage()
+		if errors.Is(err, io.EOF) {
+			// 回放结束
+			return
+		}
+		if err != nil {
+			m.logger.Warn("读取消息失败", core.LogKeyError, err)
+			return
+		}
+		m.lastMessage.Store(time.Now().UnixNano())
+
+		var notification Notification
+		if err := json.Unmarshal(msg, &notification); err != nil {
+			m.logger.Warn("消息解析失败", core.LogKeyError, err, "payload", string(msg))
+			continue
+		}
+
+		if notification.Method != "logsNotification" {
+			m.handleResponse(msg)
+			continue
+		}
+
+		m.mu.Lock()
+		sub := m.wallets[m.subscriptions[notification.Params.Subscription]]
+		closing := m.closing
+		m.mu.Unlock()
+		if closing {
+			continue
+		}
+		if sub == nil {
+			m.logger.Warn("收到未知订阅的通知", "subscription", notification.Params.Subscription, "payload", string(msg))
+			continue
+		}
+		global.Recorder.RecordNotification(sub.wallet.Address, msg)
+		value := notification.Params.Result.Value
+		logger := sub.logger.With(core.LogKeySignature, value.Signature, core.LogKeySlot, notification.Params.Result.Context.Slot)
+		logger.Debug("收到消息", "payload", json.RawMessage(msg))
+		core.NotificationsReceived.WithLabelValues(sub.wallet.Address).Inc()
+		wallet := sub.wallet
+		m.workers.Submit(core.Job{
+			Name: fmt.Sprintf("%s %s", wallet.Label, notification.Params.Result.Value.Signature),
+			Run: func(ctx context.Context) {
+				handleNotification(ctx, logger, wallet, notification)
+			},
+		})
+	}
+}
+
+// handleNotification 获取并解析通知中的交易，开启跟单时执行跟单
+func handleNotification(ctx context.Context, logger *slog.Logger, wallet core.WalletConfig, notification Notification) {
+	signature := notification.Params.Result.Value.Signature
+	logger.Info("收到交易")
+
+	transactionService := service.NewTransactionService(logger)
+	transactionLogs, err := transactionService.GetTransactionLogs(ctx, wallet.Address, signature)
+	switch {
+	case errors.Is(err, service.ErrNotTrade):
+		core.TransactionsParsed.WithLabelValues(core.ParseSkipped).Inc()
+		logger.Debug("跳过非买卖交易")
+		return
+	case err != nil:
+		core.TransactionsParsed.WithLabelValues(core.ParseFailure).Inc()
+		logger.Error("获取交易日志失败", core.LogKeyError, err)
+		return
+	}
+	core.TransactionsParsed.WithLabelValues(core.ParseSuccess).Inc()
+	logger.Debug("解析后交易", "type", transactionLogs.Type, "amount", transactionLogs.Amount, core.LogKeyMint, transactionLogs.Mint)
+
+	if notification.Params.Result.Value.Err != nil {
+		logger.Info("交易失败", "tx_err", notification.Params.Result.Value.Err)
+		return
+	}
+	logger.Info("交易成功", "type", transactionLogs.Type, core.LogKeyMint, transactionLogs.Mint)
+	if len(wallet.Alerts) > 0 {
+		global.Alerter.Send(ctx, wallet.Alerts, core.Alert{
+			Wallet:    wallet.Address,
+			Label:     wallet.Label,
+			Signature: signature,
+			Type:      transactionLogs.Type,
+			Mint:      transactionLogs.Mint,
+			Amount:    transactionLogs.Amount,
+			Time:      time.Now(),
+		})
+	}
+	if _, err := transactionService.RecordTrades(ctx, wallet.Address, signature); err != nil && !errors.Is(err, service.ErrNotTrade) {
+		logger.Error("保存交易失败", core.LogKeyError, err)
+	}
+
+	if wallet.Follow.Enabled && transactionLogs.Mint != "" {
+		followTrade(ctx, logger, wallet, signature, transactionLogs.Mint)
+	}
+}
+
+// followTrade 对开启跟单的地址执行跟单
+func followTrade(ctx context.Context, logger *slog.Logger, wallet core.WalletConfig, signature string, mint string) {
+	follow := service.NewFollowTransactionService(global.RpcClient, logger)
+	err := follow.FollowAndSend(ctx, solana.MustPublicKeyFromBase58(wallet.Address), signature, solana.MustPublicKeyFromBase58(mint))
+	if err != nil {
+		logger.Error("跟单失败", core.LogKeyError, err)
+	}
+}
+
+// handleResponse 处理订阅与取消订阅请求的响应
+func (m *Monitor) handleResponse(msg []byte) {
+	var resp RPCResponse
+	if err := json.Unmarshal(msg, &resp); err != nil || resp.Id == 0 {
+		m.logger.Debug("收到消息", "payload", string(msg))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	address, ok := m.pending[resp.Id]
+	if !ok {
+		m.logger.Debug("收到响应", "payload", string(msg))
+		return
+	}
+	delete(m.pending, resp.Id)
+
+	if resp.Error != nil {
+		m.logger.Warn("请求失败", "id", resp.Id, core.LogKeyAddress, address, "code", resp.Error.Code, "message", resp.Error.Message)
+		return
+	}
+
+	var subscriptionID int
+	if err := json.Unmarshal(resp.Result, &subscriptionID); err != nil {
+		// 取消订阅的响应为 true/false
+		m.logger.Info("取消订阅", core.LogKeyAddress, address, "result", string(resp.Result))
+		return
+	}
+	sub, ok := m.wallets[address]
+	if !ok {
+		// 订阅确认前地址已被移除，立即取消
+		m.unsubscribeID(address, subscriptionID)
+		return
+	}
+	sub.subscriptionID = subscriptionID
+	sub.subscribed = true
+	m.backoff = 0
+	m.subscriptions[subscriptionID] = address
+	sub.logger.Info("订阅成功", "subscription", subscriptionID)
+}
+
+// subscribe 在当前连接上订阅地址，调用方需持有锁
+func (m *Monitor) subscribe(sub *walletSubscription) {
+	if m.ws == nil {
+		return
+	}
+	params := []interface{}{
+		map[string]interface{}{
+			"mentions": []string{sub.wallet.Address},
+		},
+		map[string]interface{}{
+			"commitment": sub.wallet.Commitment,
+		},
+	}
+	m.send(sub.wallet.Address, methodLogsSubscribe, params, sub.logger)
+}
+
+// unsubscribe 在当前连接上取消地址的订阅，调用方需持有锁
+func (m *Monitor) unsubscribe(sub *walletSubscription) {
+	if !sub.subscribed {
+		return
+	}
+	sub.subscribed = false
+	m.unsubscribeID(sub.wallet.Address, sub.subscriptionID)
+}
+
+// unsubscribeID 在当前连接上取消订阅 id，调用方需持有锁
+func (m *Monitor) unsubscribeID(address string, subscriptionID int) {
+	delete(m.subscriptions, subscriptionID)
+	if m.ws == nil {
+		return
+	}
+	m.send(address, methodLogsUnsub, []interface{}{subscriptionID}, m.logger)
+}
+
+// send 发送请求并记录请求 id 对应的地址，调用方需持有锁
+func (m *Monitor) send(address, method string, params []interface{}, logger *slog.Logger) {
+	m.nextID++
+	req := RPCRequest{
+		Jsonrpc: "2.0",
+		Id:      m.nextID,
+		Method:  method,
+		Params:  params,
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		logger.Error("请求序列化失败", "method", method, core.LogKeyError, err)
+		return
+	}
+	m.pending[req.Id] = address
+	m.ws.SendMessage(reqBytes)
+	logger.Debug("请求发送成功", "method", method, "id", req.Id, "payload", json.RawMessage(reqBytes))
+}
+
+// apply 把监控列表与当前订阅对比，只订阅新增地址、取消已移除地址
+func (m *Monitor) apply(wallets []core.WalletConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing {
+		return
+	}
+
+	next := make(map[string]core.WalletConfig, len(wallets))
+	for _, wallet := range wallets {
+		next[wallet.Address] = wallet
+	}
+
+	for address, sub := range m.wallets {
+		wallet, ok := next[address]
+		switch {
+		case !ok:
+			m.logger.Info("移除监控地址", walletAttrs(sub.wallet)...)
+			m.unsubscribe(sub)
+			delete(m.wallets, address)
+		case wallet.Commitment != sub.wallet.Commitment:
+			// 确认级别变化需要重新订阅
+			m.logger.Info("更新监控地址", append(walletAttrs(wallet), "previous", describeWallet(sub.wallet))...)
+			m.unsubscribe(sub)
+			sub.wallet = wallet
+			sub.logger = m.walletLogger(wallet)
+			m.subscribe(sub)
+		case !reflect.DeepEqual(wallet, sub.wallet):
+			m.logger.Info("更新监控地址", append(walletAttrs(wallet), "previous", describeWallet(sub.wallet))...)
+			sub.wallet = wallet
+			sub.logger = m.walletLogger(wallet)
+		}
+	}
+
+	for address, wallet := range next {
+		if _, ok := m.wallets[address]; ok {
+			continue
+		}
+		sub := &walletSubscription{wallet: wallet, logger: m.walletLogger(wallet)}
+		m.wallets[address] = sub
+		m.logger.Info("新增监控地址", walletAttrs(wallet)...)
+		m.subscribe(sub)
+	}
+}
+
+// watchReload 定期检查配置文件与 Redis 中的监控列表，变化时热更新订阅，直到 ctx 结束
+func (m *Monitor) watchReload(ctx context.Context) {
+	ticker := time.NewTicker(ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		configChanged := false
+		if info, err := os.Stat(m.configPath); err == nil && !info.ModTime().Equal(m.configModTime) {
+			m.configModTime = info.ModTime()
+			configChanged = true
+		}
+		members := m.readRedisWatchList(ctx)
+		redisChanged := !reflect.DeepEqual(members, m.redisMembers)
+		if !configChanged && !redisChanged {
+			continue
+		}
+		m.redisMembers = members
+
+		config, err := core.LoadConfig(m.configPath, m.flags)
+		if err != nil {
+			m.logger.Error("重新加载配置失败，保持当前订阅", core.LogKeyError, err)
+			continue
+		}
+		m.logger.Info("监控列表发生变化，开始热更新", "config_changed", configChanged, "redis_changed", redisChanged)
+		global.SetSystemConfig(config.SystemConfig)
+		m.apply(m.watchList(config.SystemConfig))
+	}
+}
+
+// watchList 合并配置文件与 Redis 集合中的监控地址
+func (m *Monitor) watchList(system core.SystemConfig) []core.WalletConfig {
+	for _, address := range m.redisMembers {
+		if _, ok := system.Wallet(address); !ok {
+			system.Wallets = append(system.Wallets, core.WalletConfig{Address: address, Tags: []string{"redis"}})
+		}
+	}
+	return system.WatchList()
+}
+
+// readRedisWatchList 读取 Redis 集合中的监控地址，跳过非法地址
+func (m *Monitor) readRedisWatchList(ctx context.Context) []string {
+	if !global.Redis.Available() {
+		// 降级模式下保留上一次读取到的地址
+		return m.redisMembers
+	}
+	addresses, err := global.Redis.WatchList(ctx)
+	if err != nil {
+		m.logger.Warn("读取 Redis 监控列表失败", core.LogKeyError, err)
+		return m.redisMembers
+	}
+	return addresses
+}
+
+// walletLogger 返回带地址字段的日志记录器
+func (m *Monitor) walletLogger(wallet core.WalletConfig) *slog.Logger {
+	return m.logger.With(core.LogKeyAddress, wallet.Address, core.LogKeyLabel, wallet.Label)
+}
+
+// walletAttrs 返回用于变更日志的地址字段
+func walletAttrs(wallet core.WalletConfig) []any {
+	return []any{
+		core.LogKeyAddress, wallet.Address,
+		core.LogKeyLabel, wallet.Label,
+		"tags", strings.Join(wallet.Tags, ","),
+		"commitment", wallet.Commitment,
+		"follow", wallet.Follow.Enabled,
+	}
+}
+
+// describeWallet 返回用于变更日志的地址描述
+func describeWallet(wallet core.WalletConfig) string {
+	return fmt.Sprintf("%s(%s) tags=[%s] commitment=%s follow=%v", wallet.Label, wallet.Address,
+		strings.Join(wallet.Tags, ","), wallet.Commitment, wallet.Follow.Enabled)
+}
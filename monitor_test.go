@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"meme/mocknet"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// startMocknet 启动载入 transaction_demo.json 的模拟节点，并将全局 RPC 指向它，监控 demoSigner
+func startMocknet(t *testing.T) *mocknet.Network {
+	t.Helper()
+	restoreGlobals(t)
+	pool, config := global.RpcPool, global.SystemConfig()
+	t.Cleanup(func() {
+		global.RpcPool = pool
+		global.SetSystemConfig(config)
+	})
+
+	fixtures := mocknet.NewFixtures()
+	if _, err := fixtures.LoadTransactions("transaction_demo.json"); err != nil {
+		t.Fatalf("载入 transaction_demo.json 失败: %v", err)
+	}
+	network, err := mocknet.Start(fixtures, "", "")
+	if err != nil {
+		t.Fatalf("启动模拟节点失败: %v", err)
+	}
+	t.Cleanup(network.Close)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	global.RpcClient, global.RpcPool = core.InitRPC(core.RPCConfig{Endpoints: []core.EndpointConfig{network.Endpoint()}}, logger)
+	// 不使用缓存，每次通知都向节点请求交易
+	global.Cache, global.Store, global.Recorder = nil, nil, nil
+	global.SetSystemConfig(core.SystemConfig{Wallets: []core.WalletConfig{{Address: demoSigner}}})
+	return network
+}
+
+// runMonitor 在后台运行监控器，测试结束时停止并等待 Run 返回
+func runMonitor(t *testing.T, monitor *Monitor) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("监控器未在 10s 内停止")
+		}
+	})
+}
+
+// waitFor 轮询直到 cond 成立，超时后测试失败
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitSubscribed 等待 demoSigner 在模拟节点上被订阅
+func waitSubscribed(t *testing.T, network *mocknet.Network) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := network.WS.WaitSubscribed(ctx, demoSigner); err != nil {
+		t.Fatalf("等待订阅 %s 失败: %v", demoSigner, err)
+	}
+}
+
+// waitTrades 等待解析出 n 笔交易，并检查每笔都是 demoSigner 卖出 demoMint
+func waitTrades(t *testing.T, logs *syncBuffer, n int) {
+	t.Helper()
+	waitFor(t, 5*time.Second, "解析交易", func() bool {
+		return len(logs.records(t, "交易成功")) >= n
+	})
+	if failed := logs.records(t, "获取交易日志失败"); len(failed) != 0 {
+		t.Fatalf("获取交易失败: %v", failed)
+	}
+	for _, record := range logs.records(t, "交易成功") {
+		if record["type"] != "sell" || record[core.LogKeyMint] != demoMint || record[core.LogKeyAddress] != demoSigner {
+			t.Errorf("解析结果为 %v，期望 %s 卖出 %s", record, demoSigner, demoMint)
+		}
+	}
+}
+
+func TestMonitorNotify(t *testing.T) {
+	network := startMocknet(t)
+	monitor, logs := newTestMonitor(t)
+	runMonitor(t, monitor)
+
+	waitSubscribed(t, network)
+	if sent := network.WS.Notify(demoSigner, demoSignature, nil); sent != 1 {
+		t.Fatalf("推送给 %d 个连接，期望 1 个", sent)
+	}
+	waitTrades(t, logs, 1)
+	if calls := network.RPC.Calls("getTransaction"); calls != 1 {
+		t.Errorf("getTransaction 调用 %d 次，期望 1 次", calls)
+	}
+
+	// 失败的交易只记录失败，不作为成交处理
+	network.WS.Notify(demoSigner, demoSignature, map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}})
+	waitFor(t, 5*time.Second, "记录失败交易", func() bool {
+		return len(logs.records(t, "交易失败")) == 1
+	})
+	if parsed := logs.records(t, "交易成功"); len(parsed) != 1 {
+		t.Errorf("失败交易被记为成功，共 %d 笔成功交易", len(parsed))
+	}
+}
+
+func TestMonitorReconnect(t *testing.T) {
+	network := startMocknet(t)
+	monitor, logs := newTestMonitor(t)
+	runMonitor(t, monitor)
+
+	waitSubscribed(t, network)
+	reconnects := testutil.ToFloat64(core.WSReconnects)
+	network.WS.Disconnect()
+
+	// 断线后重新连接并恢复订阅
+	waitFor(t, 5*time.Second, "重连", func() bool {
+		return network.WS.Connects() == 2 && network.WS.Subscribers(demoSigner) == 1
+	})
+	if got := testutil.ToFloat64(core.WSReconnects) - reconnects; got != 1 {
+		t.Errorf("重连计数增加 %v，期望 1", got)
+	}
+
+	if sent := network.WS.Notify(demoSigner, demoSignature, nil); sent != 1 {
+		t.Fatalf("重连后推送给 %d 个连接，期望 1 个", sent)
+	}
+	waitTrades(t, logs, 1)
+}
+
+// TestMonitorReconnectBackoff 校验重连等待按指数增长并带抖动，达到上限后不再增长，订阅成功后重置
+func TestMonitorReconnectBackoff(t *testing.T) {
+	monitor := NewMonitor("", nil, core.QueueConfig{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	want := ReconnectMinInterval
+	for i := 0; i < 10; i++ {
+		if wait := monitor.nextBackoff(); wait < want/2 || wait >= want {
+			t.Fatalf("第 %d 次重连等待 %v，期望在 [%v, %v) 之间", i+1, wait, want/2, want)
+		}
+		want = min(want*2, ReconnectMaxInterval)
+	}
+
+	const address = "HXvUJoQuDvpZ4oNNFF5itafDfwMUCAFijLnjCwKVJ5rg"
+	monitor.mu.Lock()
+	monitor.pending[1] = address
+	monitor.wallets[address] = &walletSubscription{logger: monitor.logger}
+	monitor.mu.Unlock()
+	monitor.handleResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":7}`))
+	if wait := monitor.nextBackoff(); wait >= ReconnectMinInterval {
+		t.Errorf("订阅成功后重连等待 %v，期望重置为小于 %v", wait, ReconnectMinInterval)
+	}
+}
+
+func TestMonitorRateLimitBackoff(t *testing.T) {
+	network := startMocknet(t)
+	monitor, logs := newTestMonitor(t)
+	runMonitor(t, monitor)
+
+	waitSubscribed(t, network)
+	network.RPC.RateLimit(1, time.Second)
+	start := time.Now()
+	network.WS.Notify(demoSigner, demoSignature, nil)
+
+	// 第一次请求被限流，等待 Retry-After 后重试成功
+	waitTrades(t, logs, 1)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("限流后 %v 即重试，期望至少等待 1s", elapsed)
+	}
+	if limited := logs.records(t, "请求速率限制，稍后重试"); len(limited) != 1 {
+		t.Errorf("记录 %d 次限流，期望 1 次", len(limited))
+	}
+}
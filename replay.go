@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"net/http"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+// errOffline 表示回放时尝试访问网络
+var errOffline = errors.New("回放模式不访问网络，录制文件中缺少该请求的结果")
+
+// offlineRPC 拒绝全部 RPC 请求，保证回放不访问网络
+type offlineRPC struct{}
+
+func (offlineRPC) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	return fmt.Errorf("%s: %w", method, errOffline)
+}
+
+func (offlineRPC) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	return fmt.Errorf("%s: %w", method, errOffline)
+}
+
+func (offlineRPC) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	return nil, fmt.Errorf("batch: %w", errOffline)
+}
+
+// replayReader 按录制时的时间间隔（除以 speed）依次返回录制的通知，全部返回后返回 io.EOF
+type replayReader struct {
+	ctx           context.Context
+	notifications []core.Record
+	subscriptions map[string]int // 地址 -> 回放使用的订阅 id
+	speed         float64        // 0 表示不等待
+	next          int
+}
+
+func (r *replayReader) ReadMessage() (int, []byte, error) {
+	if r.next >= len(r.notifications) {
+		return 0, nil, io.EOF
+	}
+	record := r.notifications[r.next]
+	if r.next > 0 && r.speed > 0 {
+		wait := time.Duration(float64(record.Time.Sub(r.notifications[r.next-1].Time)) / r.speed)
+		select {
+		case <-time.After(wait):
+		case <-r.ctx.Done():
+			return 0, nil, io.EOF
+		}
+	}
+	r.next++
+
+	// 录制跨越重连时订阅 id 会变化，统一改写为按地址分配的 id
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(record.Data, &msg); err != nil {
+		return 0, nil, fmt.Errorf("录制的通知解析失败: %w", err)
+	}
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		return 0, nil, fmt.Errorf("录制的通知解析失败: %w", err)
+	}
+	params["subscription"], _ = json.Marshal(r.subscriptions[record.Address])
+	msg["params"], _ = json.Marshal(params)
+	data, err := json.Marshal(msg)
+	return websocket.TextMessage, data, err
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay recorded notifications through the parser without network",
+	Long: `Replay recorded notifications through the parser without network.
+
+Record a session by setting record.file (or --record.file). Replay feeds the
+recorded logsNotification messages through the same message handler and
+worker pool as the monitor. Transactions are served from the recording;
+any other RPC call fails instead of reaching the network. Copy trading and
+the trade store are disabled during replay.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		config, err := core.LoadConfig(path, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		debug, _ := cmd.Flags().GetBool("debug")
+		records, err := core.ReadRecords(args[0])
+		if err != nil {
+			return fmt.Errorf("读取录制文件失败: %w", err)
+		}
+
+		level := slog.LevelInfo
+		if debug {
+			level = slog.LevelDebug
+		}
+		logger := core.NewConsoleLogger(level)
+		ctx := cmd.Context()
+
+		// 回放不丢弃通知，队列满时等待
+		queue := config.Queue
+		queue.FullPolicy = core.QueuePolicyBlock
+		monitor := NewMonitor(path, cmd.Flags(), queue, logger)
+		reader, transactions, err := prepareReplay(ctx, monitor, records, config.Cache, speed)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("回放 %d 条通知、%d 笔交易，%d 个地址\n", len(reader.notifications), transactions, len(reader.subscriptions))
+
+		monitor.workers.Start(ctx)
+		start := time.Now()
+		handleMessages(monitor, reader)
+		monitor.workers.Close()
+		monitor.workers.Wait()
+		stats := monitor.workers.Stats()
+		fmt.Printf("回放完成，用时 %v，处理 %d 条，丢弃 %d 条\n", time.Since(start).Round(time.Millisecond), stats.Completed, stats.Dropped)
+		return nil
+	},
+}
+
+// prepareReplay 把录制的交易放入进程内缓存，断开 RPC、交易存储与录制，并在 monitor 上登记通知涉及的地址。
+// 返回按录制顺序回放通知的 reader 与录制的交易数
+func prepareReplay(ctx context.Context, monitor *Monitor, records []core.Record, cacheConfig core.CacheConfig, speed float64) (*replayReader, int, error) {
+	var notifications, transactions []core.Record
+	for _, record := range records {
+		switch record.Kind {
+		case core.RecordNotification:
+			notifications = append(notifications, record)
+		case core.RecordTransaction:
+			transactions = append(transactions, record)
+		}
+	}
+
+	// 交易全部放入进程内缓存，解析时从缓存读取
+	cacheConfig.Size = max(cacheConfig.Size, core.DefaultCacheSize, 2*len(transactions))
+	global.Cache = core.NewCache(cacheConfig, nil, nil)
+	for _, record := range transactions {
+		var tx rpc.GetTransactionResult
+		if err := json.Unmarshal(record.Data, &tx); err != nil {
+			return nil, 0, fmt.Errorf("录制的交易 %s 解析失败: %w", record.Signature, err)
+		}
+		global.Cache.SetTTL(ctx, core.CacheTransaction, record.Signature, &tx, 0)
+	}
+	global.RpcClient = rpc.NewWithCustomRPCClient(offlineRPC{})
+	global.Store = nil
+	global.Recorder = nil
+
+	reader := &replayReader{ctx: ctx, notifications: notifications, subscriptions: make(map[string]int), speed: speed}
+	for _, record := range notifications {
+		if _, ok := reader.subscriptions[record.Address]; ok {
+			continue
+		}
+		wallet, ok := global.SystemConfig().Wallet(record.Address)
+		if !ok {
+			wallet = core.WalletConfig{Address: record.Address, Label: global.SystemConfig().Label(record.Address)}
+		}
+		wallet.Follow.Enabled = false
+		id := len(reader.subscriptions) + 1
+		reader.subscriptions[record.Address] = id
+		monitor.wallets[record.Address] = &walletSubscription{wallet: wallet, logger: monitor.walletLogger(wallet), subscriptionID: id, subscribed: true}
+		monitor.subscriptions[id] = record.Address
+	}
+	return reader, len(transactions), nil
+}
+
+func init() {
+	replayCmd.Flags().Float64("speed", 0, "回放速度倍数，1 为录制时的原始速度，0 表示不等待")
+	replayCmd.Flags().Bool("debug", false, "输出 debug 级别日志，包含原始消息与交易 JSON")
+}
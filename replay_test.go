@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/spf13/pflag"
+)
+
+const (
+	demoSigner    = "HXvUJoQuDvpZ4oNNFF5itafDfwMUCAFijLnjCwKVJ5rg"
+	demoSignature = "3bnthj8Fv7J3yq8ZP2zY3Y64jAwzVRgLe2YN1CJJghhDxRn8LqxTcA2uMNDB2zS44RNzMV1usFnNrwqcm8Z2parZ"
+	demoMint      = "9bA47jHMbY8XqGKdgC7QtYiZb1XqBj8vM1t1zbQPJcWv"
+)
+
+// syncBuffer 是可并发写入的日志缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 返回 msg 为 message 的全部 JSON 日志
+func (b *syncBuffer) records(t *testing.T, message string) []map[string]interface{} {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range bytes.Split(b.buf.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("日志解析失败: %v: %s", err, line)
+		}
+		if record["msg"] == message {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// newTestMonitor 创建日志以 JSON 写入缓冲区的监控器
+func newTestMonitor(t *testing.T) (*Monitor, *syncBuffer) {
+	t.Helper()
+	logs := &syncBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	queue := core.QueueConfig{Workers: 1, Size: 16, FullPolicy: core.QueuePolicyBlock}
+	return NewMonitor(filepath.Join(t.TempDir(), "config.yml"), pflag.NewFlagSet("test", pflag.ContinueOnError), queue, logger), logs
+}
+
+// restoreGlobals 在测试结束后恢复被修改的全局状态
+func restoreGlobals(t *testing.T) {
+	cache, client, store, recorder := global.Cache, global.RpcClient, global.Store, global.Recorder
+	t.Cleanup(func() {
+		global.Cache, global.RpcClient, global.Store, global.Recorder = cache, client, store, recorder
+	})
+}
+
+func TestReplayRecording(t *testing.T) {
+	restoreGlobals(t)
+	data, err := os.ReadFile("transaction_demo.json")
+	if err != nil {
+		t.Fatalf("读取 transaction_demo.json 失败: %v", err)
+	}
+	var tx rpc.GetTransactionResult
+	if err := json.Unmarshal(data, &tx); err != nil {
+		t.Fatalf("解析 transaction_demo.json 失败: %v", err)
+	}
+
+	// 录制一条通知与对应交易，同一交易重复获取只录制一次
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := core.NewRecorder(path, nil)
+	if err != nil {
+		t.Fatalf("创建录制文件失败: %v", err)
+	}
+	notification := []byte(`{"jsonrpc":"2.0","method":"logsNotification","params":{"result":{"context":{"slot":304040433},` +
+		`"value":{"signature":"` + demoSignature + `","err":null,"logs":[]}},"subscription":42}}`)
+	recorder.RecordNotification(demoSigner, notification)
+	recorder.RecordTransaction(demoSignature, &tx)
+	recorder.RecordTransaction(demoSignature, &tx)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("关闭录制文件失败: %v", err)
+	}
+
+	records, err := core.ReadRecords(path)
+	if err != nil {
+		t.Fatalf("读取录制文件失败: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("录制了 %d 条记录，期望 2 条", len(records))
+	}
+
+	ctx := context.Background()
+	monitor, logs := newTestMonitor(t)
+	reader, transactions, err := prepareReplay(ctx, monitor, records, core.CacheConfig{}, 0)
+	if err != nil {
+		t.Fatalf("准备回放失败: %v", err)
+	}
+	if transactions != 1 || len(reader.notifications) != 1 {
+		t.Fatalf("回放 %d 条通知、%d 笔交易，期望各 1 条", len(reader.notifications), transactions)
+	}
+
+	monitor.workers.Start(ctx)
+	handleMessages(monitor, reader)
+	monitor.workers.Close()
+	monitor.workers.Wait()
+
+	if failed := logs.records(t, "获取交易日志失败"); len(failed) != 0 {
+		t.Fatalf("回放时解析失败: %v", failed)
+	}
+	parsed := logs.records(t, "交易成功")
+	if len(parsed) != 1 {
+		t.Fatalf("解析出 %d 笔交易，期望 1 笔", len(parsed))
+	}
+	if parsed[0]["type"] != "sell" || parsed[0][core.LogKeyMint] != demoMint || parsed[0][core.LogKeyAddress] != demoSigner {
+		t.Errorf("解析结果为 %v，期望 %s 卖出 %s", parsed[0], demoSigner, demoMint)
+	}
+}
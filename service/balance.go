@@ -7,48 +7,66 @@ import (
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/spf13/cobra"
 	"log"
+	"log/slog"
 	"math/big"
 	"meme/global"
 )
 
 var BalanceCmd = &cobra.Command{
-	Use:   "balance",
+	Use:   "balance [address|label]",
 	Short: "Get SOL and token balances",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client := rpc.New(rpc.MainNetBeta_RPC)
-		selfAddress := global.SystemConfig.SelfAddress
-		address := solana.MustPublicKeyFromBase58(selfAddress)
+		client := global.RpcClient
+		address := solana.MustPublicKeyFromBase58(resolveWallet(args))
 
-		getSolBalance(client, address)
-		getTokenBalances(client, address)
+		getSolBalance(cmd.Context(), client, address)
+		getTokenBalances(cmd.Context(), client, address)
 	},
 }
 
+// resolveWallet 按参数中的地址或标签查找钱包，未指定时使用监控列表中的第一个地址
+func resolveWallet(args []string) string {
+	wallets := global.SystemConfig().WatchList()
+	if len(args) == 0 {
+		if len(wallets) == 0 {
+			log.Fatalf("请配置监控地址")
+		}
+		return wallets[0].Address
+	}
+	for _, wallet := range wallets {
+		if wallet.Label == args[0] || wallet.Address == args[0] {
+			return wallet.Address
+		}
+	}
+	return args[0]
+}
+
 type BalanceService struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewBalanceService 创建一个新的余额服务实例
-func NewBalanceService(logger *log.Logger) *BalanceService {
+func NewBalanceService(logger *slog.Logger) *BalanceService {
 	return &BalanceService{
 		logger: logger,
 	}
 }
 
 // 获取 SOL 余额
-func getSolBalance(client *rpc.Client, address solana.PublicKey) {
-	balance, err := client.GetBalance(context.TODO(), address, rpc.CommitmentConfirmed)
+func getSolBalance(ctx context.Context, client *rpc.Client, address solana.PublicKey) {
+	balance, err := client.GetBalance(ctx, address, rpc.CommitmentConfirmed)
 	if err != nil {
 		log.Fatalf("获取 SOL 余额失败: %v", err)
 	}
-	fmt.Printf("账户: %s 的 SOL 余额: %.9f SOL\n", address.String(), float64(balance.Value)/1e9)
+	fmt.Printf("账户: %s 的 SOL 余额: %.9f SOL\n", global.SystemConfig().Label(address.String()), float64(balance.Value)/1e9)
 }
 
 // 获取代币账户及余额
-func getTokenBalances(client *rpc.Client, address solana.PublicKey) {
+func getTokenBalances(ctx context.Context, client *rpc.Client, address solana.PublicKey) {
 	// 查询账户代币持有情况
 	response, err := client.GetTokenAccountsByOwner(
-		context.TODO(),
+		ctx,
 		address,
 		&rpc.GetTokenAccountsConfig{
 			ProgramId: &solana.TokenProgramID,
@@ -60,25 +78,51 @@ func getTokenBalances(client *rpc.Client, address solana.PublicKey) {
 	}
 
 	if len(response.Value) == 0 {
-		fmt.Printf("账户 %s 未持有任何 SPL 代币\n", address.String())
+		fmt.Printf("账户 %s 未持有任何 SPL 代币\n", global.SystemConfig().Label(address.String()))
 		return
 	}
 
-	fmt.Printf("账户 %s 持有的 SPL 代币列表:\n", address.String())
+	var holdings []tokenHolding
 	for _, tokenAccount := range response.Value {
 		//fmt.Printf("代币账户地址: %s\n", tokenAccount.Pubkey)
 		//fmt.Printf("tokenAccount.Account: %+v\n", tokenAccount.Account)
 		accountData := tokenAccount.Account.Data.GetBinary()
-		parseTokenAccountData(client, accountData)
+		if holding, ok := parseTokenAccountData(accountData); ok {
+			holdings = append(holdings, holding)
+		}
+	}
+
+	// 批量查询精度与元数据
+	mints := make([]solana.PublicKey, 0, len(holdings))
+	for _, holding := range holdings {
+		mints = append(mints, holding.Mint)
 	}
+	decimals, err := GetTokenDecimals(ctx, client, mints)
+	if err != nil {
+		fmt.Printf("获取精度时出错: %v\n", err)
+	}
+	metadata, err := GetTokenMetadataBatch(ctx, client, mints)
+	if err != nil {
+		fmt.Printf("获取元数据时出错: %v\n", err)
+	}
+
+	fmt.Printf("账户 %s 持有的 SPL 代币列表:\n", global.SystemConfig().Label(address.String()))
+	for _, holding := range holdings {
+		printTokenHolding(holding, decimals[holding.Mint], metadata[holding.Mint])
+	}
+}
+
+type tokenHolding struct {
+	Mint   solana.PublicKey
+	Amount *big.Int
 }
 
-// 解析代币账户数据
-func parseTokenAccountData(client *rpc.Client, accountData []byte) {
+// 解析代币账户数据，忽略余额过小的账户
+func parseTokenAccountData(accountData []byte) (tokenHolding, bool) {
 	// 检查账户数据长度是否符合 SPL Token 数据结构
 	if len(accountData) < 165 {
 		fmt.Println("账户数据长度不正确，可能不是一个有效的 SPL 代币账户")
-		return
+		return tokenHolding{}, false
 	}
 	//fmt.Printf("accountData: %+v\n", accountData)
 
@@ -87,29 +131,30 @@ func parseTokenAccountData(client *rpc.Client, accountData []byte) {
 	reverseBytes(amountBytes)         // 转换为小端字节序
 	amount := new(big.Int).SetBytes(amountBytes)
 	if amount.Cmp(big.NewInt(1e6)) < 0 {
-		return
+		return tokenHolding{}, false
 	}
 	// 提取 Mint 地址（代币的唯一标识）
 	mint := solana.PublicKeyFromBytes(accountData[0:32])
+	return tokenHolding{Mint: mint, Amount: amount}, true
+}
 
-	metadata, _ := GetTokenMetadata(client, mint)
-
+// 打印代币余额
+func printTokenHolding(holding tokenHolding, decimals uint8, metadata TokenMetadata) {
 	// 根据精度计算余额
-	decimals := getTokenDecimals(client, solana.PublicKeyFromBytes(accountData[0:32]))
 	if decimals > 0 {
 		// 余额 = 余额 / 10^decimals，保留 8 位小数点
 		divisor := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
-		amountFloat := new(big.Float).Quo(new(big.Float).SetInt(amount), divisor)
+		amountFloat := new(big.Float).Quo(new(big.Float).SetInt(holding.Amount), divisor)
 
 		// 格式化余额为保留 8 位小数
 		amountFormatted := fmt.Sprintf("%.8f", amountFloat)
 		fmt.Printf("余额(已处理精度): %s\n", amountFormatted)
 	} else {
 
-		fmt.Printf("余额(未处理精度): %s\n", amount)
+		fmt.Printf("余额(未处理精度): %s\n", holding.Amount)
 	}
 	//fmt.Printf("代币精度: %d\n", decimals)
-	fmt.Printf("代币地址 (Mint): %s\n", mint)
+	fmt.Printf("代币地址 (Mint): %s\n", holding.Mint)
 	fmt.Printf("代币符号: %s\n", metadata.Symbol)
 	fmt.Println("--------------------------------------")
 }
@@ -119,15 +164,3 @@ func reverseBytes(b []byte) {
 		b[i], b[j] = b[j], b[i]
 	}
 }
-
-// 获取代币 Decimals
-func getTokenDecimals(client *rpc.Client, mint solana.PublicKey) int {
-	// 调用 getTokenSupply 获取精度
-	response, err := client.GetTokenSupply(context.TODO(), mint, rpc.CommitmentConfirmed)
-	if err != nil || response.Value.Decimals == 0 {
-		fmt.Printf("获取精度时出错: %v\n", err)
-		return 0
-	}
-	//spew.Dump(response)
-	return int(response.Value.Decimals)
-}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"meme/core"
+	"meme/global"
+	"sync/atomic"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// finalizedSlot 最近一次查询到的 finalized slot，只增不减
+var finalizedSlot atomic.Uint64
+
+// GetTransactionCached 优先从缓存读取交易，未命中时查询节点并写入缓存。
+// 交易所在 slot 已经 finalized 时不会再变化，永久缓存；否则按 transaction 类型的缓存时间
+func GetTransactionCached(ctx context.Context, client *rpc.Client, signature solana.Signature, commitment rpc.CommitmentType) (*rpc.GetTransactionResult, error) {
+	key := signature.String()
+	var cached rpc.GetTransactionResult
+	if global.Cache.Get(ctx, core.CacheTransaction, key, &cached) {
+		global.Recorder.RecordTransaction(key, &cached)
+		return &cached, nil
+	}
+
+	maxSupportedVersion := uint64(0)
+	tx, err := client.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
+		Commitment:                     commitment,
+		MaxSupportedTransactionVersion: &maxSupportedVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	global.Recorder.RecordTransaction(key, tx)
+	if commitment == rpc.CommitmentFinalized || isFinalized(ctx, client, tx.Slot) {
+		global.Cache.SetTTL(ctx, core.CacheTransaction, key, tx, 0)
+	} else {
+		global.Cache.Set(ctx, core.CacheTransaction, key, tx)
+	}
+	return tx, nil
+}
+
+// isFinalized 判断 slot 是否已经 finalized。只有 slot 比已知的 finalized slot 新时才查询节点，
+// 查询失败时按未 finalized 处理
+func isFinalized(ctx context.Context, client *rpc.Client, slot uint64) bool {
+	if slot <= finalizedSlot.Load() {
+		return true
+	}
+	latest, err := client.GetSlot(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return false
+	}
+	for {
+		current := finalizedSlot.Load()
+		if latest <= current || finalizedSlot.CompareAndSwap(current, latest) {
+			break
+		}
+	}
+	return slot <= latest
+}
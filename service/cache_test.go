@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"meme/core"
+	"meme/global"
+	"meme/mocknet"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGetTransactionCachedFinality(t *testing.T) {
+	server := useMiniRedis(t)
+	fixtures := mocknet.NewFixtures()
+	signatures, err := fixtures.LoadTransactions("../transaction_demo.json")
+	if err != nil {
+		t.Fatalf("加载 fixtures 失败: %v", err)
+	}
+	network, err := mocknet.Start(fixtures, "", "")
+	if err != nil {
+		t.Fatalf("启动 mocknet 失败: %v", err)
+	}
+	t.Cleanup(network.Close)
+
+	previous := global.Cache
+	t.Cleanup(func() {
+		global.Cache = previous
+		finalizedSlot.Store(0)
+	})
+	ctx := context.Background()
+	client := rpc.New(network.RPC.URL())
+	signature := solana.MustSignatureFromBase58(signatures[0])
+	key := "cache:" + string(core.CacheTransaction) + ":" + signatures[0]
+	misses := func() float64 {
+		return testutil.ToFloat64(core.CacheLookups.WithLabelValues(string(core.CacheTransaction), core.CacheMiss))
+	}
+
+	// finalized slot 落后于交易所在 slot，按 transaction 类型的缓存时间缓存
+	finalizedSlot.Store(0)
+	global.Cache = core.NewCache(core.CacheConfig{}, global.Redis, nil)
+	before := misses()
+	tx, err := GetTransactionCached(ctx, client, signature, rpc.CommitmentConfirmed)
+	if err != nil {
+		t.Fatalf("获取交易失败: %v", err)
+	}
+	if got := misses() - before; got != 1 {
+		t.Errorf("缓存未命中计数增加 %v，期望 1", got)
+	}
+	if ttl := server.TTL(key); ttl != core.DefaultCacheTTL[core.CacheTransaction] {
+		t.Errorf("未 finalized 的交易缓存时间为 %v，期望 %v", ttl, core.DefaultCacheTTL[core.CacheTransaction])
+	}
+
+	// 再次读取命中进程内缓存
+	if _, err := GetTransactionCached(ctx, client, signature, rpc.CommitmentConfirmed); err != nil {
+		t.Fatalf("读取缓存失败: %v", err)
+	}
+	if got := misses() - before; got != 1 {
+		t.Errorf("命中缓存时不应增加未命中计数，实际增加 %v", got)
+	}
+
+	// 交易所在 slot 已经 finalized，永久缓存
+	server.FlushAll()
+	network.RPC.SetSlot(tx.Slot + 32)
+	global.Cache = core.NewCache(core.CacheConfig{}, global.Redis, nil)
+	if _, err := GetTransactionCached(ctx, client, signature, rpc.CommitmentConfirmed); err != nil {
+		t.Fatalf("获取交易失败: %v", err)
+	}
+	if !server.Exists(key) {
+		t.Fatal("交易未写入 Redis 缓存")
+	}
+	if ttl := server.TTL(key); ttl != 0 {
+		t.Errorf("finalized 交易缓存时间为 %v，期望永久缓存", ttl)
+	}
+	if got := network.RPC.Calls("getSlot"); got != 2 {
+		t.Errorf("getSlot 调用 %d 次，期望 2", got)
+	}
+
+	// 已知的 finalized slot 足够新时不再查询节点
+	global.Cache = core.NewCache(core.CacheConfig{}, nil, nil)
+	if _, err := GetTransactionCached(ctx, client, signature, rpc.CommitmentConfirmed); err != nil {
+		t.Fatalf("获取交易失败: %v", err)
+	}
+	if got := network.RPC.Calls("getSlot"); got != 2 {
+		t.Errorf("getSlot 调用 %d 次，期望仍为 2", got)
+	}
+}
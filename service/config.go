@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"meme/core"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and validate configuration",
+	Long: `Inspect and validate configuration.
+
+Every config key can be overridden by environment variable and by flag.
+Precedence is flag > env > file, e.g. redis.password is read from
+--redis.password, then MONITOR_REDIS_PASSWORD, then config.yml.`,
+	// 配置相关命令自行加载配置，避免根命令在校验失败时提前退出
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the effective configuration",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		if _, err := core.LoadConfig(path, cmd.Flags()); err != nil {
+			return err
+		}
+		fmt.Printf("配置文件 %s 校验通过\n", path)
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration with secrets redacted",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		config, err := core.LoadConfig(path, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(config.Redacted())
+		if err != nil {
+			return fmt.Errorf("序列化配置失败: %w", err)
+		}
+		fmt.Print(string(data))
+		return nil
+	},
+}
+
+func init() {
+	ConfigCmd.AddCommand(configValidateCmd)
+	ConfigCmd.AddCommand(configShowCmd)
+}
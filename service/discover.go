@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DefaultDiscoverMaxPages        = 100
+	DefaultDiscoverMaxTransactions = 5000
+	DefaultDiscoverEarlyBuyers     = 100
+	DefaultDiscoverTop             = 20
+)
+
+var DiscoverCmd = &cobra.Command{
+	Use:   "discover <mint>",
+	Short: "Find early buyers of a mint that sold at a profit",
+	Long: `Find early buyers of a mint that sold at a profit.
+
+The mint's signatures are paged back to its first transaction, the oldest
+--max-transactions are fetched and every signer's trades in the mint are
+parsed. Wallets among the first --early buyers are ranked by realized SOL
+profit, then by how early they entered. Wallets already watched, in the
+config file or the Redis monitor:addresses set, are marked. With --fixtures
+the transactions are read from a directory of getTransaction JSON files
+instead of RPC.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mint, err := solana.PublicKeyFromBase58(args[0])
+		if err != nil {
+			return fmt.Errorf("mint 地址无效 %q: %w", args[0], err)
+		}
+		flags := cmd.Flags()
+		var opts DiscoverOptions
+		opts.MaxPages, _ = flags.GetInt("max-pages")
+		opts.MaxTransactions, _ = flags.GetInt("max-transactions")
+		opts.EarlyBuyers, _ = flags.GetInt("early")
+		opts.MinProfitSOL, _ = flags.GetFloat64("min-profit")
+		opts.Top, _ = flags.GetInt("top")
+		opts.BatchSize, _ = flags.GetInt("batch-size")
+		opts.Concurrency, _ = flags.GetInt("concurrency")
+		opts.RPS, _ = flags.GetFloat64("rps")
+		fixtures, _ := flags.GetString("fixtures")
+
+		ctx := cmd.Context()
+		var transactions []*rpc.GetTransactionResult
+		if fixtures != "" {
+			transactions, err = LoadTransactionFixtures(fixtures)
+			if err != nil {
+				return err
+			}
+		} else {
+			discovery := NewDiscoveryService(global.RpcClient, core.NewConsoleLogger(slog.LevelWarn))
+			early, err := discovery.FetchEarlyTransactions(ctx, mint, opts)
+			if err != nil {
+				return err
+			}
+			if !early.Complete {
+				fmt.Printf("已获取 %d 页签名仍未到达第一笔交易，结果只包含其中最早的部分\n", opts.MaxPages)
+			}
+			fmt.Printf("共 %d 个签名，获取到最早的 %d 笔交易\n", early.Signatures, len(early.Transactions))
+			transactions = early.Transactions
+		}
+
+		// 监控列表包括配置文件与 Redis 集合中的地址，Redis 不可用时只使用配置文件
+		redisClient, err := core.InitRedis(global.RedisConfig, nil)
+		if redisClient != nil {
+			defer redisClient.Close()
+		}
+		watched, watchErr := watchedAddresses(ctx, redisClient)
+		if err := errors.Join(err, watchErr); err != nil {
+			fmt.Printf("读取 Redis 监控列表失败，只按配置文件标记已监控地址: %v\n", err)
+		}
+		candidates := RankEarlyBuyers(transactions, mint.String(), watched, opts)
+		fmt.Printf("已解析 %d 笔交易，找到 %d 个候选钱包\n", len(transactions), len(candidates))
+		printCandidates(os.Stdout, mint.String(), candidates)
+		return nil
+	},
+}
+
+// DiscoverOptions 表示发现钱包的范围、筛选条件与请求速度
+type DiscoverOptions struct {
+	MaxPages        int     // 最多获取的签名页数，每页 1000 个
+	MaxTransactions int     // 最多获取的最早交易数
+	EarlyBuyers     int     // 前多少个买入的钱包算作早期买家
+	MinProfitSOL    float64 // 最低已实现盈利
+	Top             int     // 最多输出的候选数，0 表示不限制
+	BatchSize       int
+	Concurrency     int
+	RPS             float64
+}
+
+// Candidate 表示一个早期买入并获利卖出的候选钱包
+type Candidate struct {
+	Wallet      string
+	EntryRank   int           // 第几个买入该代币的钱包，从 1 开始
+	EntryDelay  time.Duration // 首次买入距该代币第一笔交易的时间
+	Buys        int
+	Sells       int
+	CostSOL     float64 // 已卖出部分的买入成本
+	RealizedSOL float64
+	OpenAmount  uint64 // 未卖出的数量，最小单位
+	Watched     bool   // 已在监控列表中
+}
+
+// ROI 返回已实现收益率
+func (c Candidate) ROI() float64 {
+	if c.CostSOL <= 0 {
+		return 0
+	}
+	return c.RealizedSOL / c.CostSOL
+}
+
+// EarlyTransactions 表示获取到的代币早期交易与签名翻页情况
+type EarlyTransactions struct {
+	Transactions []*rpc.GetTransactionResult // 按时间正序排列
+	Signatures   int                         // 翻页获取到的签名数
+	Complete     bool                        // 是否已翻页到代币的第一笔交易
+}
+
+// DiscoveryService 获取代币的早期交易，用于发现值得跟单的钱包
+type DiscoveryService struct {
+	client *rpc.Client
+	logger *slog.Logger
+}
+
+// NewDiscoveryService 创建钱包发现服务
+func NewDiscoveryService(client *rpc.Client, logger *slog.Logger) *DiscoveryService {
+	return &DiscoveryService{
+		client: client,
+		logger: logger,
+	}
+}
+
+// FetchEarlyTransactions 向前翻页到代币的第一笔交易（或 MaxPages 页），获取最早的 MaxTransactions 笔成功交易，按时间正序返回
+func (s *DiscoveryService) FetchEarlyTransactions(ctx context.Context, mint solana.PublicKey, opts DiscoverOptions) (*EarlyTransactions, error) {
+	limiter := core.NewTokenBucket(opts.RPS, max(opts.BatchSize, 1))
+	var signatures []*rpc.TransactionSignature
+	var before solana.Signature
+	complete := false
+	for page := 0; page < max(opts.MaxPages, 1); page++ {
+		result, err := fetchSignaturePage(ctx, s.client, mint, before, solana.Signature{}, DefaultHistoryPageSize, limiter, s.logger)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, result...)
+		if len(result) < DefaultHistoryPageSize {
+			complete = true
+			break
+		}
+		before = result[len(result)-1].Signature
+	}
+	if !complete {
+		s.logger.Warn("签名翻页达到上限，未到达第一笔交易", "mint", mint.String(), "pages", opts.MaxPages)
+	}
+
+	// 签名从新到旧排列，取最早的成功交易
+	var early []solana.Signature
+	for i := len(signatures) - 1; i >= 0 && len(early) < max(opts.MaxTransactions, 1); i-- {
+		if signatures[i].Err == nil {
+			early = append(early, signatures[i].Signature)
+		}
+	}
+
+	transactions := make([]*rpc.GetTransactionResult, len(early))
+	index := make(map[solana.Signature]int, len(early))
+	for i, signature := range early {
+		index[signature] = i
+	}
+	var mu sync.Mutex
+	err := forEachBatch(ctx, early, max(opts.BatchSize, 1), max(opts.Concurrency, 1), func(ctx context.Context, batch []solana.Signature) error {
+		results, errs, err := fetchTransactionBatch(ctx, s.client, batch, limiter, s.logger)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for i, tx := range results {
+			if errs[i] != nil {
+				s.logger.Warn("获取交易失败", core.LogKeySignature, batch[i].String(), core.LogKeyError, errs[i])
+				continue
+			}
+			transactions[index[batch[i]]] = tx
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := transactions[:0]
+	for _, tx := range transactions {
+		if tx != nil {
+			fetched = append(fetched, tx)
+		}
+	}
+	return &EarlyTransactions{Transactions: fetched, Signatures: len(signatures), Complete: complete}, nil
+}
+
+// LoadTransactionFixtures 读取目录下的 getTransaction 结果 JSON 文件（如 transaction_demo.json），按 slot 排序返回
+func LoadTransactionFixtures(dir string) ([]*rpc.GetTransactionResult, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("目录 %s 下没有 JSON 文件", dir)
+	}
+	sort.Strings(paths)
+	var transactions []*rpc.GetTransactionResult
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var tx rpc.GetTransactionResult
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+		}
+		transactions = append(transactions, &tx)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool { return transactions[i].Slot < transactions[j].Slot })
+	return transactions, nil
+}
+
+// RankEarlyBuyers 解析按时间正序排列的交易中每个签名者对 mint 的买卖，
+// 筛选前 EarlyBuyers 个买家中已实现盈利不低于 MinProfitSOL 的钱包，按盈利从高到低、入场从早到晚排序。
+// watched 中的地址标记为已监控
+func RankEarlyBuyers(transactions []*rpc.GetTransactionResult, mint string, watched map[string]bool, opts DiscoverOptions) []Candidate {
+	var trades []core.Trade
+	var firstTrade time.Time
+	entries := make(map[string]int)
+	entryTimes := make(map[string]time.Time)
+	for _, tx := range transactions {
+		parsed, err := tx.Transaction.GetTransaction()
+		if err != nil || len(parsed.Message.AccountKeys) == 0 {
+			continue
+		}
+		// 交易的签名者（手续费支付者）视为交易者
+		signer := parsed.Message.AccountKeys[0].String()
+		legs, err := ParseTrades(tx, signer)
+		if err != nil {
+			continue
+		}
+		for _, leg := range legs {
+			if leg.Mint != mint {
+				continue
+			}
+			if firstTrade.IsZero() {
+				firstTrade = leg.BlockTime
+			}
+			if _, ok := entries[signer]; !ok && leg.Direction == core.TradeDirectionBuy {
+				entries[signer] = len(entries) + 1
+				entryTimes[signer] = leg.BlockTime
+			}
+			trades = append(trades, leg)
+		}
+	}
+
+	var candidates []Candidate
+	for _, wallet := range ComputePnL(trades) {
+		rank, ok := entries[wallet.Wallet]
+		if !ok || (opts.EarlyBuyers > 0 && rank > opts.EarlyBuyers) {
+			continue
+		}
+		if wallet.Closed == 0 || wallet.RealizedSOL < opts.MinProfitSOL {
+			continue
+		}
+		candidate := Candidate{
+			Wallet:      wallet.Wallet,
+			EntryRank:   rank,
+			EntryDelay:  entryTimes[wallet.Wallet].Sub(firstTrade),
+			RealizedSOL: wallet.RealizedSOL,
+			Watched:     watched[wallet.Wallet],
+		}
+		for _, m := range wallet.Mints {
+			candidate.Buys += m.Buys
+			candidate.Sells += m.Sells
+			candidate.OpenAmount += m.OpenAmount
+			for _, closed := range m.closed {
+				candidate.CostSOL += closed.CostSOL
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].RealizedSOL != candidates[j].RealizedSOL {
+			return candidates[i].RealizedSOL > candidates[j].RealizedSOL
+		}
+		return candidates[i].EntryRank < candidates[j].EntryRank
+	})
+	if opts.Top > 0 && len(candidates) > opts.Top {
+		candidates = candidates[:opts.Top]
+	}
+	return candidates
+}
+
+// watchedAddresses 返回配置文件与 Redis 集合 monitor:addresses 中的监控地址，与 monitor 的监控列表一致。
+// Redis 不可用时返回配置文件中的地址与错误
+func watchedAddresses(ctx context.Context, redisClient *core.RedisClient) (map[string]bool, error) {
+	watched := make(map[string]bool)
+	for _, wallet := range global.SystemConfig().WatchList() {
+		watched[wallet.Address] = true
+	}
+	if !redisClient.Available() {
+		return watched, nil
+	}
+	addresses, err := redisClient.WatchList(ctx)
+	if err != nil {
+		return watched, err
+	}
+	for _, address := range addresses {
+		watched[address] = true
+	}
+	return watched, nil
+}
+
+// printCandidates 输出候选钱包，以及可以直接加入 system.wallets 的配置
+func printCandidates(out io.Writer, mint string, candidates []Candidate) {
+	if len(candidates) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WALLET\tENTRY\tDELAY\tBUYS\tSELLS\tREALIZED SOL\tROI\tNOTE")
+	for _, c := range candidates {
+		var note string
+		if c.Watched {
+			note = "已监控"
+		}
+		fmt.Fprintf(w, "%s\t#%d\t%s\t%d\t%d\t%+.4f\t%.0f%%\t%s\n", c.Wallet, c.EntryRank, c.EntryDelay.Round(time.Second),
+			c.Buys, c.Sells, c.RealizedSOL, c.ROI()*100, note)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\n# 可加入 system.wallets:")
+	for i, c := range candidates {
+		if c.Watched {
+			continue
+		}
+		fmt.Fprintf(out, "  - address: %s\n    label: discovered-%s-%d\n    tags: [discovered]\n", c.Wallet, mint[:4], i+1)
+	}
+}
+
+func init() {
+	flags := DiscoverCmd.Flags()
+	flags.Int("max-pages", DefaultDiscoverMaxPages, "最多获取的签名页数，每页 1000 个")
+	flags.Int("max-transactions", DefaultDiscoverMaxTransactions, "最多获取的最早交易数")
+	flags.Int("early", DefaultDiscoverEarlyBuyers, "前多少个买入的钱包算作早期买家，0 表示不限制")
+	flags.Float64("min-profit", 0, "最低已实现盈利 (SOL)")
+	flags.Int("top", DefaultDiscoverTop, "最多输出的候选数，0 表示不限制")
+	flags.Int("batch-size", DefaultHistoryBatchSize, "单个批量请求包含的交易数")
+	flags.Int("concurrency", DefaultHistoryConcurrency, "同时发送的批量请求数")
+	flags.Float64("rps", 0, "每秒最多请求的交易数，0 表示只受 rpc 配置的限流")
+	flags.String("fixtures", "", "从目录读取 getTransaction JSON 文件，不请求 RPC")
+}
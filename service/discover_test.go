@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"meme/core"
+	"meme/global"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestRankEarlyBuyers(t *testing.T) {
+	result, _ := loadDemoTransaction(t)
+	mint := result.Meta.PreTokenBalances[2].Mint.String()
+	a, b, c, d := solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey(), solana.NewWallet().PublicKey()
+	sol := solana.LAMPORTS_PER_SOL
+
+	// 买入顺序 a、b、c、d，均以 1 SOL 买入后卖出
+	transactions := []*rpc.GetTransactionResult{
+		swapFixture(t, a, 1, true, 1000, sol),
+		swapFixture(t, b, 2, true, 1000, sol),
+		swapFixture(t, c, 3, true, 1000, sol),
+		swapFixture(t, d, 4, true, 1000, sol),
+		swapFixture(t, a, 10, false, 1000, 3*sol),   // 盈利 2
+		swapFixture(t, b, 11, false, 1000, sol/2*3), // 盈利 0.5
+		swapFixture(t, c, 12, false, 1000, 5*sol),   // 盈利 4
+		swapFixture(t, d, 13, false, 1000, 11*sol),  // 盈利 10
+	}
+	watched := map[string]bool{a.String(): true}
+
+	tests := []struct {
+		name string
+		opts DiscoverOptions
+		want []solana.PublicKey
+	}{
+		{"前 3 个买家且盈利不低于 1", DiscoverOptions{EarlyBuyers: 3, MinProfitSOL: 1}, []solana.PublicKey{c, a}},
+		{"不限制买家", DiscoverOptions{MinProfitSOL: 1}, []solana.PublicKey{d, c, a}},
+		{"不限制盈利", DiscoverOptions{EarlyBuyers: 2}, []solana.PublicKey{a, b}},
+		{"只输出前 1 个", DiscoverOptions{Top: 1}, []solana.PublicKey{d}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := RankEarlyBuyers(transactions, mint, watched, tt.opts)
+			if len(candidates) != len(tt.want) {
+				t.Fatalf("候选为 %+v，期望 %v", candidates, tt.want)
+			}
+			for i, candidate := range candidates {
+				if candidate.Wallet != tt.want[i].String() {
+					t.Errorf("第 %d 个候选为 %s，期望 %s", i, candidate.Wallet, tt.want[i])
+				}
+			}
+		})
+	}
+
+	candidates := RankEarlyBuyers(transactions, mint, watched, DiscoverOptions{EarlyBuyers: 3, MinProfitSOL: 1})
+	c0, a0 := candidates[0], candidates[1]
+	if c0.EntryRank != 3 || c0.Watched || c0.Buys != 1 || c0.Sells != 1 {
+		t.Errorf("候选 c 为 %+v，期望第 3 个买入、未监控、1 买 1 卖", c0)
+	}
+	if a0.EntryRank != 1 || !a0.Watched || a0.EntryDelay != 0 {
+		t.Errorf("候选 a 为 %+v，期望第 1 个买入、已监控", a0)
+	}
+	if !approxEqual(a0.RealizedSOL, 2) || !approxEqual(a0.ROI(), 2) {
+		t.Errorf("候选 a 盈利 %v、收益率 %v，期望 2、2", a0.RealizedSOL, a0.ROI())
+	}
+	if c0.EntryDelay != 2*time.Second {
+		t.Errorf("候选 c 入场延迟 %v，期望 2s", c0.EntryDelay)
+	}
+}
+
+// TestWatchedAddresses 校验已监控地址合并配置文件与 Redis 集合，Redis 不可用时只使用配置文件
+func TestWatchedAddresses(t *testing.T) {
+	server := useMiniRedis(t)
+	previous := global.SystemConfig()
+	t.Cleanup(func() { global.SetSystemConfig(previous) })
+	fromConfig, fromRedis := solana.NewWallet().PublicKey().String(), solana.NewWallet().PublicKey().String()
+	global.SetSystemConfig(core.SystemConfig{Wallets: []core.WalletConfig{{Address: fromConfig}}})
+	server.SAdd(core.WatchListRedisKey, fromRedis, "invalid")
+
+	ctx := context.Background()
+	watched, err := watchedAddresses(ctx, global.Redis)
+	if err != nil {
+		t.Fatalf("读取监控地址失败: %v", err)
+	}
+	if len(watched) != 2 || !watched[fromConfig] || !watched[fromRedis] {
+		t.Errorf("监控地址为 %v，期望配置文件与 Redis 各 1 个", watched)
+	}
+
+	// nil 客户端视为不可用
+	if watched, err := watchedAddresses(ctx, nil); err != nil || len(watched) != 1 || !watched[fromConfig] {
+		t.Errorf("Redis 不可用时监控地址为 %v, %v，期望只有配置文件中的地址", watched, err)
+	}
+}
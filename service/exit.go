@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+const DefaultExitInterval = 15 * time.Second
+
+// exitTrader 为持仓定价并卖出，卖出后更新持仓，由 FollowTransactionService 实现
+type exitTrader interface {
+	Price(ctx context.Context, mint solana.PublicKey, amount uint64) (float64, error)
+	Sell(ctx context.Context, mint solana.PublicKey, amount uint64) (*SendResult, error)
+}
+
+// ExitWorker 定期为跟单持仓定价，按止损、止盈阶梯与移动止损规则卖出
+type ExitWorker struct {
+	follow    exitTrader
+	positions *PositionStore
+	logger    *slog.Logger
+	config    core.ExitConfig
+}
+
+// NewExitWorker 根据 follow.exit 配置创建退出任务
+func NewExitWorker(follow *FollowTransactionService, logger *slog.Logger) *ExitWorker {
+	config := global.FollowConfig.Exit
+	// 复制后排序，不修改全局配置中的切片
+	config.TakeProfit = slices.Clone(config.TakeProfit)
+	sort.Slice(config.TakeProfit, func(i, j int) bool {
+		return config.TakeProfit[i].Multiple < config.TakeProfit[j].Multiple
+	})
+	return &ExitWorker{
+		follow:    follow,
+		positions: follow.positions,
+		logger:    logger,
+		config:    config,
+	}
+}
+
+// Run 按配置的间隔检查全部持仓，直到 ctx 结束
+func (w *ExitWorker) Run(ctx context.Context) {
+	interval := time.Duration(w.config.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = DefaultExitInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.logger.Info("持仓退出任务启动", "interval", interval)
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("持仓退出任务停止")
+			return
+		case <-ticker.C:
+			// 停止信号不打断正在进行的检查与卖出
+			w.checkAll(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// checkAll 检查全部持仓
+func (w *ExitWorker) checkAll(ctx context.Context) {
+	positions, err := w.positions.List(ctx)
+	if err != nil {
+		w.logger.Error("读取持仓失败", core.LogKeyError, err)
+		return
+	}
+	for _, position := range positions {
+		if err := w.check(ctx, position); err != nil {
+			w.logger.Error("处理持仓失败", core.LogKeyMint, position.Mint, core.LogKeyError, err)
+		}
+	}
+}
+
+// check 为单个持仓定价并执行触发的退出规则
+func (w *ExitWorker) check(ctx context.Context, position *Position) error {
+	mint, err := solana.PublicKeyFromBase58(position.Mint)
+	if err != nil {
+		return fmt.Errorf("mint 地址无效: %w", err)
+	}
+	price, err := w.follow.Price(ctx, mint, position.Amount)
+	if err != nil {
+		return err
+	}
+	if price > position.HighPrice {
+		position.HighPrice = price
+		_, err := w.positions.Update(ctx, position.Mint, func(current *Position) *Position {
+			if current == nil || price <= current.HighPrice {
+				return nil
+			}
+			current.HighPrice = price
+			current.UpdatedAt = time.Now()
+			return current
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// 价格一次越过多档止盈时逐档卖出，直到没有规则触发
+	for {
+		amount, reason := w.evaluate(position, price)
+		if amount == 0 {
+			return nil
+		}
+
+		w.logger.Info("持仓触发"+reason, core.LogKeyMint, position.Mint, "price", price, "entry_price", position.EntryPrice(), "amount", amount)
+		result, err := w.follow.Sell(ctx, mint, amount)
+		if err != nil {
+			return fmt.Errorf("%s卖出失败: %w", reason, err)
+		}
+		w.logger.Info("持仓"+reason+"卖出成功", core.LogKeyMint, position.Mint, core.LogKeyCopySignature, result.Signature, core.LogKeyCopySlot, result.Slot)
+		if reason != "止盈" {
+			return nil
+		}
+
+		hit := position.TakeProfitHit + 1
+		position, err = w.positions.Update(ctx, position.Mint, func(current *Position) *Position {
+			if current == nil {
+				return nil
+			}
+			current.TakeProfitHit = max(current.TakeProfitHit, hit)
+			return current
+		})
+		if err != nil || position == nil {
+			return err
+		}
+	}
+}
+
+// evaluate 返回应卖出的数量及触发原因，不需要卖出时返回 0
+func (w *ExitWorker) evaluate(position *Position, price float64) (uint64, string) {
+	entry := position.EntryPrice()
+	if entry <= 0 || price <= 0 {
+		return 0, ""
+	}
+
+	if w.config.StopLossPct > 0 && price <= entry*(1-w.config.StopLossPct/100) {
+		return position.Amount, "止损"
+	}
+	if w.config.TrailingStopPct > 0 && position.HighPrice > entry && price <= position.HighPrice*(1-w.config.TrailingStopPct/100) {
+		return position.Amount, "移动止损"
+	}
+
+	if position.TakeProfitHit < len(w.config.TakeProfit) {
+		level := w.config.TakeProfit[position.TakeProfitHit]
+		if price >= entry*level.Multiple {
+			if position.TakeProfitHit == len(w.config.TakeProfit)-1 {
+				return position.Amount, "止盈"
+			}
+			amount := uint64(float64(position.InitialAmount) * level.SellPct / 100)
+			if amount > position.Amount {
+				amount = position.Amount
+			}
+			return amount, "止盈"
+		}
+	}
+	return 0, ""
+}
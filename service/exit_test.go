@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// fakeExitTrader 按固定价格报价，卖出时像跟单服务一样减少持仓
+type fakeExitTrader struct {
+	positions *PositionStore
+	price     float64
+	sells     []uint64
+}
+
+func (f *fakeExitTrader) Price(ctx context.Context, mint solana.PublicKey, amount uint64) (float64, error) {
+	return f.price, nil
+}
+
+func (f *fakeExitTrader) Sell(ctx context.Context, mint solana.PublicKey, amount uint64) (*SendResult, error) {
+	f.sells = append(f.sells, amount)
+	return &SendResult{}, f.positions.Reduce(ctx, mint, amount)
+}
+
+// TestExitWorkerTakeProfitLevels 校验价格一次越过多档止盈时逐档卖出，且排序不修改全局配置
+func TestExitWorkerTakeProfitLevels(t *testing.T) {
+	useMiniRedis(t)
+	previous := global.FollowConfig
+	t.Cleanup(func() { global.FollowConfig = previous })
+	levels := []core.TakeProfitLevel{{Multiple: 5, SellPct: 50}, {Multiple: 3, SellPct: 25}, {Multiple: 2, SellPct: 25}}
+	global.FollowConfig.Exit = core.ExitConfig{Enabled: true, TakeProfit: levels}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	worker := NewExitWorker(NewFollowTransactionService(nil, logger), logger)
+	if levels[0].Multiple != 5 || levels[2].Multiple != 2 {
+		t.Fatalf("全局止盈配置被重新排序: %+v", levels)
+	}
+
+	ctx := context.Background()
+	mint := solana.NewWallet().PublicKey()
+	position, err := worker.positions.Open(ctx, mint, 1000, 1000)
+	if err != nil {
+		t.Fatalf("建仓失败: %v", err)
+	}
+	// 成本价 1，价格 4 越过 2 倍与 3 倍两档，未到 5 倍
+	trader := &fakeExitTrader{positions: worker.positions, price: 4}
+	worker.follow = trader
+	if err := worker.check(ctx, position); err != nil {
+		t.Fatalf("检查持仓失败: %v", err)
+	}
+	if len(trader.sells) != 2 || trader.sells[0] != 250 || trader.sells[1] != 250 {
+		t.Errorf("卖出 %v，期望两档各 250", trader.sells)
+	}
+	current, err := worker.positions.Get(ctx, mint.String())
+	if err != nil || current == nil {
+		t.Fatalf("读取持仓失败: %v, %+v", err, current)
+	}
+	if current.TakeProfitHit != 2 || current.Amount != 500 {
+		t.Errorf("持仓止盈档位 %d、剩余 %d，期望 2、500", current.TakeProfitHit, current.Amount)
+	}
+
+	// 价格不变时不再卖出
+	if err := worker.check(ctx, current); err != nil {
+		t.Fatalf("检查持仓失败: %v", err)
+	}
+	if len(trader.sells) != 2 {
+		t.Errorf("未触发新档位时卖出 %v", trader.sells)
+	}
+
+	// 越过最后一档时卖出剩余全部并清仓
+	trader.price = 6
+	if err := worker.check(ctx, current); err != nil {
+		t.Fatalf("检查持仓失败: %v", err)
+	}
+	if len(trader.sells) != 3 || trader.sells[2] != 500 {
+		t.Errorf("卖出 %v，期望最后一档卖出剩余 500", trader.sells)
+	}
+	if current, err := worker.positions.Get(ctx, mint.String()); err != nil || current != nil {
+		t.Errorf("清仓后持仓为 %+v, %v", current, err)
+	}
+}
@@ -4,35 +4,59 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"meme/core"
 	"meme/global"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
-	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
 )
 
+// 持仓写入失败时的重试次数与间隔
+const (
+	positionUpdateAttempts = 3
+	positionRetryInterval  = time.Second
+)
+
 type FollowTransactionService struct {
-	client *rpc.Client
-	logger *log.Logger
+	client    *rpc.Client
+	logger    *slog.Logger
+	sender    *TransactionSender
+	swapper   SwapBuilder
+	guard     *SlippageGuard
+	positions *PositionStore
+}
+
+func NewFollowTransactionService(client *rpc.Client, logger *slog.Logger) *FollowTransactionService {
+	return &FollowTransactionService{
+		client:    client,
+		logger:    logger,
+		sender:    NewTransactionSender(client, logger),
+		swapper:   NewSwapBuilder(client, logger),
+		guard:     NewSlippageGuard(logger),
+		positions: NewPositionStore(),
+	}
 }
 
-func NewFollowTransactionService(client *rpc.Client, logger *log.Logger) *FollowTransactionService {
-	return &FollowTransactionService{client: client, logger: logger}
+// SetSwapBuilder 替换跟单使用的报价与兑换构造器，默认按 follow.router 创建
+func (fts *FollowTransactionService) SetSwapBuilder(swapper SwapBuilder) {
+	fts.swapper = swapper
 }
 
 // FollowAndSend 根据指定 mint 筛选交易并跟单
-func (fts *FollowTransactionService) FollowAndSend(address solana.PublicKey, signatureStr string, followMint solana.PublicKey, destination solana.PublicKey) error {
+func (fts *FollowTransactionService) FollowAndSend(ctx context.Context, address solana.PublicKey, signatureStr string, followMint solana.PublicKey) error {
 	signature, err := solana.SignatureFromBase58(signatureStr)
 	if err != nil {
 		return fmt.Errorf("解析签名失败: %v", err)
 	}
 
 	// 获取交易详情
-	txDetails, err := fts.client.GetTransaction(context.TODO(), signature, nil)
+	txDetails, err := GetTransactionCached(ctx, fts.client, signature, rpc.CommitmentConfirmed)
 	if err != nil {
 		if rpcErr, ok := err.(*jsonrpc.RPCError); ok {
-			fts.logger.Printf("RPC 错误: %s", rpcErr.Message)
+			fts.logger.Warn("RPC 错误", "code", rpcErr.Code, "message", rpcErr.Message)
 		}
 		return fmt.Errorf("获取交易详情失败: %v", err)
 	}
@@ -47,63 +71,209 @@ func (fts *FollowTransactionService) FollowAndSend(address solana.PublicKey, sig
 	}
 
 	if tokenAmount == "" {
-		fts.logger.Println("未找到符合条件的交易记录")
+		fts.logger.Info("未找到符合条件的交易记录", core.LogKeyMint, followMint)
 		return nil
 	}
 
-	// 构造交易（转移 Token）
-	return fts.createAndSendTransaction(followMint, address, destination, tokenAmount)
+	// 签名前校验滑点与价格偏离
+	quote, ok := fts.checkSlippage(ctx, signatureStr, txDetails, address, followMint)
+	if !ok {
+		return nil
+	}
+
+	// 构造并发送兑换交易
+	result, err := fts.createAndSendTransaction(ctx, quote, core.TradeKindFollow)
+	if err != nil {
+		return err
+	}
+	fts.updatePosition(ctx, quote, result)
+	return nil
+}
+
+// Sell 按最新报价卖出指定数量的代币，供持仓退出使用
+func (fts *FollowTransactionService) Sell(ctx context.Context, mint solana.PublicKey, amount uint64) (*SendResult, error) {
+	if fts.swapper == nil {
+		return nil, fmt.Errorf("未配置报价源")
+	}
+	quote, err := fts.swapper.Quote(ctx, mint, solana.SolMint, amount, fts.guard.MaxSlippageBps)
+	if err != nil {
+		return nil, fmt.Errorf("获取报价失败: %v", err)
+	}
+	quote.SlippageBps = fts.guard.MaxSlippageBps
+	quote.MinOutAmount = minOutAmount(quote.OutAmount, fts.guard.MaxSlippageBps)
+
+	result, err := fts.createAndSendTransaction(ctx, quote, core.TradeKindExit)
+	if err != nil {
+		return nil, err
+	}
+	fts.updatePosition(ctx, quote, result)
+	return result, nil
+}
+
+// Price 通过报价源为持仓定价，返回每最小单位代币对应的 lamports
+func (fts *FollowTransactionService) Price(ctx context.Context, mint solana.PublicKey, amount uint64) (float64, error) {
+	if fts.swapper == nil {
+		return 0, fmt.Errorf("未配置报价源")
+	}
+	if amount == 0 {
+		return 0, fmt.Errorf("持仓数量为 0")
+	}
+	quote, err := fts.swapper.Quote(ctx, mint, solana.SolMint, amount, fts.guard.MaxSlippageBps)
+	if err != nil {
+		return 0, fmt.Errorf("获取报价失败: %v", err)
+	}
+	return float64(quote.OutAmount) / float64(quote.InAmount), nil
+}
+
+// updatePosition 按已确认交易中钱包的实际成交数量更新持仓，获取成交失败时退回使用报价数量。
+// Redis 写入失败时重试，仍失败则记录错误与成交数量，需人工补录
+func (fts *FollowTransactionService) updatePosition(ctx context.Context, quote *Quote, result *SendResult) {
+	if result == nil {
+		return
+	}
+	buy := quote.InputMint.Equals(solana.SolMint)
+	mint, tokenAmount, lamports := quote.OutputMint, quote.OutAmount, quote.InAmount
+	if !buy {
+		mint, tokenAmount, lamports = quote.InputMint, quote.InAmount, quote.OutAmount
+	}
+	if fill, err := fts.confirmedFill(ctx, result.Signature, mint); err != nil {
+		fts.logger.Warn("获取实际成交失败，按报价数量记录持仓", core.LogKeyCopySignature, result.Signature, core.LogKeyError, err)
+	} else {
+		tokenAmount, lamports = fill.TokenAmount, fill.SolAmount
+	}
+
+	var err error
+	for attempt := 1; attempt <= positionUpdateAttempts; attempt++ {
+		if buy {
+			_, err = fts.positions.Open(ctx, mint, tokenAmount, lamports)
+		} else {
+			err = fts.positions.Reduce(ctx, mint, tokenAmount)
+		}
+		if err == nil {
+			return
+		}
+		if attempt == positionUpdateAttempts || ctx.Err() != nil {
+			break
+		}
+		fts.logger.Warn("更新持仓失败，稍后重试", core.LogKeyCopySignature, result.Signature, "attempt", attempt, core.LogKeyError, err)
+		time.Sleep(positionRetryInterval)
+	}
+	fts.logger.Error("更新持仓失败，需人工补录", core.LogKeyCopySignature, result.Signature, core.LogKeyMint, mint,
+		"buy", buy, "token_amount", tokenAmount, "lamports", lamports, core.LogKeyError, err)
+}
+
+// confirmedFill 从已确认的跟单交易中解析本钱包对 mint 的实际成交
+func (fts *FollowTransactionService) confirmedFill(ctx context.Context, signature solana.Signature, mint solana.PublicKey) (*LeaderFill, error) {
+	wallet, err := solana.PrivateKeyFromBase58(global.SystemConfig().PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("加载钱包失败: %v", err)
+	}
+	tx, err := GetTransactionCached(ctx, fts.client, signature, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	return parseLeaderFill(tx, wallet.PublicKey(), mint)
 }
 
-func (fts *FollowTransactionService) createAndSendTransaction(mint solana.PublicKey, source solana.PublicKey, destination solana.PublicKey, amount string) error {
+// checkSlippage 获取最新报价并校验，未通过时记录拒绝原因。跟单金额与滑点按地址的 follow 设置调整
+func (fts *FollowTransactionService) checkSlippage(ctx context.Context, signature string, txDetails *rpc.GetTransactionResult, leader solana.PublicKey, mint solana.PublicKey) (*Quote, bool) {
+	wallet, _ := global.SystemConfig().Wallet(leader.String())
+	guard := fts.guard.ForWallet(wallet)
+
+	fill, err := parseLeaderFill(txDetails, leader, mint)
+	if err != nil {
+		guard.Reject(ctx, signature, nil, nil, fmt.Sprintf("解析跟单成交失败: %v", err))
+		return nil, false
+	}
+	// 持仓保存在 Redis 中，降级运行时无法记录持仓，跟单后也不会触发止盈止损
+	if !global.Redis.Available() {
+		guard.Reject(ctx, signature, fill, nil, "Redis 不可用，无法记录跟单持仓")
+		return nil, false
+	}
+	if fts.swapper == nil {
+		guard.Reject(ctx, signature, fill, nil, "未配置报价源")
+		return nil, false
+	}
+
+	inputMint, outputMint, amount := solana.SolMint, mint, fill.SolAmount
+	if fill.Side == TradeSideSell {
+		inputMint, outputMint, amount = mint, solana.SolMint, fill.TokenAmount
+	}
+	if wallet.Follow.Ratio > 0 {
+		amount = uint64(float64(amount) * wallet.Follow.Ratio)
+	}
+	if fill.Side == TradeSideSell {
+		// 跟随卖出不超过本钱包记录的持仓，没有持仓时不跟随
+		position, err := fts.positions.Get(ctx, mint.String())
+		if err != nil {
+			guard.Reject(ctx, signature, fill, nil, fmt.Sprintf("读取持仓失败: %v", err))
+			return nil, false
+		}
+		if position == nil || position.Amount == 0 {
+			guard.Reject(ctx, signature, fill, nil, "没有该代币的持仓，不跟随卖出")
+			return nil, false
+		}
+		amount = min(amount, position.Amount)
+	}
+	quote, err := fts.swapper.Quote(ctx, inputMint, outputMint, amount, guard.MaxSlippageBps)
+	if err != nil {
+		guard.Reject(ctx, signature, fill, nil, fmt.Sprintf("获取报价失败: %v", err))
+		return nil, false
+	}
+
+	if reason, ok := guard.Check(fill, quote); !ok {
+		guard.Reject(ctx, signature, fill, quote, reason)
+		return nil, false
+	}
+	return quote, true
+}
+
+// createAndSendTransaction 根据报价构造兑换交易，交给发送器签名并等待确认，kind 为跟单交易类型
+func (fts *FollowTransactionService) createAndSendTransaction(ctx context.Context, quote *Quote, kind string) (*SendResult, error) {
 	// 加载钱包密钥对
-	wallet, err := solana.PrivateKeyFromBase58("<你的钱包私钥>")
+	wallet, err := solana.PrivateKeyFromBase58(global.SystemConfig().PrivateKey)
 	if err != nil {
-		return fmt.Errorf("加载钱包失败: %v", err)
-	}
-
-	// 构造交易
-	tx, err := solana.NewTransaction(
-		[]solana.Instruction{
-			token.NewTransferCheckedInstruction(
-				uint64(1000), // 转移的数量（按最小单位）; 可根据需要转换 `amount` 为 uint64
-				uint8(3),
-
-				source,
-				mint, // Token 的 mint 地址
-				destination,
-				wallet.PublicKey(),
-				[]solana.PublicKey{wallet.PublicKey()},
-			).Build(),
-		},
-		solana.Hash(solana.MustPublicKeyFromBase58("<最近的区块哈希>")),
-	)
+		return nil, fmt.Errorf("加载钱包失败: %v", err)
+	}
+
+	plan, err := fts.swapper.BuildSwap(ctx, quote, wallet.PublicKey())
 	if err != nil {
-		return fmt.Errorf("构造交易失败: %v", err)
+		return nil, fmt.Errorf("构造兑换交易失败: %v", err)
 	}
 
-	// 签名并发送交易
-	_, err = fts.client.SendTransactionWithOpts(context.TODO(), tx, rpc.TransactionOpts{})
+	var opts []solana.TransactionOption
+	if len(plan.AddressTables) > 0 {
+		opts = append(opts, solana.TransactionAddressTables(plan.AddressTables))
+	}
+
+	// 签名、广播并等待确认
+	core.CopyTradesSent.WithLabelValues(kind).Inc()
+	result, err := fts.sender.Send(ctx, wallet, plan.Instructions, opts...)
 	if err != nil {
-		return fmt.Errorf("发送交易失败: %v", err)
+		return nil, fmt.Errorf("发送交易失败: %v", err)
 	}
+	if result.Err != nil {
+		return nil, fmt.Errorf("跟单交易 %s 执行失败: %v", result.Signature, result.Err)
+	}
+	core.CopyTradesLanded.WithLabelValues(kind).Inc()
 
-	fts.logger.Printf("成功发送跟单交易: %s -> %s (数量: %s)", source, destination, amount)
-	return nil
+	fts.logger.Info("成功发送跟单交易", "kind", kind, "input_mint", quote.InputMint, "output_mint", quote.OutputMint,
+		"in_amount", quote.InAmount, "min_out_amount", quote.MinOutAmount,
+		core.LogKeyCopySignature, result.Signature, core.LogKeyCopySlot, result.Slot, "fee", result.Fee)
+	return result, nil
 }
 
 func test() {
-	logger := log.Default()
 	client := global.RpcClient
-	service := NewFollowTransactionService(client, logger)
+	service := NewFollowTransactionService(client, slog.Default())
 
 	err := service.FollowAndSend(
+		context.Background(),
 		solana.MustPublicKeyFromBase58("<钱包地址>"),
 		"<目标签名>",
 		solana.MustPublicKeyFromBase58("<目标 Mint 地址>"),
-		solana.MustPublicKeyFromBase58("<接收地址>"),
 	)
 	if err != nil {
-		logger.Fatalf("跟单交易失败: %v", err)
+		log.Fatalf("跟单交易失败: %v", err)
 	}
 }
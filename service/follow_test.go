@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"strconv"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// fakeSwapper 按固定价格报价并记录报价数量
+type fakeSwapper struct {
+	price   uint64 // 每最小单位代币对应的 lamports
+	amounts []uint64
+}
+
+func (f *fakeSwapper) Quote(ctx context.Context, inputMint, outputMint solana.PublicKey, amount uint64, slippageBps uint16) (*Quote, error) {
+	f.amounts = append(f.amounts, amount)
+	out := amount * f.price
+	if inputMint.Equals(solana.SolMint) {
+		out = amount / f.price
+	}
+	return &Quote{InputMint: inputMint, OutputMint: outputMint, InAmount: amount, OutAmount: out}, nil
+}
+
+func (f *fakeSwapper) BuildSwap(ctx context.Context, quote *Quote, user solana.PublicKey) (*SwapPlan, error) {
+	return &SwapPlan{}, nil
+}
+
+// sellFixture 构造 seller 以 lamports 卖出 tokens 个 mint 的交易详情
+func sellFixture(t *testing.T, seller, mint solana.PublicKey, tokens, lamports uint64) *rpc.GetTransactionResult {
+	t.Helper()
+	instruction := solana.NewInstruction(solana.SystemProgramID, solana.AccountMetaSlice{solana.Meta(seller).WRITE().SIGNER()}, nil)
+	tx, err := solana.NewTransaction([]solana.Instruction{instruction}, solana.Hash{}, solana.TransactionPayer(seller))
+	if err != nil {
+		t.Fatalf("构造交易失败: %v", err)
+	}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("序列化交易失败: %v", err)
+	}
+	result := &rpc.GetTransactionResult{Meta: &rpc.TransactionMeta{
+		PreBalances:       []uint64{solana.LAMPORTS_PER_SOL, 1},
+		PostBalances:      []uint64{solana.LAMPORTS_PER_SOL + lamports, 1},
+		PreTokenBalances:  []rpc.TokenBalance{{Mint: mint, Owner: &seller, UiTokenAmount: &rpc.UiTokenAmount{Amount: strconv.FormatUint(tokens, 10)}}},
+		PostTokenBalances: []rpc.TokenBalance{{Mint: mint, Owner: &seller, UiTokenAmount: &rpc.UiTokenAmount{Amount: "0"}}},
+	}}
+	envelope, _ := json.Marshal([]string{base64.StdEncoding.EncodeToString(data), "base64"})
+	if err := json.Unmarshal(envelope, &result.Transaction); err != nil {
+		t.Fatalf("构造交易失败: %v", err)
+	}
+	return result
+}
+
+// TestCheckSlippageSellCappedByPosition 校验跟随卖出不超过本钱包的持仓，没有持仓时不跟随
+func TestCheckSlippageSellCappedByPosition(t *testing.T) {
+	useMiniRedis(t)
+	previous := global.SystemConfig()
+	t.Cleanup(func() { global.SetSystemConfig(previous) })
+	leader := solana.NewWallet().PublicKey()
+	global.SetSystemConfig(core.SystemConfig{Wallets: []core.WalletConfig{{Address: leader.String()}}})
+
+	// 被跟单地址以每单位 1e6 lamports 卖出 1000 个代币
+	mint := solana.NewWallet().PublicKey()
+	tx := sellFixture(t, leader, mint, 1000, solana.LAMPORTS_PER_SOL)
+	swapper := &fakeSwapper{price: 1_000_000}
+	service := NewFollowTransactionService(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	service.SetSwapBuilder(swapper)
+	ctx := context.Background()
+
+	if quote, ok := service.checkSlippage(ctx, "sig", tx, leader, mint); ok || quote != nil {
+		t.Fatalf("没有持仓时跟随卖出: %+v", quote)
+	}
+	if len(swapper.amounts) != 0 {
+		t.Errorf("没有持仓时仍请求报价 %v", swapper.amounts)
+	}
+
+	if _, err := service.positions.Open(ctx, mint, 400, 400_000_000); err != nil {
+		t.Fatalf("建仓失败: %v", err)
+	}
+	quote, ok := service.checkSlippage(ctx, "sig", tx, leader, mint)
+	if !ok || quote.InAmount != 400 {
+		t.Errorf("持仓 400 时卖出报价为 %+v, %v，期望卖出 400", quote, ok)
+	}
+
+	if _, err := service.positions.Open(ctx, mint, 5000, 5_000_000_000); err != nil {
+		t.Fatalf("加仓失败: %v", err)
+	}
+	quote, ok = service.checkSlippage(ctx, "sig", tx, leader, mint)
+	if !ok || quote.InAmount != 1000 {
+		t.Errorf("持仓充足时卖出报价为 %+v, %v，期望跟随卖出 1000", quote, ok)
+	}
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"meme/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DefaultHistoryPageSize    = 1000 // getSignaturesForAddress 单页上限
+	DefaultHistoryBatchSize   = 20
+	DefaultHistoryConcurrency = 4
+
+	historyProgressInterval = time.Second
+	historyMaxAttempts      = 5 // 失败交易的最多尝试次数，超过后只保留记录供排查
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history [address|label]",
+	Short: "Backfill a wallet's past trades into the trade store",
+	Long: `Backfill a wallet's past trades into the trade store.
+
+Signatures are paged from newest to oldest until --until-slot, --until-date
+or --until-signature is reached (or the wallet's history ends). Progress is
+saved after every page, so an interrupted run resumes where it stopped.
+Once a run completes, later runs only backfill transactions newer than the
+newest one it covered; use --restart to forget all progress and start again
+from the newest transaction. Transactions that fail to fetch or parse are
+recorded and retried at the start of the next run.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wallet := resolveWallet(args)
+		opts, err := historyOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		store, err := OpenTradeStore(ctx)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		history := NewHistoryService(global.RpcClient, store, core.NewConsoleLogger(slog.LevelWarn))
+		fmt.Printf("开始回填 %s (%s)\n", global.SystemConfig().Label(wallet), wallet)
+
+		start := time.Now()
+		done, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(historyProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					fmt.Printf("\r%s", history.Progress().format(time.Since(start)))
+				case <-done:
+					return
+				}
+			}
+		}()
+		err = history.Backfill(ctx, wallet, opts)
+		close(done)
+		<-stopped
+		fmt.Printf("\r%s\n", history.Progress().format(time.Since(start)))
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			fmt.Println("已中断，再次运行将从上次保存的进度继续")
+			return nil
+		case err != nil:
+			return fmt.Errorf("回填失败，再次运行将从上次保存的进度继续: %w", err)
+		}
+		if failed := history.Progress().Failed; failed > 0 {
+			fmt.Printf("回填完成，%d 笔交易获取或解析失败，已记录，再次运行时重试\n", failed)
+			return nil
+		}
+		fmt.Println("回填完成")
+		return nil
+	},
+}
+
+// HistoryOptions 表示回填的范围与速度
+type HistoryOptions struct {
+	UntilSlot      uint64           // 回填到该 slot（含）为止
+	UntilTime      time.Time        // 回填到该时间（含）为止
+	UntilSignature solana.Signature // 回填到该签名（不含）为止
+	PageSize       int
+	BatchSize      int     // 单个批量 getTransaction 请求包含的交易数
+	Concurrency    int     // 同时发送的批量请求数
+	RPS            float64 // 每秒最多请求的交易数，0 表示只受 RPC 节点池限流
+	Restart        bool    // 忽略已保存的进度，包括已完整回填的最新签名
+}
+
+// HistoryProgress 表示回填进度
+type HistoryProgress struct {
+	Signatures int64  // 已处理的签名数
+	Trades     int64  // 已保存的买卖数
+	Skipped    int64  // 链上执行失败或非买卖的交易数
+	Failed     int64  // 获取或解析失败的交易数
+	Slot       uint64 // 已处理的最早 slot
+}
+
+func (p HistoryProgress) format(elapsed time.Duration) string {
+	rate := float64(p.Signatures) / max(elapsed.Seconds(), 1)
+	return fmt.Sprintf("签名 %d | 买卖 %d | 跳过 %d | 失败 %d | slot %d | %.1f 笔/秒   ",
+		p.Signatures, p.Trades, p.Skipped, p.Failed, p.Slot, rate)
+}
+
+// HistoryService 分页获取地址的历史签名，批量获取交易并解析保存到交易存储
+type HistoryService struct {
+	client *rpc.Client
+	store  *core.TradeStore
+	logger *slog.Logger
+
+	signatures, trades, skipped, failed atomic.Int64
+	slot                                atomic.Uint64
+}
+
+// NewHistoryService 创建回填服务
+func NewHistoryService(client *rpc.Client, store *core.TradeStore, logger *slog.Logger) *HistoryService {
+	return &HistoryService{
+		client: client,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Progress 返回当前进度，可在回填过程中并发调用
+func (s *HistoryService) Progress() HistoryProgress {
+	return HistoryProgress{
+		Signatures: s.signatures.Load(),
+		Trades:     s.trades.Load(),
+		Skipped:    s.skipped.Load(),
+		Failed:     s.failed.Load(),
+		Slot:       s.slot.Load(),
+	}
+}
+
+// Backfill 先重试上次失败的交易，再从最新（或未完成运行的进度）开始向前回填，每处理完一页保存一次进度。
+// 上次运行已完成时只回填到已完整回填的最新签名为止。获取或解析失败的交易记录到 history_failures，不阻塞进度
+func (s *HistoryService) Backfill(ctx context.Context, wallet string, opts HistoryOptions) error {
+	address, err := solana.PublicKeyFromBase58(wallet)
+	if err != nil {
+		return fmt.Errorf("地址无效 %q: %w", wallet, err)
+	}
+	opts.PageSize = min(max(opts.PageSize, 1), DefaultHistoryPageSize)
+	opts.BatchSize = max(opts.BatchSize, 1)
+	opts.Concurrency = max(opts.Concurrency, 1)
+	limiter := core.NewTokenBucket(opts.RPS, opts.BatchSize)
+
+	if opts.Restart {
+		if err := s.store.DeleteHistoryCursor(ctx, wallet); err != nil {
+			return err
+		}
+	}
+	cursor, _, err := s.store.HistoryCursor(ctx, wallet)
+	if err != nil {
+		return fmt.Errorf("读取回填进度失败: %w", err)
+	}
+	if err := s.retryFailures(ctx, wallet, opts, limiter); err != nil {
+		return err
+	}
+	// 指定 --until-signature 时按指定范围回填，不确定是否覆盖到 Newest，完成后不更新 Newest
+	explicitUntil := !opts.UntilSignature.IsZero()
+	if !explicitUntil && cursor.Newest != "" {
+		if opts.UntilSignature, err = solana.SignatureFromBase58(cursor.Newest); err != nil {
+			return fmt.Errorf("回填进度中的签名无效 %q: %w", cursor.Newest, err)
+		}
+		s.logger.Info("回填到上次完成时的最新签名为止", "until", cursor.Newest, core.LogKeySlot, cursor.NewestSlot)
+	}
+	// 首次回填以本次范围为基准；之后的运行只有回填到 Newest 才算完整覆盖
+	covers := func(reached bool) bool {
+		return cursor.Newest == "" || (!reached && !explicitUntil)
+	}
+	if cursor.Before != "" {
+		s.logger.Info("从上次进度继续", "before", cursor.Before, core.LogKeySlot, cursor.Slot)
+		s.slot.Store(cursor.Slot)
+		if opts.UntilSlot > 0 && cursor.Slot < opts.UntilSlot {
+			return s.finishRun(ctx, cursor, covers(true))
+		}
+	}
+
+	for {
+		page, err := s.signaturePage(ctx, address, cursor.Before, opts, limiter)
+		if err != nil {
+			return err
+		}
+		last := len(page) == opts.PageSize
+		page, reached := truncatePage(page, opts)
+		if len(page) == 0 {
+			return s.finishRun(ctx, cursor, covers(reached))
+		}
+		// 新一轮运行的第一页，记录开始时的最新签名
+		if cursor.Pending == "" {
+			cursor.Pending, cursor.PendingSlot = page[0].Signature.String(), page[0].Slot
+		}
+
+		if err := s.processPage(ctx, wallet, page, opts, limiter); err != nil {
+			return err
+		}
+		oldest := page[len(page)-1]
+		cursor.Before = oldest.Signature.String()
+		cursor.Slot = oldest.Slot
+		cursor.Processed += int64(len(page))
+		if err := s.store.SaveHistoryCursor(ctx, cursor); err != nil {
+			return fmt.Errorf("保存回填进度失败: %w", err)
+		}
+		s.slot.Store(oldest.Slot)
+
+		if reached || !last {
+			return s.finishRun(ctx, cursor, covers(reached))
+		}
+	}
+}
+
+// finishRun 在运行完成后清除向前回填的进度，下次从最新交易开始。
+// covered 为 true 时本次运行开始时的最新签名成为 Newest，之后的运行回填到它为止
+func (s *HistoryService) finishRun(ctx context.Context, cursor core.HistoryCursor, covered bool) error {
+	if covered && cursor.Pending != "" {
+		cursor.Newest, cursor.NewestSlot = cursor.Pending, cursor.PendingSlot
+	}
+	cursor.Before, cursor.Slot = "", 0
+	cursor.Pending, cursor.PendingSlot = "", 0
+	if err := s.store.SaveHistoryCursor(ctx, cursor); err != nil {
+		return fmt.Errorf("保存回填进度失败: %w", err)
+	}
+	return nil
+}
+
+// signaturePage 获取 before 之前的一页签名，从新到旧排列
+func (s *HistoryService) signaturePage(ctx context.Context, address solana.PublicKey, before string, opts HistoryOptions, limiter *core.TokenBucket) ([]*rpc.TransactionSignature, error) {
+	var beforeSignature solana.Signature
+	if before != "" {
+		var err error
+		if beforeSignature, err = solana.SignatureFromBase58(before); err != nil {
+			return nil, fmt.Errorf("回填进度中的签名无效 %q: %w", before, err)
+		}
+	}
+	return fetchSignaturePage(ctx, s.client, address, beforeSignature, opts.UntilSignature, opts.PageSize, limiter, s.logger)
+}
+
+// fetchSignaturePage 获取地址在 before 之前、until 之后的一页 finalized 签名，被限流时等待后重试
+func fetchSignaturePage(ctx context.Context, client *rpc.Client, address solana.PublicKey, before, until solana.Signature, limit int, limiter *core.TokenBucket, logger *slog.Logger) ([]*rpc.TransactionSignature, error) {
+	request := &rpc.GetSignaturesForAddressOpts{
+		Limit:      &limit,
+		Before:     before,
+		Until:      until,
+		Commitment: rpc.CommitmentFinalized,
+	}
+	var page []*rpc.TransactionSignature
+	err := retryRateLimited(ctx, logger, func() error {
+		if err := limiter.Wait(ctx, 1); err != nil {
+			return err
+		}
+		var err error
+		page, err = client.GetSignaturesForAddressWithOpts(ctx, address, request)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取签名列表失败: %w", err)
+	}
+	return page, nil
+}
+
+// truncatePage 去掉超出回填范围的签名，reached 表示已到达范围边界
+func truncatePage(page []*rpc.TransactionSignature, opts HistoryOptions) ([]*rpc.TransactionSignature, bool) {
+	for i, signature := range page {
+		if opts.UntilSlot > 0 && signature.Slot < opts.UntilSlot {
+			return page[:i], true
+		}
+		if !opts.UntilTime.IsZero() && signature.BlockTime != nil && signature.BlockTime.Time().Before(opts.UntilTime) {
+			return page[:i], true
+		}
+	}
+	return page, false
+}
+
+// processPage 把一页签名分批并发获取、解析与保存，任一批失败时返回错误
+func (s *HistoryService) processPage(ctx context.Context, wallet string, page []*rpc.TransactionSignature, opts HistoryOptions, limiter *core.TokenBucket) error {
+	var pending []solana.Signature
+	for _, signature := range page {
+		if signature.Err != nil {
+			s.skipped.Add(1)
+			s.signatures.Add(1)
+			continue
+		}
+		pending = append(pending, signature.Signature)
+	}
+
+	return forEachBatch(ctx, pending, opts.BatchSize, opts.Concurrency, func(ctx context.Context, batch []solana.Signature) error {
+		return s.processBatch(ctx, wallet, batch, limiter, false)
+	})
+}
+
+// retryFailures 重试之前获取或解析失败的交易，成功的从 history_failures 删除
+func (s *HistoryService) retryFailures(ctx context.Context, wallet string, opts HistoryOptions, limiter *core.TokenBucket) error {
+	failures, err := s.store.HistoryFailures(ctx, wallet, historyMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("读取失败交易失败: %w", err)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	s.logger.Info("重试上次失败的交易", "count", len(failures))
+
+	signatures := make([]solana.Signature, 0, len(failures))
+	for _, failure := range failures {
+		signature, err := solana.SignatureFromBase58(failure.Signature)
+		if err != nil {
+			return fmt.Errorf("失败记录中的签名无效 %q: %w", failure.Signature, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return forEachBatch(ctx, signatures, opts.BatchSize, opts.Concurrency, func(ctx context.Context, batch []solana.Signature) error {
+		return s.processBatch(ctx, wallet, batch, limiter, true)
+	})
+}
+
+// forEachBatch 把 signatures 按 batchSize 分批，由 concurrency 个 goroutine 并发处理，任一批失败时停止并返回错误
+func forEachBatch(ctx context.Context, signatures []solana.Signature, batchSize, concurrency int, fn func(context.Context, []solana.Signature) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	batches := make(chan []solana.Signature)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := fn(ctx, batch); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(signatures); start += batchSize {
+		select {
+		case batches <- signatures[start:min(start+batchSize, len(signatures))]:
+		case <-ctx.Done():
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// processBatch 通过批量请求获取交易，解析钱包的买卖并保存。单笔交易失败时记录到 history_failures 不中断，
+// retry 为 true 时把成功的交易从 history_failures 删除
+func (s *HistoryService) processBatch(ctx context.Context, wallet string, signatures []solana.Signature, limiter *core.TokenBucket, retry bool) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	transactions, errs, err := fetchTransactionBatch(ctx, s.client, signatures, limiter, s.logger)
+	if err != nil {
+		return err
+	}
+
+	var trades []core.Trade
+	var failures []core.HistoryFailure
+	var succeeded []string
+	for i, tx := range transactions {
+		signature := signatures[i].String()
+		logger := s.logger.With(core.LogKeySignature, signature)
+		if errs[i] != nil {
+			s.failed.Add(1)
+			logger.Warn("获取交易失败", core.LogKeyError, errs[i])
+			failures = append(failures, core.HistoryFailure{Wallet: wallet, Signature: signature, Error: errs[i].Error()})
+			continue
+		}
+		parsed, err := ParseTrades(tx, wallet)
+		switch {
+		case errors.Is(err, ErrNotTrade):
+			s.skipped.Add(1)
+			succeeded = append(succeeded, signature)
+		case err != nil:
+			s.failed.Add(1)
+			logger.Warn("解析交易失败", core.LogKeyError, err)
+			failures = append(failures, core.HistoryFailure{Wallet: wallet, Signature: signature, Error: err.Error()})
+		default:
+			trades = append(trades, parsed...)
+			succeeded = append(succeeded, signature)
+		}
+	}
+	if err := s.store.SaveTrades(ctx, trades); err != nil {
+		return fmt.Errorf("保存交易失败: %w", err)
+	}
+	if err := s.store.SaveHistoryFailures(ctx, failures); err != nil {
+		return fmt.Errorf("记录失败交易失败: %w", err)
+	}
+	if retry {
+		if err := s.store.DeleteHistoryFailures(ctx, wallet, succeeded); err != nil {
+			return fmt.Errorf("删除重试成功的交易失败: %w", err)
+		}
+	}
+	s.trades.Add(int64(len(trades)))
+	s.signatures.Add(int64(len(signatures)))
+	return nil
+}
+
+// fetchTransactionBatch 通过一个批量请求获取 finalized 交易并永久缓存，结果与 signatures 顺序一致。
+// 单笔交易的错误放在 errs 中，整批失败时返回 error
+func fetchTransactionBatch(ctx context.Context, client *rpc.Client, signatures []solana.Signature, limiter *core.TokenBucket, logger *slog.Logger) ([]*rpc.GetTransactionResult, []error, error) {
+	maxSupportedVersion := uint64(0)
+	requests := make([]utils.BatchRequest, 0, len(signatures))
+	for _, signature := range signatures {
+		requests = append(requests, utils.BatchRequest{
+			Method: "getTransaction",
+			Params: []interface{}{signature.String(), map[string]interface{}{
+				"encoding":                       solana.EncodingBase64,
+				"commitment":                     rpc.CommitmentFinalized,
+				"maxSupportedTransactionVersion": maxSupportedVersion,
+			}},
+		})
+	}
+
+	var results []utils.BatchResult
+	err := retryRateLimited(ctx, logger, func() error {
+		if err := limiter.Wait(ctx, float64(len(requests))); err != nil {
+			return err
+		}
+		var err error
+		results, err = utils.NewBatchClient(client).Call(ctx, requests)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("批量获取交易失败: %w", err)
+	}
+
+	transactions := make([]*rpc.GetTransactionResult, len(results))
+	errs := make([]error, len(results))
+	for i, result := range results {
+		var tx rpc.GetTransactionResult
+		if errs[i] = result.Decode(&tx); errs[i] != nil {
+			continue
+		}
+		transactions[i] = &tx
+		// finalized 交易不会再变化，永久缓存
+		global.Cache.SetTTL(ctx, core.CacheTransaction, signatures[i].String(), &tx, 0)
+	}
+	return transactions, errs, nil
+}
+
+// retryRateLimited 执行 call，被限流时按 Retry-After 等待后重试，其它错误直接返回
+func retryRateLimited(ctx context.Context, logger *slog.Logger, call func() error) error {
+	for {
+		err := call()
+		var limited *core.RateLimitError
+		if !errors.As(err, &limited) {
+			return err
+		}
+		logger.Warn("请求速率限制，稍后重试", "wait", limited.RetryAfter)
+		timer := time.NewTimer(limited.RetryAfter)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// historyOptionsFromFlags 读取 history 子命令的参数
+func historyOptionsFromFlags(cmd *cobra.Command) (HistoryOptions, error) {
+	flags := cmd.Flags()
+	var opts HistoryOptions
+	opts.UntilSlot, _ = flags.GetUint64("until-slot")
+	opts.PageSize, _ = flags.GetInt("page-size")
+	opts.BatchSize, _ = flags.GetInt("batch-size")
+	opts.Concurrency, _ = flags.GetInt("concurrency")
+	opts.RPS, _ = flags.GetFloat64("rps")
+	opts.Restart, _ = flags.GetBool("restart")
+
+	if date, _ := flags.GetString("until-date"); date != "" {
+		t, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, date); err != nil {
+				return opts, fmt.Errorf("--until-date 只支持 2006-01-02 或 RFC3339 格式: %q", date)
+			}
+		}
+		opts.UntilTime = t
+	}
+	if signature, _ := flags.GetString("until-signature"); signature != "" {
+		var err error
+		if opts.UntilSignature, err = solana.SignatureFromBase58(signature); err != nil {
+			return opts, fmt.Errorf("--until-signature 无效 %q: %w", signature, err)
+		}
+	}
+	return opts, nil
+}
+
+func init() {
+	flags := HistoryCmd.Flags()
+	flags.Uint64("until-slot", 0, "回填到该 slot 为止")
+	flags.String("until-date", "", "回填到该日期为止，例如 2024-01-02")
+	flags.String("until-signature", "", "回填到该签名为止（不含）")
+	flags.Int("page-size", DefaultHistoryPageSize, "每页获取的签名数，最大 1000")
+	flags.Int("batch-size", DefaultHistoryBatchSize, "单个批量请求包含的交易数")
+	flags.Int("concurrency", DefaultHistoryConcurrency, "同时发送的批量请求数")
+	flags.Float64("rps", 0, "每秒最多请求的交易数，0 表示只受 rpc 配置的限流")
+	flags.Bool("restart", false, "忽略已保存的进度，从最新交易开始")
+}
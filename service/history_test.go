@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"meme/core"
+	"meme/mocknet"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// swapFixture 以 transaction_demo.json 为模板构造 signer 用 lamports 买入或卖出 tokens 个 mint 的交易
+func swapFixture(t *testing.T, signer solana.PublicKey, slot uint64, buy bool, tokens, lamports uint64) *rpc.GetTransactionResult {
+	t.Helper()
+	result, tx := loadDemoTransaction(t)
+	tx.Message.AccountKeys[0] = signer
+	tx.Signatures[0] = solana.Signature{byte(slot), byte(slot >> 8), 1}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("序列化交易失败: %v", err)
+	}
+	envelope, _ := json.Marshal([]string{base64.StdEncoding.EncodeToString(data), "base64"})
+	if err := json.Unmarshal(envelope, &result.Transaction); err != nil {
+		t.Fatalf("构造交易失败: %v", err)
+	}
+
+	result.Slot = slot
+	blockTime := solana.UnixTimeSeconds(1_700_000_000 + int64(slot))
+	result.BlockTime = &blockTime
+	result.Meta.Fee = 0
+
+	const base = 10 * solana.LAMPORTS_PER_SOL
+	// 第 3 条代币余额是手续费支付者持有的代币账户
+	pre, post := result.Meta.PreTokenBalances[2], result.Meta.PostTokenBalances[2]
+	pre.Owner, post.Owner = &signer, &signer
+	preAmount, postAmount := *pre.UiTokenAmount, *post.UiTokenAmount
+	pre.UiTokenAmount, post.UiTokenAmount = &preAmount, &postAmount
+	if buy {
+		result.Meta.PreBalances[0], result.Meta.PostBalances[0] = base, base-lamports
+		preAmount.Amount, postAmount.Amount = "0", strconv.FormatUint(tokens, 10)
+	} else {
+		result.Meta.PreBalances[0], result.Meta.PostBalances[0] = base, base+lamports
+		preAmount.Amount, postAmount.Amount = strconv.FormatUint(tokens, 10), "0"
+	}
+	result.Meta.PreTokenBalances = []rpc.TokenBalance{pre}
+	result.Meta.PostTokenBalances = []rpc.TokenBalance{post}
+	return result
+}
+
+// TestHistoryBackfillRetriesFailures 校验获取失败的交易被记录，进度照常前进，下次运行时重试成功
+func TestHistoryBackfillRetriesFailures(t *testing.T) {
+	_, demo := loadDemoTransaction(t)
+	wallet := demo.Message.AccountKeys[0].String()
+
+	fixtures := mocknet.NewFixtures()
+	signatures, err := fixtures.LoadTransactions("../transaction_demo.json")
+	if err != nil {
+		t.Fatalf("加载 fixtures 失败: %v", err)
+	}
+	network, err := mocknet.Start(fixtures, "", "")
+	if err != nil {
+		t.Fatalf("启动 mocknet 失败: %v", err)
+	}
+	t.Cleanup(network.Close)
+
+	ctx := context.Background()
+	store, err := core.OpenStore(ctx, core.StoreConfig{Driver: core.StoreDriverSQLite, DSN: filepath.Join(t.TempDir(), "trades.db")}, nil)
+	if err != nil {
+		t.Fatalf("打开交易存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	client := rpc.New(network.RPC.URL())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// 第一次运行获取交易失败：记录失败，进度越过该页
+	network.RPC.Fail("getTransaction", 1)
+	history := NewHistoryService(client, store, logger)
+	if err := history.Backfill(ctx, wallet, HistoryOptions{}); err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if progress := history.Progress(); progress.Failed != 1 || progress.Trades != 0 {
+		t.Errorf("第一次运行进度为 %+v，期望失败 1、买卖 0", progress)
+	}
+	failures, err := store.HistoryFailures(ctx, wallet, 0)
+	if err != nil {
+		t.Fatalf("读取失败交易失败: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Signature != signatures[0] || failures[0].Attempts != 1 {
+		t.Fatalf("失败记录为 %+v，期望 %s 失败 1 次", failures, signatures[0])
+	}
+	cursor, ok, err := store.HistoryCursor(ctx, wallet)
+	if err != nil || !ok || cursor.Before != "" || cursor.Newest != signatures[0] {
+		t.Fatalf("回填进度为 %+v, %v, %v，期望运行完成、最新签名为 %s", cursor, ok, err, signatures[0])
+	}
+
+	// 第二次运行先重试失败的交易，成功后删除记录
+	history = NewHistoryService(client, store, logger)
+	if err := history.Backfill(ctx, wallet, HistoryOptions{}); err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if progress := history.Progress(); progress.Failed != 0 || progress.Trades == 0 {
+		t.Errorf("第二次运行进度为 %+v，期望重试成功", progress)
+	}
+	trades, err := store.Trades(ctx, core.TradeFilter{Wallet: wallet})
+	if err != nil {
+		t.Fatalf("查询交易失败: %v", err)
+	}
+	if len(trades) == 0 || trades[0].Signature != signatures[0] {
+		t.Errorf("重试后保存的交易为 %+v", trades)
+	}
+	if failures, err := store.HistoryFailures(ctx, wallet, 0); err != nil || len(failures) != 0 {
+		t.Errorf("重试成功后失败记录为 %+v, %v，期望为空", failures, err)
+	}
+}
+
+// TestHistoryFailuresMaxAttempts 校验失败次数累加，达到上限后不再重试
+func TestHistoryFailuresMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	store, err := core.OpenStore(ctx, core.StoreConfig{Driver: core.StoreDriverSQLite, DSN: filepath.Join(t.TempDir(), "trades.db")}, nil)
+	if err != nil {
+		t.Fatalf("打开交易存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	failure := core.HistoryFailure{Wallet: "wallet", Signature: "sig", Error: "解析失败"}
+	for i := 0; i < historyMaxAttempts; i++ {
+		if err := store.SaveHistoryFailures(ctx, []core.HistoryFailure{failure}); err != nil {
+			t.Fatalf("记录失败交易失败: %v", err)
+		}
+	}
+	all, err := store.HistoryFailures(ctx, "wallet", 0)
+	if err != nil || len(all) != 1 || all[0].Attempts != historyMaxAttempts {
+		t.Fatalf("失败记录为 %+v, %v，期望失败 %d 次", all, err, historyMaxAttempts)
+	}
+	if pending, err := store.HistoryFailures(ctx, "wallet", historyMaxAttempts); err != nil || len(pending) != 0 {
+		t.Errorf("达到上限后待重试记录为 %+v, %v，期望为空", pending, err)
+	}
+}
+
+// TestHistoryBackfillIncremental 校验运行完成后清除向前的进度，之后的运行只回填更新的交易
+func TestHistoryBackfillIncremental(t *testing.T) {
+	wallet := solana.NewWallet().PublicKey()
+	fixtures := mocknet.NewFixtures()
+	addSwap := func(slot uint64) string {
+		t.Helper()
+		data, err := json.Marshal(swapFixture(t, wallet, slot, true, 1000, solana.LAMPORTS_PER_SOL))
+		if err != nil {
+			t.Fatalf("序列化交易失败: %v", err)
+		}
+		signature, err := fixtures.AddTransaction(data)
+		if err != nil {
+			t.Fatalf("登记交易失败: %v", err)
+		}
+		return signature
+	}
+	addSwap(100)
+	addSwap(200)
+	newest := addSwap(300)
+
+	network, err := mocknet.Start(fixtures, "", "")
+	if err != nil {
+		t.Fatalf("启动 mocknet 失败: %v", err)
+	}
+	t.Cleanup(network.Close)
+	ctx := context.Background()
+	store, err := core.OpenStore(ctx, core.StoreConfig{Driver: core.StoreDriverSQLite, DSN: filepath.Join(t.TempDir(), "trades.db")}, nil)
+	if err != nil {
+		t.Fatalf("打开交易存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	client := rpc.New(network.RPC.URL())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := HistoryOptions{PageSize: 2}
+
+	// 第一次运行分两页回填全部 3 笔
+	history := NewHistoryService(client, store, logger)
+	if err := history.Backfill(ctx, wallet.String(), opts); err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if progress := history.Progress(); progress.Signatures != 3 || progress.Trades != 3 {
+		t.Fatalf("第一次运行进度为 %+v，期望签名 3、买卖 3", progress)
+	}
+	cursor, _, err := store.HistoryCursor(ctx, wallet.String())
+	if err != nil || cursor.Before != "" || cursor.Pending != "" || cursor.Newest != newest || cursor.NewestSlot != 300 {
+		t.Fatalf("回填进度为 %+v, %v，期望运行完成、最新签名为 slot 300 的 %s", cursor, err, newest)
+	}
+
+	// 没有新交易时不重复处理
+	history = NewHistoryService(client, store, logger)
+	if err := history.Backfill(ctx, wallet.String(), opts); err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if progress := history.Progress(); progress.Signatures != 0 {
+		t.Fatalf("没有新交易时处理了 %d 个签名", progress.Signatures)
+	}
+
+	// 新交易只回填到上次的最新签名为止
+	newer := addSwap(400)
+	history = NewHistoryService(client, store, logger)
+	if err := history.Backfill(ctx, wallet.String(), opts); err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if progress := history.Progress(); progress.Signatures != 1 || progress.Trades != 1 {
+		t.Errorf("增量运行进度为 %+v，期望签名 1、买卖 1", progress)
+	}
+	cursor, _, err = store.HistoryCursor(ctx, wallet.String())
+	if err != nil || cursor.Before != "" || cursor.Newest != newer {
+		t.Errorf("增量运行后进度为 %+v, %v，期望最新签名为 %s", cursor, err, newer)
+	}
+	if trades, err := store.Trades(ctx, core.TradeFilter{Wallet: wallet.String()}); err != nil || len(trades) != 4 {
+		t.Errorf("保存了 %d 笔交易, %v，期望 4 笔", len(trades), err)
+	}
+}
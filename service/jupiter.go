@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"meme/global"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const DefaultJupiterAPIURL = "https://quote-api.jup.ag/v6"
+
+// jupiterQuote 对应 /quote 接口返回中需要的字段
+type jupiterQuote struct {
+	InputMint            string `json:"inputMint"`
+	InAmount             string `json:"inAmount"`
+	OutputMint           string `json:"outputMint"`
+	OutAmount            string `json:"outAmount"`
+	OtherAmountThreshold string `json:"otherAmountThreshold"`
+	SlippageBps          uint16 `json:"slippageBps"`
+	PriceImpactPct       string `json:"priceImpactPct"`
+}
+
+// jupiterInstruction 对应 /swap-instructions 接口返回的单条指令
+type jupiterInstruction struct {
+	ProgramID string `json:"programId"`
+	Accounts  []struct {
+		Pubkey     string `json:"pubkey"`
+		IsSigner   bool   `json:"isSigner"`
+		IsWritable bool   `json:"isWritable"`
+	} `json:"accounts"`
+	Data string `json:"data"`
+}
+
+// jupiterSwapInstructions 对应 /swap-instructions 接口返回
+type jupiterSwapInstructions struct {
+	Error                       string               `json:"error"`
+	ComputeBudgetInstructions   []jupiterInstruction `json:"computeBudgetInstructions"`
+	SetupInstructions           []jupiterInstruction `json:"setupInstructions"`
+	SwapInstruction             *jupiterInstruction  `json:"swapInstruction"`
+	CleanupInstruction          *jupiterInstruction  `json:"cleanupInstruction"`
+	OtherInstructions           []jupiterInstruction `json:"otherInstructions"`
+	AddressLookupTableAddresses []string             `json:"addressLookupTableAddresses"`
+}
+
+// JupiterSwapBuilder 通过 Jupiter 兼容的 HTTP API 获取报价并构造兑换交易
+type JupiterSwapBuilder struct {
+	client     *rpc.Client
+	httpClient *http.Client
+	logger     *slog.Logger
+	baseURL    string
+}
+
+// NewJupiterSwapBuilder 创建 Jupiter 兑换构造器，API 地址取自 follow.jupiter_api_url
+func NewJupiterSwapBuilder(client *rpc.Client, logger *slog.Logger) *JupiterSwapBuilder {
+	baseURL := global.FollowConfig.JupiterAPIURL
+	if baseURL == "" {
+		baseURL = DefaultJupiterAPIURL
+	}
+	return &JupiterSwapBuilder{
+		client:     client,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Quote 调用 /quote 获取报价
+func (j *JupiterSwapBuilder) Quote(ctx context.Context, inputMint, outputMint solana.PublicKey, amount uint64, slippageBps uint16) (*Quote, error) {
+	query := url.Values{}
+	query.Set("inputMint", inputMint.String())
+	query.Set("outputMint", outputMint.String())
+	query.Set("amount", strconv.FormatUint(amount, 10))
+	query.Set("slippageBps", strconv.Itoa(int(slippageBps)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.baseURL+"/quote?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := j.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Jupiter 报价失败: %w", err)
+	}
+
+	var resp jupiterQuote
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("解析 Jupiter 报价失败: %w", err)
+	}
+
+	quote := &Quote{
+		InputMint:   inputMint,
+		OutputMint:  outputMint,
+		SlippageBps: resp.SlippageBps,
+		Raw:         raw,
+	}
+	if quote.InAmount, err = strconv.ParseUint(resp.InAmount, 10, 64); err != nil {
+		return nil, fmt.Errorf("解析 inAmount 失败: %w", err)
+	}
+	if quote.OutAmount, err = strconv.ParseUint(resp.OutAmount, 10, 64); err != nil {
+		return nil, fmt.Errorf("解析 outAmount 失败: %w", err)
+	}
+	if quote.MinOutAmount, err = strconv.ParseUint(resp.OtherAmountThreshold, 10, 64); err != nil {
+		return nil, fmt.Errorf("解析 otherAmountThreshold 失败: %w", err)
+	}
+	if impact, err := strconv.ParseFloat(resp.PriceImpactPct, 64); err == nil {
+		quote.PriceImpactBps = uint16(math.Round(impact * 10_000))
+	}
+	return quote, nil
+}
+
+// BuildSwap 调用 /swap-instructions 并解析地址查找表，返回可交给发送器签名的交易内容。
+// ComputeBudget 指令由 TransactionSender 统一添加，这里不使用接口返回的预算指令。
+func (j *JupiterSwapBuilder) BuildSwap(ctx context.Context, quote *Quote, user solana.PublicKey) (*SwapPlan, error) {
+	if len(quote.Raw) == 0 {
+		return nil, fmt.Errorf("报价缺少原始响应，无法构造 Jupiter 兑换")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"quoteResponse":    json.RawMessage(quote.Raw),
+		"userPublicKey":    user.String(),
+		"wrapAndUnwrapSol": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.baseURL+"/swap-instructions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	raw, err := j.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Jupiter 兑换指令失败: %w", err)
+	}
+
+	var resp jupiterSwapInstructions
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("解析 Jupiter 兑换指令失败: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("Jupiter 返回错误: %s", resp.Error)
+	}
+	if resp.SwapInstruction == nil {
+		return nil, fmt.Errorf("Jupiter 未返回兑换指令")
+	}
+
+	var raws []jupiterInstruction
+	raws = append(raws, resp.SetupInstructions...)
+	raws = append(raws, *resp.SwapInstruction)
+	if resp.CleanupInstruction != nil {
+		raws = append(raws, *resp.CleanupInstruction)
+	}
+	raws = append(raws, resp.OtherInstructions...)
+
+	plan := &SwapPlan{}
+	for _, item := range raws {
+		instruction, err := item.toInstruction()
+		if err != nil {
+			return nil, err
+		}
+		plan.Instructions = append(plan.Instructions, instruction)
+	}
+
+	plan.AddressTables, err = j.loadAddressTables(ctx, resp.AddressLookupTableAddresses)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// loadAddressTables 读取地址查找表账户内容
+func (j *JupiterSwapBuilder) loadAddressTables(ctx context.Context, addresses []string) (map[solana.PublicKey]solana.PublicKeySlice, error) {
+	tables := make(map[solana.PublicKey]solana.PublicKeySlice, len(addresses))
+	for _, address := range addresses {
+		key, err := solana.PublicKeyFromBase58(address)
+		if err != nil {
+			return nil, fmt.Errorf("地址查找表地址无效 %s: %w", address, err)
+		}
+		state, err := addresslookuptable.GetAddressLookupTable(ctx, j.client, key)
+		if err != nil {
+			return nil, fmt.Errorf("获取地址查找表 %s 失败: %w", address, err)
+		}
+		tables[key] = state.Addresses
+	}
+	return tables, nil
+}
+
+// do 发送 HTTP 请求并返回响应体
+func (j *JupiterSwapBuilder) do(req *http.Request) ([]byte, error) {
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// toInstruction 把接口返回的指令转换为 solana.Instruction
+func (in jupiterInstruction) toInstruction() (solana.Instruction, error) {
+	programID, err := solana.PublicKeyFromBase58(in.ProgramID)
+	if err != nil {
+		return nil, fmt.Errorf("程序地址无效 %s: %w", in.ProgramID, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(in.Data)
+	if err != nil {
+		return nil, fmt.Errorf("指令数据解码失败: %w", err)
+	}
+
+	accounts := make(solana.AccountMetaSlice, 0, len(in.Accounts))
+	for _, account := range in.Accounts {
+		key, err := solana.PublicKeyFromBase58(account.Pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("账户地址无效 %s: %w", account.Pubkey, err)
+		}
+		accounts = append(accounts, solana.NewAccountMeta(key, account.IsWritable, account.IsSigner))
+	}
+	return solana.NewInstruction(programID, accounts, data), nil
+}
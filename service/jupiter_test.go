@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"meme/global"
+	"meme/mocknet"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+var (
+	testInputMint  = solana.SolMint
+	testOutputMint = solana.MustPublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+)
+
+// recordedQuote 为 /quote 接口的录制响应，保留了未使用的字段
+const recordedQuote = `{
+  "inputMint": "So11111111111111111111111111111111111111112",
+  "inAmount": "100000000",
+  "outputMint": "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v",
+  "outAmount": "23612345",
+  "otherAmountThreshold": "23494283",
+  "swapMode": "ExactIn",
+  "slippageBps": 50,
+  "priceImpactPct": "0.0012",
+  "routePlan": [{"swapInfo": {"label": "Raydium"}, "percent": 100}],
+  "contextSlot": 304040433
+}`
+
+// newJupiterStub 启动返回录制响应的 Jupiter 接口，handler 为 nil 的路径返回 404
+func newJupiterStub(t *testing.T, quote, swap http.HandlerFunc) *JupiterSwapBuilder {
+	t.Helper()
+	mux := http.NewServeMux()
+	if quote != nil {
+		mux.HandleFunc("/quote", quote)
+	}
+	if swap != nil {
+		mux.HandleFunc("/swap-instructions", swap)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	previous := global.FollowConfig
+	global.FollowConfig.JupiterAPIURL = server.URL + "/"
+	t.Cleanup(func() { global.FollowConfig = previous })
+	return NewJupiterSwapBuilder(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestJupiterQuote(t *testing.T) {
+	builder := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		want := map[string]string{
+			"inputMint":   testInputMint.String(),
+			"outputMint":  testOutputMint.String(),
+			"amount":      "100000000",
+			"slippageBps": "50",
+		}
+		for key, value := range want {
+			if got := query.Get(key); got != value {
+				t.Errorf("参数 %s 为 %q，期望 %q", key, got, value)
+			}
+		}
+		w.Write([]byte(recordedQuote))
+	}, nil)
+
+	quote, err := builder.Quote(context.Background(), testInputMint, testOutputMint, 100_000_000, 50)
+	if err != nil {
+		t.Fatalf("Quote 失败: %v", err)
+	}
+	if quote.InAmount != 100_000_000 || quote.OutAmount != 23_612_345 || quote.MinOutAmount != 23_494_283 {
+		t.Errorf("金额解析错误: in=%d out=%d minOut=%d", quote.InAmount, quote.OutAmount, quote.MinOutAmount)
+	}
+	if quote.SlippageBps != 50 || quote.PriceImpactBps != 12 {
+		t.Errorf("滑点解析错误: slippage=%d impact=%d", quote.SlippageBps, quote.PriceImpactBps)
+	}
+	if !quote.InputMint.Equals(testInputMint) || !quote.OutputMint.Equals(testOutputMint) {
+		t.Errorf("mint 错误: %s -> %s", quote.InputMint, quote.OutputMint)
+	}
+	if string(quote.Raw) != recordedQuote {
+		t.Error("Raw 未保留原始响应")
+	}
+}
+
+func TestJupiterQuoteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"HTTP 错误", http.StatusBadRequest, `{"error":"Could not find any route"}`, "HTTP 400"},
+		{"响应不是 JSON", http.StatusOK, `<html>`, "解析 Jupiter 报价失败"},
+		{"金额无效", http.StatusOK, `{"inAmount":"1","outAmount":"abc","otherAmountThreshold":"1"}`, "解析 outAmount 失败"},
+		{"缺少最少获得数量", http.StatusOK, `{"inAmount":"1","outAmount":"1"}`, "解析 otherAmountThreshold 失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}, nil)
+			_, err := builder.Quote(context.Background(), testInputMint, testOutputMint, 1, 50)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误为 %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestJupiterQuoteSlippage 校验报价的价格影响超过最大滑点时拒绝跟单，未超过时按最大滑点重算最少获得数量
+func TestJupiterQuoteSlippage(t *testing.T) {
+	impact := "0.0012"
+	builder := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(recordedQuote, `"0.0012"`, `"`+impact+`"`, 1)))
+	}, nil)
+	guard := &SlippageGuard{MaxSlippageBps: 100, MaxPriceDeviationBps: 1500}
+	// 跟单地址以相同价格成交
+	fill := &LeaderFill{Side: TradeSideBuy, SolAmount: 100_000_000, TokenAmount: 23_612_345}
+
+	quote, err := builder.Quote(context.Background(), testInputMint, testOutputMint, 100_000_000, guard.MaxSlippageBps)
+	if err != nil {
+		t.Fatalf("Quote 失败: %v", err)
+	}
+	if reason, ok := guard.Check(fill, quote); !ok {
+		t.Fatalf("价格影响 12 bps 被拒绝: %s", reason)
+	}
+	if quote.SlippageBps != 100 || quote.MinOutAmount != 23_376_221 {
+		t.Errorf("通过后 slippage=%d minOut=%d，期望 100 与 23376221", quote.SlippageBps, quote.MinOutAmount)
+	}
+
+	impact = "0.05"
+	quote, err = builder.Quote(context.Background(), testInputMint, testOutputMint, 100_000_000, guard.MaxSlippageBps)
+	if err != nil {
+		t.Fatalf("Quote 失败: %v", err)
+	}
+	if reason, ok := guard.Check(fill, quote); ok || !strings.Contains(reason, "500 bps") {
+		t.Errorf("价格影响 500 bps 应被拒绝，结果 ok=%v reason=%q", ok, reason)
+	}
+}
+
+// lookupTableData 按地址查找表账户布局编码
+func lookupTableData(addresses ...solana.PublicKey) []byte {
+	data := make([]byte, addresslookuptable.LOOKUP_TABLE_META_SIZE, addresslookuptable.LOOKUP_TABLE_META_SIZE+32*len(addresses))
+	binary.LittleEndian.PutUint32(data[0:4], 1)
+	binary.LittleEndian.PutUint64(data[4:12], math.MaxUint64)
+	for _, address := range addresses {
+		data = append(data, address.Bytes()...)
+	}
+	return data
+}
+
+func TestJupiterBuildSwap(t *testing.T) {
+	user := solana.NewWallet().PublicKey()
+	table := solana.NewWallet().PublicKey()
+	tableEntry := solana.NewWallet().PublicKey()
+	swapProgram := solana.MustPublicKeyFromBase58("JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4")
+
+	fixtures := mocknet.NewFixtures()
+	fixtures.SetAccount(table, mocknet.Account{Owner: solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111"), Lamports: 1, Data: lookupTableData(tableEntry)})
+	network, err := mocknet.Start(fixtures, "", "")
+	if err != nil {
+		t.Fatalf("启动模拟节点失败: %v", err)
+	}
+	t.Cleanup(network.Close)
+
+	instruction := func(program solana.PublicKey, data []byte) map[string]interface{} {
+		return map[string]interface{}{
+			"programId": program.String(),
+			"accounts": []map[string]interface{}{
+				{"pubkey": user.String(), "isSigner": true, "isWritable": true},
+				{"pubkey": tableEntry.String(), "isSigner": false, "isWritable": false},
+			},
+			"data": base64.StdEncoding.EncodeToString(data),
+		}
+	}
+	builder := newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(recordedQuote))
+	}, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			QuoteResponse    json.RawMessage `json:"quoteResponse"`
+			UserPublicKey    string          `json:"userPublicKey"`
+			WrapAndUnwrapSol bool            `json:"wrapAndUnwrapSol"`
+		}
+		if r.Method != http.MethodPost {
+			t.Errorf("请求方法为 %s，期望 POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("请求体解析失败: %v", err)
+		}
+		var want, got bytes.Buffer
+		json.Compact(&want, []byte(recordedQuote))
+		json.Compact(&got, body.QuoteResponse)
+		if got.String() != want.String() {
+			t.Errorf("quoteResponse 未原样回传: %s", body.QuoteResponse)
+		}
+		if body.UserPublicKey != user.String() || !body.WrapAndUnwrapSol {
+			t.Errorf("请求参数错误: user=%s wrap=%v", body.UserPublicKey, body.WrapAndUnwrapSol)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"computeBudgetInstructions":   []interface{}{instruction(solana.ComputeBudget, []byte{2})},
+			"setupInstructions":           []interface{}{instruction(solana.SPLAssociatedTokenAccountProgramID, []byte{1})},
+			"swapInstruction":             instruction(swapProgram, []byte{0xe5, 0x17}),
+			"cleanupInstruction":          instruction(solana.TokenProgramID, []byte{9}),
+			"addressLookupTableAddresses": []string{table.String()},
+		})
+	})
+	builder.client = rpc.New(network.RPC.URL())
+
+	quote, err := builder.Quote(context.Background(), testInputMint, testOutputMint, 100_000_000, 50)
+	if err != nil {
+		t.Fatalf("Quote 失败: %v", err)
+	}
+	plan, err := builder.BuildSwap(context.Background(), quote, user)
+	if err != nil {
+		t.Fatalf("BuildSwap 失败: %v", err)
+	}
+
+	// 预算指令由发送器添加，不包含在兑换内容中
+	wantPrograms := []solana.PublicKey{solana.SPLAssociatedTokenAccountProgramID, swapProgram, solana.TokenProgramID}
+	if len(plan.Instructions) != len(wantPrograms) {
+		t.Fatalf("指令数为 %d，期望 %d", len(plan.Instructions), len(wantPrograms))
+	}
+	for i, program := range wantPrograms {
+		if !plan.Instructions[i].ProgramID().Equals(program) {
+			t.Errorf("第 %d 条指令程序为 %s，期望 %s", i, plan.Instructions[i].ProgramID(), program)
+		}
+	}
+	swap := plan.Instructions[1]
+	if data, _ := swap.Data(); string(data) != "\xe5\x17" {
+		t.Errorf("兑换指令数据为 %x", data)
+	}
+	if accounts := swap.Accounts(); len(accounts) != 2 || !accounts[0].IsSigner || !accounts[0].IsWritable || accounts[1].IsWritable {
+		t.Errorf("兑换指令账户权限错误: %+v", accounts)
+	}
+	if entries := plan.AddressTables[table]; len(entries) != 1 || !entries[0].Equals(tableEntry) {
+		t.Errorf("地址查找表内容为 %v，期望 [%s]", entries, tableEntry)
+	}
+}
+
+func TestJupiterBuildSwapErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"HTTP 错误", http.StatusInternalServerError, `internal`, "HTTP 500"},
+		{"接口返回错误", http.StatusOK, `{"error":"quote expired"}`, "Jupiter 返回错误: quote expired"},
+		{"缺少兑换指令", http.StatusOK, `{}`, "未返回兑换指令"},
+		{"程序地址无效", http.StatusOK, `{"swapInstruction":{"programId":"bad","accounts":[],"data":""}}`, "程序地址无效"},
+		{"指令数据无效", http.StatusOK, `{"swapInstruction":{"programId":"11111111111111111111111111111111","accounts":[],"data":"!!"}}`, "指令数据解码失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newJupiterStub(t, nil, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			_, err := builder.BuildSwap(context.Background(), &Quote{Raw: []byte(recordedQuote)}, solana.NewWallet().PublicKey())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误为 %v，期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	builder := newJupiterStub(t, nil, nil)
+	if _, err := builder.BuildSwap(context.Background(), &Quote{}, solana.NewWallet().PublicKey()); err == nil {
+		t.Error("缺少原始报价时应返回错误")
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"meme/mocknet"
+
+	"github.com/spf13/cobra"
+)
+
+var MockCmd = &cobra.Command{
+	Use:   "mock",
+	Short: "Run a local mock Solana RPC and WebSocket node",
+	Long: `Run a local mock Solana RPC and WebSocket node.
+
+The RPC node answers getTransaction, getSignaturesForAddress, getBalance,
+getAccountInfo, getMultipleAccounts, getTokenAccountsByOwner and
+getTokenSupply (including batch requests) from transaction fixtures. The
+WebSocket node answers logsSubscribe and pushes a logsNotification for the
+next fixture every --interval to each subscribed address the fixture
+mentions. --disconnect-every drops all connections and --rate-limit-every
+answers the next --rate-limit-count requests with 429, so the monitor's
+reconnect and backoff paths can be exercised without mainnet. Point
+rpc.endpoints at the printed URLs.`,
+	// 模拟节点不依赖配置文件
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rpcListen, _ := cmd.Flags().GetString("rpc-listen")
+		wsListen, _ := cmd.Flags().GetString("ws-listen")
+		fixtures, _ := cmd.Flags().GetString("fixtures")
+		template, _ := cmd.Flags().GetString("template")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		disconnectEvery, _ := cmd.Flags().GetDuration("disconnect-every")
+		rateLimitEvery, _ := cmd.Flags().GetDuration("rate-limit-every")
+		rateLimitCount, _ := cmd.Flags().GetInt("rate-limit-count")
+		retryAfter, _ := cmd.Flags().GetDuration("retry-after")
+
+		data := mocknet.NewFixtures()
+		signatures, err := data.LoadTransactions(fixtures)
+		if err != nil {
+			return fmt.Errorf("加载交易样本失败: %w", err)
+		}
+		if template != "" {
+			content, err := os.ReadFile(template)
+			if err != nil {
+				return fmt.Errorf("读取模板交易失败: %w", err)
+			}
+			if err := data.SetTemplate(content); err != nil {
+				return err
+			}
+		}
+
+		network, err := mocknet.Start(data, rpcListen, wsListen)
+		if err != nil {
+			return fmt.Errorf("启动模拟节点失败: %w", err)
+		}
+		defer network.Close()
+		endpoint := network.Endpoint()
+		fmt.Printf("已加载 %d 笔交易样本\n", len(signatures))
+		fmt.Printf("rpc:\n  endpoints:\n    - name: %s\n      url: %s\n      ws_url: %s\n", endpoint.Name, endpoint.URL, endpoint.WSURL)
+
+		ctx := cmd.Context()
+		var notify, disconnect, rateLimit <-chan time.Time
+		if interval > 0 && len(signatures) > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			notify = ticker.C
+		}
+		if disconnectEvery > 0 {
+			ticker := time.NewTicker(disconnectEvery)
+			defer ticker.Stop()
+			disconnect = ticker.C
+		}
+		if rateLimitEvery > 0 && rateLimitCount > 0 {
+			ticker := time.NewTicker(rateLimitEvery)
+			defer ticker.Stop()
+			rateLimit = ticker.C
+		}
+
+		next := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-notify:
+				signature := signatures[next%len(signatures)]
+				next++
+				for _, address := range network.WS.Subscribed() {
+					if data.Mentions(signature, address) {
+						n := network.WS.Notify(address, signature, nil)
+						fmt.Printf("推送 %s -> %s（%d 个连接）\n", signature, address, n)
+					}
+				}
+			case <-disconnect:
+				network.WS.Disconnect()
+				fmt.Println("已断开全部 WebSocket 连接")
+			case <-rateLimit:
+				network.RPC.RateLimit(rateLimitCount, retryAfter)
+				fmt.Printf("接下来 %d 个 RPC 请求返回 429\n", rateLimitCount)
+			}
+		}
+	},
+}
+
+func init() {
+	MockCmd.Flags().String("rpc-listen", "127.0.0.1:8899", "RPC 节点监听地址，为空时使用随机端口")
+	MockCmd.Flags().String("ws-listen", "127.0.0.1:8900", "WebSocket 节点监听地址，为空时使用随机端口")
+	MockCmd.Flags().String("fixtures", "transaction_demo.json", "交易样本文件或目录（目录下的全部 JSON 文件）")
+	MockCmd.Flags().String("template", "", "未登记的签名按该交易应答，签名替换为请求的签名")
+	MockCmd.Flags().Duration("interval", 5*time.Second, "按样本顺序推送通知的间隔，0 表示不推送")
+	MockCmd.Flags().Duration("disconnect-every", 0, "断开全部 WebSocket 连接的间隔，0 表示不断开")
+	MockCmd.Flags().Duration("rate-limit-every", 0, "触发限流的间隔，0 表示不限流")
+	MockCmd.Flags().Int("rate-limit-count", 3, "每次触发限流时返回 429 的请求数")
+	MockCmd.Flags().Duration("retry-after", time.Second, "429 响应的 Retry-After，0 表示不返回该响应头")
+}
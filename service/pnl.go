@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"meme/core"
+	"meme/global"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	solPriceTTL   = time.Minute
+	solPriceRetry = 5 * time.Second // SOL 价格获取失败后的重试间隔
+)
+
+var PnLCmd = &cobra.Command{
+	Use:   "pnl [address|label]",
+	Short: "Show realized and unrealized PnL per wallet and mint",
+	Long: `Show realized and unrealized PnL per wallet and mint from the trade store.
+
+Realized PnL matches sells against earlier buys first-in first-out. Open
+amounts are valued with a live quote to SOL. Realized USD figures use the
+SOL/USD price recorded with each trade; only unrealized USD uses the current
+price. Trades without a recorded price (e.g. backfilled by history) are left
+out of USD figures. Sells without a recorded buy (run history to backfill)
+are reported as unmatched and left out of realized PnL. Buys whose quote
+amount is unknown still hold their place in the FIFO queue; sells matched
+against them are reported as unpriced.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := core.TradeFilter{}
+		if len(args) > 0 {
+			filter.Wallet = resolveWallet(args)
+		}
+		filter.Mint, _ = cmd.Flags().GetString("mint")
+		if since, _ := cmd.Flags().GetDuration("since"); since > 0 {
+			filter.Since = time.Now().Add(-since)
+		}
+
+		ctx := cmd.Context()
+		store, err := OpenTradeStore(ctx)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		logger := core.NewConsoleLogger(slog.LevelWarn)
+		report, err := NewPnLService(store, NewJupiterSwapBuilder(global.RpcClient, logger), logger).Report(ctx, filter)
+		if err != nil {
+			return err
+		}
+		printPnLReport(report)
+		return nil
+	},
+}
+
+// ClosedTrade 表示一笔卖出按 FIFO 与之前买入匹配后的结算结果
+type ClosedTrade struct {
+	Wallet      string        `json:"wallet"`
+	Mint        string        `json:"mint"`
+	Signature   string        `json:"signature"`
+	Time        time.Time     `json:"time"`
+	Amount      uint64        `json:"amount"` // 匹配到买入成本的卖出数量，最小单位
+	CostSOL     float64       `json:"cost_sol"`
+	ProceedsSOL float64       `json:"proceeds_sol"`
+	PnLSOL      float64       `json:"pnl_sol"`
+	PnLUSD      float64       `json:"pnl_usd"`    // 按买入与卖出时的 SOL 价格折算，USDPriced 为 false 时为 0
+	USDPriced   bool          `json:"usd_priced"` // 买入与卖出是否都记录了 SOL 价格
+	Hold        time.Duration `json:"-"`          // 按数量加权的平均持有时间
+	HoldSeconds float64       `json:"hold_seconds"`
+}
+
+// PnLSummary 表示一组已结算卖出与未平仓持仓的汇总
+type PnLSummary struct {
+	RealizedSOL    float64      `json:"realized_sol"`
+	RealizedUSD    float64      `json:"realized_usd"`
+	UnrealizedSOL  float64      `json:"unrealized_sol"`
+	UnrealizedUSD  float64      `json:"unrealized_usd"`
+	Closed         int          `json:"closed"` // 已结算的卖出笔数
+	Wins           int          `json:"wins"`
+	WinRate        float64      `json:"win_rate"`
+	AvgHoldSeconds float64      `json:"avg_hold_seconds"`
+	Best           *ClosedTrade `json:"best,omitempty"`
+	Worst          *ClosedTrade `json:"worst,omitempty"`
+}
+
+// MintPnL 表示钱包在单个代币上的盈亏
+type MintPnL struct {
+	PnLSummary
+	Mint          string  `json:"mint"`
+	Decimals      uint8   `json:"decimals"`
+	Buys          int     `json:"buys"`
+	Sells         int     `json:"sells"`
+	OpenAmount    uint64  `json:"open_amount"` // 未卖出的数量，最小单位
+	OpenCostSOL   float64 `json:"open_cost_sol"`
+	OpenUnpriced  uint64  `json:"open_unpriced"`  // 未卖出数量中买入金额未知的部分，不计入未实现盈亏
+	Priced        bool    `json:"priced"`         // 未实现盈亏是否已按当前价格计算
+	UnmatchedSold uint64  `json:"unmatched_sold"` // 没有对应买入记录的卖出数量
+	UnpricedSold  uint64  `json:"unpriced_sold"`  // 匹配到金额未知的买入、未计入盈亏的卖出数量
+	Unpriced      int     `json:"unpriced"`       // 计价金额未知、未计入盈亏的交易数
+	UnpricedUSD   int     `json:"unpriced_usd"`   // 缺少交易时 SOL 价格、未计入 USD 盈亏的卖出数
+
+	closed []ClosedTrade
+	lots   []pnlLot
+}
+
+// WalletPnL 表示单个钱包的盈亏
+type WalletPnL struct {
+	PnLSummary
+	Wallet string     `json:"wallet"`
+	Label  string     `json:"label"`
+	Mints  []*MintPnL `json:"mints"`
+}
+
+// PnLReport 表示一次盈亏计算的结果
+type PnLReport struct {
+	SOLPriceUSD float64      `json:"sol_price_usd"` // 当前价格，只用于未实现盈亏，0 表示获取失败
+	Wallets     []*WalletPnL `json:"wallets"`
+}
+
+// pnlLot 表示一笔尚未卖完的买入
+type pnlLot struct {
+	amount  uint64
+	costSOL float64
+	costUSD float64 // 按买入时 SOL 价格折算的成本
+	valued  bool    // 买入的计价金额是否已知，未知时成本记为 0
+	priced  bool    // 买入时是否记录了 SOL 价格
+	time    time.Time
+}
+
+// PnLService 从交易存储读取交易，按 FIFO 计算已实现盈亏并用报价源计算未实现盈亏
+type PnLService struct {
+	store  *core.TradeStore
+	quoter Quoter
+	logger *slog.Logger
+}
+
+// NewPnLService 创建盈亏服务，quoter 为 nil 时不计算未实现盈亏与 USD 金额
+func NewPnLService(store *core.TradeStore, quoter Quoter, logger *slog.Logger) *PnLService {
+	return &PnLService{
+		store:  store,
+		quoter: quoter,
+		logger: logger,
+	}
+}
+
+// Report 计算满足条件的交易的盈亏。设置 Since 时更早的买入不参与匹配，对应卖出记为未匹配
+func (s *PnLService) Report(ctx context.Context, filter core.TradeFilter) (*PnLReport, error) {
+	filter.Ascending = true
+	filter.Limit = 0
+	trades, err := s.store.Trades(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易失败: %w", err)
+	}
+
+	report := &PnLReport{SOLPriceUSD: s.solPriceUSD(ctx)}
+	report.Wallets = ComputePnL(trades)
+	for _, wallet := range report.Wallets {
+		for _, mint := range wallet.Mints {
+			s.markToMarket(ctx, mint, report.SOLPriceUSD)
+		}
+		wallet.PnLSummary = summarizeWallet(wallet.Mints)
+	}
+	return report, nil
+}
+
+// ServeHTTP 以 JSON 返回盈亏，支持 wallet、mint 与 since（例如 24h）查询参数
+func (s *PnLService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := core.TradeFilter{Wallet: query.Get("wallet"), Mint: query.Get("mint")}
+	if since := query.Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("since 无效: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+	report, err := s.Report(r.Context(), filter)
+	if err != nil {
+		s.logger.Error("计算盈亏失败", core.LogKeyError, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ComputePnL 按钱包与代币分组，对按时间正序排列的交易做 FIFO 匹配，只计算已实现部分。
+// USD 金额按每笔交易记录的 SOL 价格折算
+func ComputePnL(trades []core.Trade) []*WalletPnL {
+	var wallets []*WalletPnL
+	byWallet := make(map[string]*WalletPnL)
+	byMint := make(map[string]*MintPnL)
+	for _, t := range trades {
+		wallet, ok := byWallet[t.Wallet]
+		if !ok {
+			wallet = &WalletPnL{Wallet: t.Wallet, Label: global.SystemConfig().Label(t.Wallet)}
+			byWallet[t.Wallet] = wallet
+			wallets = append(wallets, wallet)
+		}
+		key := t.Wallet + "/" + t.Mint
+		mint, ok := byMint[key]
+		if !ok {
+			mint = &MintPnL{Mint: t.Mint, Decimals: t.TokenDecimals}
+			byMint[key] = mint
+			wallet.Mints = append(wallet.Mints, mint)
+		}
+		mint.apply(t)
+	}
+
+	for _, wallet := range wallets {
+		for _, mint := range wallet.Mints {
+			for _, lot := range mint.lots {
+				mint.OpenAmount += lot.amount
+				mint.OpenCostSOL += lot.costSOL
+				if !lot.valued {
+					mint.OpenUnpriced += lot.amount
+				}
+			}
+			mint.PnLSummary = summarize(mint.closed)
+		}
+		sort.SliceStable(wallet.Mints, func(i, j int) bool {
+			return wallet.Mints[i].RealizedSOL > wallet.Mints[j].RealizedSOL
+		})
+		wallet.PnLSummary = summarizeWallet(wallet.Mints)
+	}
+	return wallets
+}
+
+// apply 把一笔交易计入持仓：买入追加一个 lot，卖出从最早的 lot 开始扣减并结算。
+// 计价金额未知的买入同样占一个 lot，保证后续卖出按 FIFO 顺序匹配，匹配到它的部分不结算
+func (m *MintPnL) apply(t core.Trade) {
+	value, priced := tradeValueSOL(t)
+	if t.Direction == core.TradeDirectionBuy {
+		m.Buys++
+		lot := pnlLot{amount: t.TokenAmount, time: t.BlockTime}
+		if priced {
+			lot.costSOL = value + tradeFeeSOL(t)
+			lot.costUSD = toUSD(lot.costSOL, t.SOLPriceUSD)
+			lot.valued, lot.priced = true, t.SOLPriceUSD > 0
+		} else {
+			m.Unpriced++
+		}
+		if lot.amount > 0 {
+			m.lots = append(m.lots, lot)
+		}
+		return
+	}
+
+	m.Sells++
+	remaining := t.TokenAmount
+	var matched, unvalued uint64
+	var cost, costUSD, holdWeighted float64
+	usdPriced := t.SOLPriceUSD > 0
+	for remaining > 0 && len(m.lots) > 0 {
+		lot := &m.lots[0]
+		take := min(remaining, lot.amount)
+		share := lot.costSOL * float64(take) / float64(lot.amount)
+		shareUSD := lot.costUSD * float64(take) / float64(lot.amount)
+		if lot.valued {
+			usdPriced = usdPriced && lot.priced
+			cost += share
+			costUSD += shareUSD
+			holdWeighted += t.BlockTime.Sub(lot.time).Seconds() * float64(take)
+			matched += take
+		} else {
+			unvalued += take
+		}
+		lot.amount -= take
+		lot.costSOL -= share
+		lot.costUSD -= shareUSD
+		remaining -= take
+		if lot.amount == 0 {
+			m.lots = m.lots[1:]
+		}
+	}
+	m.UnmatchedSold += remaining
+	// 计价金额未知时只扣减持仓，不结算
+	if !priced {
+		m.Unpriced++
+		return
+	}
+	m.UnpricedSold += unvalued
+	if matched == 0 {
+		return
+	}
+
+	proceeds := (value - tradeFeeSOL(t)) * float64(matched) / float64(t.TokenAmount)
+	hold := time.Duration(holdWeighted / float64(matched) * float64(time.Second))
+	var pnlUSD float64
+	if usdPriced {
+		pnlUSD = toUSD(proceeds, t.SOLPriceUSD) - costUSD
+	} else {
+		m.UnpricedUSD++
+	}
+	m.closed = append(m.closed, ClosedTrade{
+		Wallet:      t.Wallet,
+		Mint:        t.Mint,
+		Signature:   t.Signature,
+		Time:        t.BlockTime,
+		Amount:      matched,
+		CostSOL:     cost,
+		ProceedsSOL: proceeds,
+		PnLSOL:      proceeds - cost,
+		PnLUSD:      pnlUSD,
+		USDPriced:   usdPriced,
+		Hold:        hold,
+		HoldSeconds: hold.Seconds(),
+	})
+}
+
+// markToMarket 用报价源为未卖出且买入金额已知的数量定价，计算未实现盈亏
+func (s *PnLService) markToMarket(ctx context.Context, m *MintPnL, solUSD float64) {
+	amount := m.OpenAmount - m.OpenUnpriced
+	if amount == 0 || s.quoter == nil {
+		return
+	}
+	mint, err := solana.PublicKeyFromBase58(m.Mint)
+	if err != nil {
+		return
+	}
+	quote, err := s.quoter.Quote(ctx, mint, solana.SolMint, amount, 0)
+	if err != nil {
+		s.logger.Warn("获取当前价格失败", core.LogKeyMint, m.Mint, core.LogKeyError, err)
+		return
+	}
+	m.Priced = true
+	m.UnrealizedSOL = lamportsToSOL(quote.OutAmount) - m.OpenCostSOL
+	m.UnrealizedUSD = toUSD(m.UnrealizedSOL, solUSD)
+}
+
+// solPriceUSD 获取当前 SOL 价格，失败时返回 0
+func (s *PnLService) solPriceUSD(ctx context.Context) float64 {
+	if s.quoter == nil {
+		return 0
+	}
+	price, err := fetchSOLPriceUSD(ctx, s.quoter)
+	if err != nil {
+		s.logger.Warn("获取 SOL 价格失败，未实现 USD 金额记为 0", core.LogKeyError, err)
+		return 0
+	}
+	return price
+}
+
+// fetchSOLPriceUSD 通过 1 SOL 兑换 USDC 的报价获取 SOL 价格
+func fetchSOLPriceUSD(ctx context.Context, quoter Quoter) (float64, error) {
+	quote, err := quoter.Quote(ctx, solana.SolMint, solana.MustPublicKeyFromBase58(USDCMint), solana.LAMPORTS_PER_SOL, 0)
+	if err != nil {
+		return 0, err
+	}
+	return float64(quote.OutAmount) / 1e6, nil
+}
+
+// solPriceCache 缓存记录交易时使用的 SOL 价格，获取失败时在 solPriceRetry 内不再重试
+type solPriceCache struct {
+	mu      sync.Mutex
+	price   float64
+	expires time.Time
+	group   singleflight.Group
+}
+
+var solPrice solPriceCache
+
+// currentSOLPrice 返回记录交易时的 SOL 价格，缓存过期时通过 Jupiter 报价刷新，获取失败时返回 0。
+// 报价请求不持有锁，并发调用只发起一次请求
+func currentSOLPrice(ctx context.Context, logger *slog.Logger) float64 {
+	solPrice.mu.Lock()
+	if time.Now().Before(solPrice.expires) {
+		price := solPrice.price
+		solPrice.mu.Unlock()
+		return price
+	}
+	solPrice.mu.Unlock()
+
+	price, _, _ := solPrice.group.Do("sol", func() (interface{}, error) {
+		price, err := fetchSOLPriceUSD(ctx, NewJupiterSwapBuilder(global.RpcClient, logger))
+		ttl := solPriceTTL
+		if err != nil {
+			logger.Warn("获取 SOL 价格失败，交易不记录 USD 价格", core.LogKeyError, err)
+			ttl = solPriceRetry
+		}
+		solPrice.mu.Lock()
+		solPrice.price, solPrice.expires = price, time.Now().Add(ttl)
+		solPrice.mu.Unlock()
+		return price, nil
+	})
+	return price.(float64)
+}
+
+// tradeValueSOL 返回交易的计价金额折合的 SOL，稳定币按交易记录的 SOL 价格折算
+func tradeValueSOL(t core.Trade) (float64, bool) {
+	if t.QuoteAmount == 0 {
+		return 0, false
+	}
+	switch t.QuoteMint {
+	case solana.SolMint.String():
+		return t.QuoteUI(), true
+	case USDCMint, USDTMint:
+		if t.SOLPriceUSD > 0 {
+			return t.QuoteUI() / t.SOLPriceUSD, true
+		}
+	}
+	return 0, false
+}
+
+// tradeFeeSOL 返回交易手续费，同一笔交易的手续费只计入第一个 leg
+func tradeFeeSOL(t core.Trade) float64 {
+	if t.Leg != 0 {
+		return 0
+	}
+	return lamportsToSOL(t.Fee)
+}
+
+// toUSD 按 SOL 价格折算 USD，价格未知时返回 0
+func toUSD(sol, solUSD float64) float64 {
+	if solUSD <= 0 {
+		return 0
+	}
+	return sol * solUSD
+}
+
+func lamportsToSOL(lamports uint64) float64 {
+	return float64(lamports) / float64(solana.LAMPORTS_PER_SOL)
+}
+
+// summarize 汇总已结算卖出的盈亏、胜率、平均持有时间与最好、最差的一笔
+func summarize(closed []ClosedTrade) PnLSummary {
+	var summary PnLSummary
+	var hold float64
+	for i := range closed {
+		c := &closed[i]
+		summary.RealizedSOL += c.PnLSOL
+		summary.RealizedUSD += c.PnLUSD
+		summary.Closed++
+		if c.PnLSOL > 0 {
+			summary.Wins++
+		}
+		hold += c.HoldSeconds
+		if summary.Best == nil || c.PnLSOL > summary.Best.PnLSOL {
+			summary.Best = c
+		}
+		if summary.Worst == nil || c.PnLSOL < summary.Worst.PnLSOL {
+			summary.Worst = c
+		}
+	}
+	if summary.Closed > 0 {
+		summary.WinRate = float64(summary.Wins) / float64(summary.Closed)
+		summary.AvgHoldSeconds = hold / float64(summary.Closed)
+	}
+	return summary
+}
+
+// summarizeWallet 合并钱包下全部代币的已结算卖出与未实现盈亏
+func summarizeWallet(mints []*MintPnL) PnLSummary {
+	var closed []ClosedTrade
+	var unrealizedSOL, unrealizedUSD float64
+	for _, mint := range mints {
+		closed = append(closed, mint.closed...)
+		unrealizedSOL += mint.UnrealizedSOL
+		unrealizedUSD += mint.UnrealizedUSD
+	}
+	summary := summarize(closed)
+	summary.UnrealizedSOL = unrealizedSOL
+	summary.UnrealizedUSD = unrealizedUSD
+	return summary
+}
+
+// printPnLReport 按钱包输出汇总与各代币明细
+func printPnLReport(report *PnLReport) {
+	if report.SOLPriceUSD > 0 {
+		fmt.Printf("SOL 价格: $%.2f\n", report.SOLPriceUSD)
+	}
+	if len(report.Wallets) == 0 {
+		fmt.Println("没有交易记录")
+		return
+	}
+	for _, wallet := range report.Wallets {
+		fmt.Printf("\n%s (%s)\n", wallet.Label, wallet.Wallet)
+		fmt.Printf("已实现: %+.4f SOL ($%+.2f)  未实现: %+.4f SOL ($%+.2f)  胜率: %.1f%% (%d/%d)  平均持有: %s\n",
+			wallet.RealizedSOL, wallet.RealizedUSD, wallet.UnrealizedSOL, wallet.UnrealizedUSD,
+			wallet.WinRate*100, wallet.Wins, wallet.Closed, formatHold(wallet.AvgHoldSeconds))
+		if wallet.Best != nil {
+			fmt.Printf("最好: %+.4f SOL %s %s\n", wallet.Best.PnLSOL, wallet.Best.Mint, wallet.Best.Signature)
+			fmt.Printf("最差: %+.4f SOL %s %s\n", wallet.Worst.PnLSOL, wallet.Worst.Mint, wallet.Worst.Signature)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MINT\tBUYS\tSELLS\tREALIZED SOL\tUNREALIZED SOL\tOPEN\tWIN RATE\tAVG HOLD\tNOTE")
+		for _, mint := range wallet.Mints {
+			unrealized := "-"
+			if mint.Priced {
+				unrealized = fmt.Sprintf("%+.4f", mint.UnrealizedSOL)
+			}
+			var note string
+			if mint.UnmatchedSold > 0 {
+				note = fmt.Sprintf("未匹配卖出 %.6g", float64(mint.UnmatchedSold)/math.Pow10(int(mint.Decimals)))
+			}
+			if mint.UnpricedSold > 0 {
+				note += fmt.Sprintf(" 未定价卖出 %.6g", float64(mint.UnpricedSold)/math.Pow10(int(mint.Decimals)))
+			}
+			if mint.Unpriced > 0 {
+				note += fmt.Sprintf(" 未定价 %d 笔", mint.Unpriced)
+			}
+			if mint.UnpricedUSD > 0 {
+				note += fmt.Sprintf(" 无 USD 价格 %d 笔", mint.UnpricedUSD)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%+.4f\t%s\t%.6g\t%.1f%%\t%s\t%s\n", mint.Mint, mint.Buys, mint.Sells,
+				mint.RealizedSOL, unrealized, float64(mint.OpenAmount)/math.Pow10(int(mint.Decimals)),
+				mint.WinRate*100, formatHold(mint.AvgHoldSeconds), note)
+		}
+		w.Flush()
+	}
+}
+
+// formatHold 把持有秒数格式化为便于阅读的时长
+func formatHold(seconds float64) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
+
+func init() {
+	PnLCmd.Flags().String("mint", "", "只计算该代币")
+	PnLCmd.Flags().Duration("since", 0, "只计算最近一段时间内的交易，例如 720h")
+}
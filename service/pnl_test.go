@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"meme/core"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+func pnlTrade(signature, direction string, tokens uint64, quoteMint string, quote uint64, quoteDecimals uint8, solUSD float64, at time.Time) core.Trade {
+	return core.Trade{
+		Signature:     signature,
+		BlockTime:     at,
+		Wallet:        "wallet",
+		Mint:          "mint",
+		Direction:     direction,
+		TokenAmount:   tokens,
+		TokenDecimals: 6,
+		QuoteMint:     quoteMint,
+		QuoteAmount:   quote,
+		QuoteDecimals: quoteDecimals,
+		SOLPriceUSD:   solUSD,
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// TestComputePnLUsesTradeTimeSOLPrice 校验 USD 盈亏按买入与卖出时记录的 SOL 价格折算
+func TestComputePnLUsesTradeTimeSOLPrice(t *testing.T) {
+	sol, usdc := solana.SolMint.String(), USDCMint
+	start := time.Unix(1_700_000_000, 0)
+	trades := []core.Trade{
+		// 1 SOL 买入 1000 个，SOL $100
+		pnlTrade("buy1", core.TradeDirectionBuy, 1000, sol, solana.LAMPORTS_PER_SOL, 9, 100, start),
+		// 用 200 USDC 买入 1000 个，SOL $200，折合 1 SOL
+		pnlTrade("buy2", core.TradeDirectionBuy, 1000, usdc, 200_000_000, 6, 200, start.Add(time.Minute)),
+		// 卖出 1000 个得到 3 SOL，SOL $300，匹配 buy1
+		pnlTrade("sell1", core.TradeDirectionSell, 1000, sol, 3*solana.LAMPORTS_PER_SOL, 9, 300, start.Add(2*time.Minute)),
+		// 卖出 1000 个得到 2 SOL，未记录 SOL 价格，匹配 buy2
+		pnlTrade("sell2", core.TradeDirectionSell, 1000, sol, 2*solana.LAMPORTS_PER_SOL, 9, 0, start.Add(3*time.Minute)),
+	}
+
+	wallets := ComputePnL(trades)
+	if len(wallets) != 1 || len(wallets[0].Mints) != 1 {
+		t.Fatalf("盈亏分组错误: %+v", wallets)
+	}
+	mint := wallets[0].Mints[0]
+	if mint.Closed != 2 || mint.Unpriced != 0 || mint.UnpricedUSD != 1 {
+		t.Fatalf("结算 %d 笔、未定价 %d、无 USD 价格 %d，期望 2、0、1", mint.Closed, mint.Unpriced, mint.UnpricedUSD)
+	}
+
+	first, second := mint.closed[0], mint.closed[1]
+	if !approxEqual(first.PnLSOL, 2) || !first.USDPriced || !approxEqual(first.PnLUSD, 3*300-100) {
+		t.Errorf("第一笔卖出盈亏为 %v SOL / $%v (priced=%v)，期望 2 SOL / $800", first.PnLSOL, first.PnLUSD, first.USDPriced)
+	}
+	if !approxEqual(second.PnLSOL, 1) || second.USDPriced || second.PnLUSD != 0 {
+		t.Errorf("第二笔卖出盈亏为 %v SOL / $%v (priced=%v)，期望 1 SOL 且不计 USD", second.PnLSOL, second.PnLUSD, second.USDPriced)
+	}
+	if !approxEqual(mint.RealizedUSD, 800) {
+		t.Errorf("已实现 USD 为 %v，期望 800", mint.RealizedUSD)
+	}
+}
+
+// TestComputePnLUnpricedStableBuy 校验未记录 SOL 价格的稳定币买入不按当前价格折算，但仍计入持仓
+func TestComputePnLUnpricedStableBuy(t *testing.T) {
+	trades := []core.Trade{pnlTrade("buy", core.TradeDirectionBuy, 1000, USDCMint, 100_000_000, 6, 0, time.Unix(1_700_000_000, 0))}
+	mint := ComputePnL(trades)[0].Mints[0]
+	if mint.Unpriced != 1 || mint.OpenAmount != 1000 || mint.OpenUnpriced != 1000 || mint.OpenCostSOL != 0 {
+		t.Errorf("未定价 %d、持仓 %d、未定价持仓 %d、成本 %v，期望 1、1000、1000、0",
+			mint.Unpriced, mint.OpenAmount, mint.OpenUnpriced, mint.OpenCostSOL)
+	}
+}
+
+// TestComputePnLUnpricedBuyKeepsFIFO 校验金额未知的买入仍占据 FIFO 顺序，卖出不会越过它匹配后面的买入
+func TestComputePnLUnpricedBuyKeepsFIFO(t *testing.T) {
+	sol := solana.SolMint.String()
+	start := time.Unix(1_700_000_000, 0)
+	trades := []core.Trade{
+		// 金额未知的买入 1000 个
+		pnlTrade("buy1", core.TradeDirectionBuy, 1000, USDCMint, 100_000_000, 6, 0, start),
+		// 1 SOL 买入 1000 个
+		pnlTrade("buy2", core.TradeDirectionBuy, 1000, sol, solana.LAMPORTS_PER_SOL, 9, 100, start.Add(time.Hour)),
+		// 卖出 1500 个得到 3 SOL：1000 个匹配 buy1 不结算，500 个匹配 buy2
+		pnlTrade("sell1", core.TradeDirectionSell, 1500, sol, 3*solana.LAMPORTS_PER_SOL, 9, 100, start.Add(2*time.Hour)),
+		// 卖出剩余 500 个得到 1 SOL
+		pnlTrade("sell2", core.TradeDirectionSell, 500, sol, solana.LAMPORTS_PER_SOL, 9, 100, start.Add(3*time.Hour)),
+	}
+
+	mint := ComputePnL(trades)[0].Mints[0]
+	if mint.Unpriced != 1 || mint.UnpricedSold != 1000 || mint.UnmatchedSold != 0 || mint.OpenAmount != 0 {
+		t.Fatalf("未定价 %d、未定价卖出 %d、未匹配 %d、持仓 %d，期望 1、1000、0、0",
+			mint.Unpriced, mint.UnpricedSold, mint.UnmatchedSold, mint.OpenAmount)
+	}
+	if len(mint.closed) != 2 {
+		t.Fatalf("结算 %d 笔，期望 2 笔", len(mint.closed))
+	}
+	first, second := mint.closed[0], mint.closed[1]
+	// sell1 的 500 个按 buy2 一半成本 0.5 SOL 结算，收入按数量折算为 1 SOL，持有 1 小时
+	if first.Amount != 500 || !approxEqual(first.CostSOL, 0.5) || !approxEqual(first.ProceedsSOL, 1) || first.Hold != time.Hour {
+		t.Errorf("sell1 结算为 %d 个、成本 %v、收入 %v、持有 %v，期望 500、0.5、1、1h",
+			first.Amount, first.CostSOL, first.ProceedsSOL, first.Hold)
+	}
+	if second.Amount != 500 || !approxEqual(second.PnLSOL, 0.5) || second.Hold != 2*time.Hour {
+		t.Errorf("sell2 结算为 %d 个、盈亏 %v、持有 %v，期望 500、0.5、2h", second.Amount, second.PnLSOL, second.Hold)
+	}
+	if !approxEqual(mint.RealizedSOL, 1) {
+		t.Errorf("已实现 %v SOL，期望 1", mint.RealizedSOL)
+	}
+}
+
+func TestTradeStoreKeepsSOLPrice(t *testing.T) {
+	ctx := context.Background()
+	store, err := core.OpenStore(ctx, core.StoreConfig{Driver: core.StoreDriverSQLite, DSN: filepath.Join(t.TempDir(), "trades.db")}, nil)
+	if err != nil {
+		t.Fatalf("打开交易存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	trade := pnlTrade("sig", core.TradeDirectionBuy, 1000, solana.SolMint.String(), solana.LAMPORTS_PER_SOL, 9, 150, time.Unix(1_700_000_000, 0))
+	if err := store.SaveTrades(ctx, []core.Trade{trade}); err != nil {
+		t.Fatalf("保存交易失败: %v", err)
+	}
+	// 回填时不知道交易时价格，不应覆盖已记录的价格
+	trade.SOLPriceUSD = 0
+	if err := store.SaveTrades(ctx, []core.Trade{trade}); err != nil {
+		t.Fatalf("保存交易失败: %v", err)
+	}
+	trades, err := store.Trades(ctx, core.TradeFilter{Wallet: "wallet"})
+	if err != nil || len(trades) != 1 {
+		t.Fatalf("查询交易失败: %v, %+v", err, trades)
+	}
+	if trades[0].SOLPriceUSD != 150 {
+		t.Errorf("SOL 价格为 %v，期望保留 150", trades[0].SOLPriceUSD)
+	}
+}
+
+func TestCurrentSOLPriceCached(t *testing.T) {
+	var requests atomic.Int32
+	newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"inAmount":"1000000000","outAmount":"150250000","otherAmountThreshold":"150000000","slippageBps":0,"priceImpactPct":"0"}`))
+	}, nil)
+	solPrice = solPriceCache{}
+	t.Cleanup(func() { solPrice = solPriceCache{} })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for i := 0; i < 3; i++ {
+		if price := currentSOLPrice(context.Background(), logger); price != 150.25 {
+			t.Fatalf("SOL 价格为 %v，期望 150.25", price)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("报价请求 %d 次，期望缓存期内只请求 1 次", got)
+	}
+}
+
+// TestCurrentSOLPriceConcurrent 校验并发调用只发起一次报价请求，且请求期间不持有缓存锁
+func TestCurrentSOLPriceConcurrent(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte(`{"inAmount":"1000000000","outAmount":"150000000","otherAmountThreshold":"150000000","slippageBps":0,"priceImpactPct":"0"}`))
+	}, nil)
+	solPrice = solPriceCache{}
+	t.Cleanup(func() { solPrice = solPriceCache{} })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	prices := make(chan float64, 5)
+	for i := 0; i < cap(prices); i++ {
+		go func() { prices <- currentSOLPrice(context.Background(), logger) }()
+	}
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// 报价请求进行中时缓存锁可用
+	locked := make(chan struct{})
+	go func() {
+		solPrice.mu.Lock()
+		solPrice.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("报价请求期间缓存锁被占用")
+	}
+	close(release)
+
+	for i := 0; i < cap(prices); i++ {
+		if price := <-prices; price != 150 {
+			t.Errorf("SOL 价格为 %v，期望 150", price)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("报价请求 %d 次，期望并发调用只请求 1 次", got)
+	}
+}
+
+// TestCurrentSOLPriceFailureRetry 校验获取失败只短暂缓存，重试间隔后重新请求
+func TestCurrentSOLPriceFailureRetry(t *testing.T) {
+	var requests atomic.Int32
+	newJupiterStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"inAmount":"1000000000","outAmount":"150000000","otherAmountThreshold":"150000000","slippageBps":0,"priceImpactPct":"0"}`))
+	}, nil)
+	solPrice = solPriceCache{}
+	t.Cleanup(func() { solPrice = solPriceCache{} })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if price := currentSOLPrice(ctx, logger); price != 0 {
+			t.Fatalf("获取失败时 SOL 价格为 %v，期望 0", price)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("重试间隔内报价请求 %d 次，期望 1 次", got)
+	}
+	solPrice.mu.Lock()
+	if remaining := time.Until(solPrice.expires); remaining > solPriceRetry {
+		t.Errorf("失败结果缓存 %v，期望不超过 %v", remaining, solPriceRetry)
+	}
+	solPrice.expires = time.Now()
+	solPrice.mu.Unlock()
+
+	if price := currentSOLPrice(ctx, logger); price != 150 {
+		t.Errorf("重试后 SOL 价格为 %v，期望 150", price)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"meme/global"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	PositionsKey       = "follow:positions"
+	maxPositionRetries = 50                   // 乐观锁冲突时的最大重试次数
+	positionRetryDelay = 2 * time.Millisecond // 冲突后随机退避的基准间隔
+)
+
+// Position 表示一笔跟单持仓，金额均为最小单位
+type Position struct {
+	Mint          string    `json:"mint"`
+	InitialAmount uint64    `json:"initial_amount"` // 建仓时的代币数量
+	Amount        uint64    `json:"amount"`         // 当前剩余代币数量
+	CostLamports  uint64    `json:"cost_lamports"`  // 建仓花费的 lamports
+	HighPrice     float64   `json:"high_price"`     // 持仓期间最高价 (lamports / 最小单位)
+	TakeProfitHit int       `json:"take_profit_hit"`
+	OpenedAt      time.Time `json:"opened_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// EntryPrice 返回成本价 (lamports / 最小单位)
+func (p *Position) EntryPrice() float64 {
+	if p.InitialAmount == 0 {
+		return 0
+	}
+	return float64(p.CostLamports) / float64(p.InitialAmount)
+}
+
+// PositionStore 把持仓保存在 Redis 中，重启后可以恢复
+type PositionStore struct{}
+
+// NewPositionStore 创建持仓存储
+func NewPositionStore() *PositionStore {
+	return &PositionStore{}
+}
+
+// Open 建仓或加仓
+func (s *PositionStore) Open(ctx context.Context, mint solana.PublicKey, amount, costLamports uint64) (*Position, error) {
+	return s.Update(ctx, mint.String(), func(position *Position) *Position {
+		now := time.Now()
+		if position == nil {
+			position = &Position{Mint: mint.String(), OpenedAt: now}
+		}
+		position.InitialAmount += amount
+		position.Amount += amount
+		position.CostLamports += costLamports
+		if entry := position.EntryPrice(); entry > position.HighPrice {
+			position.HighPrice = entry
+		}
+		position.UpdatedAt = now
+		return position
+	})
+}
+
+// Reduce 减仓，数量不足时清仓
+func (s *PositionStore) Reduce(ctx context.Context, mint solana.PublicKey, amount uint64) error {
+	_, err := s.Update(ctx, mint.String(), func(position *Position) *Position {
+		if position == nil {
+			return nil
+		}
+		position.Amount -= min(amount, position.Amount)
+		position.UpdatedAt = time.Now()
+		return position
+	})
+	return err
+}
+
+// Update 在 WATCH/MULTI 事务中读取、修改并保存持仓，并发修改同一持仓时重试，避免互相覆盖。
+// fn 收到 nil 表示持仓不存在，返回 nil 表示不修改；数量为 0 的持仓会被删除
+func (s *PositionStore) Update(ctx context.Context, mint string, fn func(*Position) *Position) (*Position, error) {
+	if !global.Redis.Available() {
+		return nil, fmt.Errorf("Redis 未初始化")
+	}
+	var updated *Position
+	update := func(tx *redis.Tx) error {
+		position, err := getPosition(ctx, tx, mint)
+		if err != nil {
+			return err
+		}
+		if updated = fn(position); updated == nil {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return savePosition(ctx, pipe, updated)
+		})
+		return err
+	}
+	for i := 0; i < maxPositionRetries; i++ {
+		err := global.Redis.Watch(ctx, update, PositionsKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			// 随机退避，避免同时冲突的写入再次冲突
+			time.Sleep(time.Duration(rand.Int64N(int64(positionRetryDelay) * int64(i+1))))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("持仓 %s 并发修改冲突，重试 %d 次后放弃", mint, maxPositionRetries)
+}
+
+// Get 读取单个持仓，不存在时返回 nil
+func (s *PositionStore) Get(ctx context.Context, mint string) (*Position, error) {
+	if !global.Redis.Available() {
+		return nil, fmt.Errorf("Redis 未初始化")
+	}
+	return getPosition(ctx, global.Redis, mint)
+}
+
+// List 读取全部持仓
+func (s *PositionStore) List(ctx context.Context) ([]*Position, error) {
+	if !global.Redis.Available() {
+		return nil, fmt.Errorf("Redis 未初始化")
+	}
+	values, err := global.Redis.HGetAll(ctx, PositionsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取持仓列表失败: %w", err)
+	}
+	positions := make([]*Position, 0, len(values))
+	for mint, value := range values {
+		var position Position
+		if err := json.Unmarshal([]byte(value), &position); err != nil {
+			return nil, fmt.Errorf("解析持仓 %s 失败: %w", mint, err)
+		}
+		positions = append(positions, &position)
+	}
+	return positions, nil
+}
+
+// getPosition 读取单个持仓，不存在时返回 nil
+func getPosition(ctx context.Context, client redis.Cmdable, mint string) (*Position, error) {
+	data, err := client.HGet(ctx, PositionsKey, mint).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取持仓失败: %w", err)
+	}
+	var position Position
+	if err := json.Unmarshal(data, &position); err != nil {
+		return nil, fmt.Errorf("解析持仓失败: %w", err)
+	}
+	return &position, nil
+}
+
+// savePosition 保存持仓，数量为 0 时删除
+func savePosition(ctx context.Context, client redis.Cmdable, position *Position) error {
+	if position.Amount == 0 {
+		return client.HDel(ctx, PositionsKey, position.Mint).Err()
+	}
+	data, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("序列化持仓失败: %w", err)
+	}
+	return client.HSet(ctx, PositionsKey, position.Mint, data).Err()
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"meme/core"
+	"meme/global"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gagliardetto/solana-go"
+)
+
+// useMiniRedis 把 global.Redis 指向进程内的 miniredis，测试结束后恢复
+func useMiniRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := core.InitRedis(core.RedisConfig{Addrs: []string{server.Addr()}}, nil)
+	if err != nil {
+		t.Fatalf("连接 miniredis 失败: %v", err)
+	}
+	previous := global.Redis
+	global.Redis = client
+	t.Cleanup(func() {
+		global.Redis = previous
+		client.Close()
+	})
+	return server
+}
+
+// TestPositionStoreConcurrentUpdates 校验并发加仓、减仓不会互相覆盖
+func TestPositionStoreConcurrentUpdates(t *testing.T) {
+	useMiniRedis(t)
+	ctx := context.Background()
+	store := NewPositionStore()
+	mint := solana.NewWallet().PublicKey()
+
+	if _, err := store.Open(ctx, mint, 1_000_000, 1_000_000); err != nil {
+		t.Fatalf("建仓失败: %v", err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := store.Open(ctx, mint, 100, 50)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- store.Reduce(ctx, mint, 10)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("更新持仓失败: %v", err)
+		}
+	}
+
+	position, err := store.Get(ctx, mint.String())
+	if err != nil || position == nil {
+		t.Fatalf("读取持仓失败: %v", err)
+	}
+	if want := uint64(1_000_000 + workers*100 - workers*10); position.Amount != want {
+		t.Errorf("剩余数量为 %d，期望 %d", position.Amount, want)
+	}
+	if want := uint64(1_000_000 + workers*100); position.InitialAmount != want {
+		t.Errorf("建仓数量为 %d，期望 %d", position.InitialAmount, want)
+	}
+	if want := uint64(1_000_000 + workers*50); position.CostLamports != want {
+		t.Errorf("成本为 %d，期望 %d", position.CostLamports, want)
+	}
+}
+
+func TestPositionStoreReduceCloses(t *testing.T) {
+	useMiniRedis(t)
+	ctx := context.Background()
+	store := NewPositionStore()
+	mint := solana.NewWallet().PublicKey()
+
+	if err := store.Reduce(ctx, mint, 10); err != nil {
+		t.Fatalf("减仓不存在的持仓失败: %v", err)
+	}
+	if _, err := store.Open(ctx, mint, 100, 1000); err != nil {
+		t.Fatalf("建仓失败: %v", err)
+	}
+	if err := store.Reduce(ctx, mint, 150); err != nil {
+		t.Fatalf("减仓失败: %v", err)
+	}
+	position, err := store.Get(ctx, mint.String())
+	if err != nil {
+		t.Fatalf("读取持仓失败: %v", err)
+	}
+	if position != nil {
+		t.Errorf("清仓后持仓应被删除，实际为 %+v", position)
+	}
+}
+
+func TestPositionStoreUnavailable(t *testing.T) {
+	server := useMiniRedis(t)
+	server.Close()
+	global.Redis.Check(context.Background())
+	if _, err := NewPositionStore().Open(context.Background(), solana.NewWallet().PublicKey(), 1, 1); err == nil {
+		t.Error("Redis 不可用时应返回错误")
+	}
+}
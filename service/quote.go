@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"meme/core"
+	"meme/global"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// Quote 表示一次兑换报价，金额均为最小单位
+type Quote struct {
+	InputMint      solana.PublicKey
+	OutputMint     solana.PublicKey
+	InAmount       uint64
+	OutAmount      uint64
+	MinOutAmount   uint64 // 扣除最大滑点后的最少获得数量
+	SlippageBps    uint16
+	PriceImpactBps uint16
+	Raw            []byte           // 报价源的原始响应，构造兑换交易时原样回传
+	Pool           solana.PublicKey // 原生构造兑换时使用的池子
+}
+
+// Quoter 提供兑换报价
+type Quoter interface {
+	Quote(ctx context.Context, inputMint, outputMint solana.PublicKey, amount uint64, slippageBps uint16) (*Quote, error)
+}
+
+// SwapPlan 表示待签名的兑换交易内容
+type SwapPlan struct {
+	Instructions  []solana.Instruction
+	AddressTables map[solana.PublicKey]solana.PublicKeySlice
+}
+
+// SwapBuilder 根据报价构造兑换指令
+type SwapBuilder interface {
+	Quoter
+	BuildSwap(ctx context.Context, quote *Quote, user solana.PublicKey) (*SwapPlan, error)
+}
+
+// NewSwapBuilder 按 follow.router 创建跟单使用的兑换构造器，默认使用 Jupiter
+func NewSwapBuilder(client *rpc.Client, logger *slog.Logger) SwapBuilder {
+	if global.FollowConfig.Router == core.SwapRouterRaydium {
+		return NewRaydiumSwapBuilder(client, logger)
+	}
+	return NewJupiterSwapBuilder(client, logger)
+}
+
+// minOutAmount 按滑点计算最少获得数量
+func minOutAmount(outAmount uint64, slippageBps uint16) uint64 {
+	if slippageBps >= 10_000 {
+		return 0
+	}
+	out := new(big.Int).SetUint64(outAmount)
+	out.Mul(out, big.NewInt(int64(10_000-slippageBps)))
+	out.Quo(out, big.NewInt(10_000))
+	return out.Uint64()
+}
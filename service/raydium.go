@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"meme/core"
+	"meme/global"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go"
+	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	RaydiumAMMV4ProgramID      = "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"
+	RaydiumAMMAuthority        = "5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1"
+	RaydiumSwapBaseIn          = 9
+	raydiumPoolStateSize       = 752
+	raydiumPoolBaseMintOffset  = 400
+	raydiumPoolQuoteMintOffset = 432
+)
+
+// RaydiumPoolKeys 表示 Raydium AMM v4 兑换需要的全部账户
+type RaydiumPoolKeys struct {
+	ID               solana.PublicKey
+	Authority        solana.PublicKey
+	OpenOrders       solana.PublicKey
+	TargetOrders     solana.PublicKey
+	BaseVault        solana.PublicKey
+	QuoteVault       solana.PublicKey
+	BaseMint         solana.PublicKey
+	QuoteMint        solana.PublicKey
+	MarketProgramID  solana.PublicKey
+	MarketID         solana.PublicKey
+	MarketBids       solana.PublicKey
+	MarketAsks       solana.PublicKey
+	MarketEventQueue solana.PublicKey
+	MarketBaseVault  solana.PublicKey
+	MarketQuoteVault solana.PublicKey
+	MarketAuthority  solana.PublicKey
+
+	BaseNeedTakePnl    uint64
+	QuoteNeedTakePnl   uint64
+	SwapFeeNumerator   uint64
+	SwapFeeDenominator uint64
+}
+
+// decodeRaydiumPool 按 LIQUIDITY_STATE_LAYOUT_V4 解析池子账户
+func decodeRaydiumPool(id solana.PublicKey, data []byte) (*RaydiumPoolKeys, error) {
+	if len(data) < raydiumPoolStateSize {
+		return nil, fmt.Errorf("池子账户数据长度不正确: %d", len(data))
+	}
+	u64 := func(offset int) uint64 { return binary.LittleEndian.Uint64(data[offset : offset+8]) }
+	key := func(offset int) solana.PublicKey { return solana.PublicKeyFromBytes(data[offset : offset+32]) }
+
+	return &RaydiumPoolKeys{
+		ID:                 id,
+		Authority:          solana.MustPublicKeyFromBase58(RaydiumAMMAuthority),
+		SwapFeeNumerator:   u64(176),
+		SwapFeeDenominator: u64(184),
+		BaseNeedTakePnl:    u64(192),
+		QuoteNeedTakePnl:   u64(200),
+		BaseVault:          key(336),
+		QuoteVault:         key(368),
+		BaseMint:           key(raydiumPoolBaseMintOffset),
+		QuoteMint:          key(raydiumPoolQuoteMintOffset),
+		OpenOrders:         key(496),
+		MarketID:           key(528),
+		MarketProgramID:    key(560),
+		TargetOrders:       key(592),
+	}, nil
+}
+
+// decodeSerumMarket 按 MARKET_STATE_LAYOUT_V3 解析市场账户并补全池子账户
+func decodeSerumMarket(keys *RaydiumPoolKeys, data []byte) error {
+	if len(data) < 388 {
+		return fmt.Errorf("市场账户数据长度不正确: %d", len(data))
+	}
+	key := func(offset int) solana.PublicKey { return solana.PublicKeyFromBytes(data[offset : offset+32]) }
+
+	keys.MarketBaseVault = key(117)
+	keys.MarketQuoteVault = key(165)
+	keys.MarketEventQueue = key(253)
+	keys.MarketBids = key(285)
+	keys.MarketAsks = key(317)
+
+	authority, err := solana.CreateProgramAddress(
+		[][]byte{keys.MarketID.Bytes(), data[45:53]},
+		keys.MarketProgramID,
+	)
+	if err != nil {
+		return fmt.Errorf("推导市场 vault signer 失败: %w", err)
+	}
+	keys.MarketAuthority = authority
+	return nil
+}
+
+// NewRaydiumSwapBaseInInstruction 构造 swapBaseIn 指令，账户顺序与 transaction_demo.json 一致
+func NewRaydiumSwapBaseInInstruction(keys *RaydiumPoolKeys, userSource, userDestination, owner solana.PublicKey, amountIn, minAmountOut uint64) solana.Instruction {
+	data := make([]byte, 17)
+	data[0] = RaydiumSwapBaseIn
+	binary.LittleEndian.PutUint64(data[1:9], amountIn)
+	binary.LittleEndian.PutUint64(data[9:17], minAmountOut)
+
+	accounts := solana.AccountMetaSlice{
+		solana.NewAccountMeta(solana.TokenProgramID, false, false),
+		solana.NewAccountMeta(keys.ID, true, false),
+		solana.NewAccountMeta(keys.Authority, false, false),
+		solana.NewAccountMeta(keys.OpenOrders, true, false),
+		solana.NewAccountMeta(keys.TargetOrders, true, false),
+		solana.NewAccountMeta(keys.BaseVault, true, false),
+		solana.NewAccountMeta(keys.QuoteVault, true, false),
+		solana.NewAccountMeta(keys.MarketProgramID, false, false),
+		solana.NewAccountMeta(keys.MarketID, true, false),
+		solana.NewAccountMeta(keys.MarketBids, true, false),
+		solana.NewAccountMeta(keys.MarketAsks, true, false),
+		solana.NewAccountMeta(keys.MarketEventQueue, true, false),
+		solana.NewAccountMeta(keys.MarketBaseVault, true, false),
+		solana.NewAccountMeta(keys.MarketQuoteVault, true, false),
+		solana.NewAccountMeta(keys.MarketAuthority, false, false),
+		solana.NewAccountMeta(userSource, true, false),
+		solana.NewAccountMeta(userDestination, true, false),
+		solana.NewAccountMeta(owner, false, true),
+	}
+	return solana.NewInstruction(solana.MustPublicKeyFromBase58(RaydiumAMMV4ProgramID), accounts, data)
+}
+
+// ComputeRaydiumAmountOut 按恒定乘积公式扣除手续费后计算获得数量
+func ComputeRaydiumAmountOut(amountIn, reserveIn, reserveOut, feeNumerator, feeDenominator uint64) uint64 {
+	if reserveIn == 0 || reserveOut == 0 || feeDenominator == 0 {
+		return 0
+	}
+	in := new(big.Int).SetUint64(amountIn)
+	fee := new(big.Int).Mul(in, new(big.Int).SetUint64(feeNumerator))
+	fee.Add(fee, new(big.Int).SetUint64(feeDenominator-1)) // 手续费向上取整
+	fee.Quo(fee, new(big.Int).SetUint64(feeDenominator))
+	in.Sub(in, fee)
+
+	numerator := new(big.Int).Mul(in, new(big.Int).SetUint64(reserveOut))
+	denominator := new(big.Int).Add(new(big.Int).SetUint64(reserveIn), in)
+	return numerator.Quo(numerator, denominator).Uint64()
+}
+
+// RaydiumSwapBuilder 直接构造 Raydium AMM v4 兑换指令，不依赖聚合器
+type RaydiumSwapBuilder struct {
+	client *rpc.Client
+	logger *slog.Logger
+}
+
+// NewRaydiumSwapBuilder 创建 Raydium 兑换构造器
+func NewRaydiumSwapBuilder(client *rpc.Client, logger *slog.Logger) *RaydiumSwapBuilder {
+	return &RaydiumSwapBuilder{
+		client: client,
+		logger: logger,
+	}
+}
+
+// LoadPoolKeys 根据池子 id 读取池子与市场账户，推导兑换需要的全部账户
+func (r *RaydiumSwapBuilder) LoadPoolKeys(ctx context.Context, poolID solana.PublicKey) (*RaydiumPoolKeys, error) {
+	var cached RaydiumPoolKeys
+	if global.Cache.Get(ctx, core.CachePool, poolID.String(), &cached) {
+		return &cached, nil
+	}
+
+	pool, err := r.client.GetAccountInfo(ctx, poolID)
+	if err != nil {
+		return nil, fmt.Errorf("获取池子账户失败: %w", err)
+	}
+	keys, err := decodeRaydiumPool(poolID, pool.GetBinary())
+	if err != nil {
+		return nil, err
+	}
+
+	market, err := r.client.GetAccountInfo(ctx, keys.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("获取市场账户失败: %w", err)
+	}
+	if err := decodeSerumMarket(keys, market.GetBinary()); err != nil {
+		return nil, err
+	}
+	global.Cache.Set(ctx, core.CachePool, poolID.String(), keys)
+	return keys, nil
+}
+
+// FindPool 根据交易对查找 Raydium AMM v4 池子
+func (r *RaydiumSwapBuilder) FindPool(ctx context.Context, mintA, mintB solana.PublicKey) (solana.PublicKey, error) {
+	cacheKey := "pair:" + mintA.String() + ":" + mintB.String()
+	var cached solana.PublicKey
+	if global.Cache.Get(ctx, core.CachePool, cacheKey, &cached) {
+		return cached, nil
+	}
+
+	pairs := [][2]solana.PublicKey{{mintA, mintB}, {mintB, mintA}}
+	for _, pair := range pairs {
+		accounts, err := r.client.GetProgramAccountsWithOpts(ctx, solana.MustPublicKeyFromBase58(RaydiumAMMV4ProgramID), &rpc.GetProgramAccountsOpts{
+			Commitment: rpc.CommitmentConfirmed,
+			Filters: []rpc.RPCFilter{
+				{DataSize: raydiumPoolStateSize},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: raydiumPoolBaseMintOffset, Bytes: pair[0].Bytes()}},
+				{Memcmp: &rpc.RPCFilterMemcmp{Offset: raydiumPoolQuoteMintOffset, Bytes: pair[1].Bytes()}},
+			},
+		})
+		if err != nil {
+			return solana.PublicKey{}, fmt.Errorf("查询 Raydium 池子失败: %w", err)
+		}
+		if len(accounts) > 0 {
+			global.Cache.Set(ctx, core.CachePool, cacheKey, accounts[0].Pubkey)
+			return accounts[0].Pubkey, nil
+		}
+	}
+	return solana.PublicKey{}, fmt.Errorf("未找到 %s/%s 的 Raydium 池子", mintA, mintB)
+}
+
+// Reserves 返回池子中 base 与 quote 的可用储备
+func (r *RaydiumSwapBuilder) Reserves(ctx context.Context, keys *RaydiumPoolKeys) (uint64, uint64, error) {
+	base, err := r.vaultAmount(ctx, keys.BaseVault)
+	if err != nil {
+		return 0, 0, err
+	}
+	quote, err := r.vaultAmount(ctx, keys.QuoteVault)
+	if err != nil {
+		return 0, 0, err
+	}
+	if base > keys.BaseNeedTakePnl {
+		base -= keys.BaseNeedTakePnl
+	}
+	if quote > keys.QuoteNeedTakePnl {
+		quote -= keys.QuoteNeedTakePnl
+	}
+	return base, quote, nil
+}
+
+// Quote 根据池子储备计算报价
+func (r *RaydiumSwapBuilder) Quote(ctx context.Context, inputMint, outputMint solana.PublicKey, amount uint64, slippageBps uint16) (*Quote, error) {
+	poolID, err := r.FindPool(ctx, inputMint, outputMint)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := r.LoadPoolKeys(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	base, quote, err := r.Reserves(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	reserveIn, reserveOut := base, quote
+	if inputMint.Equals(keys.QuoteMint) {
+		reserveIn, reserveOut = quote, base
+	}
+	outAmount := ComputeRaydiumAmountOut(amount, reserveIn, reserveOut, keys.SwapFeeNumerator, keys.SwapFeeDenominator)
+
+	// 价格影响 = 成交均价相对池子现价的偏离
+	var impactBps uint16
+	if amount > 0 && reserveIn > 0 && outAmount > 0 {
+		spot := float64(reserveOut) / float64(reserveIn)
+		effective := float64(outAmount) / float64(amount)
+		if effective < spot {
+			impactBps = uint16((spot - effective) / spot * 10_000)
+		}
+	}
+
+	return &Quote{
+		InputMint:      inputMint,
+		OutputMint:     outputMint,
+		InAmount:       amount,
+		OutAmount:      outAmount,
+		MinOutAmount:   minOutAmount(outAmount, slippageBps),
+		SlippageBps:    slippageBps,
+		PriceImpactBps: impactBps,
+		Pool:           poolID,
+	}, nil
+}
+
+// BuildSwap 构造 swapBaseIn 兑换指令，缺少的关联代币账户会一并创建，SOL 通过 WSOL 账户包装
+func (r *RaydiumSwapBuilder) BuildSwap(ctx context.Context, quote *Quote, user solana.PublicKey) (*SwapPlan, error) {
+	if quote.Pool.IsZero() {
+		return nil, fmt.Errorf("报价缺少池子地址，无法构造 Raydium 兑换")
+	}
+	keys, err := r.LoadPoolKeys(ctx, quote.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	source, _, err := solana.FindAssociatedTokenAddress(user, quote.InputMint)
+	if err != nil {
+		return nil, fmt.Errorf("推导输入代币账户失败: %w", err)
+	}
+	destination, _, err := solana.FindAssociatedTokenAddress(user, quote.OutputMint)
+	if err != nil {
+		return nil, fmt.Errorf("推导输出代币账户失败: %w", err)
+	}
+
+	plan := &SwapPlan{}
+	for _, mint := range []solana.PublicKey{quote.InputMint, quote.OutputMint} {
+		ata, _, _ := solana.FindAssociatedTokenAddress(user, mint)
+		exists, err := r.accountExists(ctx, ata)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			plan.Instructions = append(plan.Instructions, associatedtokenaccount.NewCreateInstruction(user, user, mint).Build())
+		}
+	}
+
+	if quote.InputMint.Equals(solana.SolMint) {
+		plan.Instructions = append(plan.Instructions,
+			system.NewTransferInstruction(quote.InAmount, user, source).Build(),
+			token.NewSyncNativeInstruction(source).Build(),
+		)
+	}
+
+	plan.Instructions = append(plan.Instructions,
+		NewRaydiumSwapBaseInInstruction(keys, source, destination, user, quote.InAmount, quote.MinOutAmount),
+	)
+
+	// 兑换完成后关闭 WSOL 账户，取回 SOL
+	for _, account := range []struct {
+		mint    solana.PublicKey
+		address solana.PublicKey
+	}{{quote.InputMint, source}, {quote.OutputMint, destination}} {
+		if account.mint.Equals(solana.SolMint) {
+			plan.Instructions = append(plan.Instructions,
+				token.NewCloseAccountInstruction(account.address, user, user, []solana.PublicKey{}).Build(),
+			)
+		}
+	}
+	return plan, nil
+}
+
+// vaultAmount 查询代币账户余额（最小单位）
+func (r *RaydiumSwapBuilder) vaultAmount(ctx context.Context, vault solana.PublicKey) (uint64, error) {
+	balance, err := r.client.GetTokenAccountBalance(ctx, vault, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, fmt.Errorf("获取池子储备 %s 失败: %w", vault, err)
+	}
+	amount, err := strconv.ParseUint(balance.Value.Amount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析池子储备 %s 失败: %w", vault, err)
+	}
+	return amount, nil
+}
+
+// accountExists 判断账户是否已创建
+func (r *RaydiumSwapBuilder) accountExists(ctx context.Context, address solana.PublicKey) (bool, error) {
+	_, err := r.client.GetAccountInfo(ctx, address)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询账户 %s 失败: %w", address, err)
+	}
+	return true, nil
+}
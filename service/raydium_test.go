@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// loadDemoTransaction 读取仓库根目录的 transaction_demo.json
+func loadDemoTransaction(t *testing.T) (*rpc.GetTransactionResult, *solana.Transaction) {
+	t.Helper()
+	data, err := os.ReadFile("../transaction_demo.json")
+	if err != nil {
+		t.Fatalf("读取 transaction_demo.json 失败: %v", err)
+	}
+	var result rpc.GetTransactionResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("解析 transaction_demo.json 失败: %v", err)
+	}
+	tx, err := result.Transaction.GetTransaction()
+	if err != nil {
+		t.Fatalf("解码交易失败: %v", err)
+	}
+	return &result, tx
+}
+
+func TestRaydiumSwapBaseInMatchesDemo(t *testing.T) {
+	_, tx := loadDemoTransaction(t)
+	programID := solana.MustPublicKeyFromBase58(RaydiumAMMV4ProgramID)
+
+	var demo *solana.CompiledInstruction
+	for i := range tx.Message.Instructions {
+		ix := &tx.Message.Instructions[i]
+		if tx.Message.AccountKeys[ix.ProgramIDIndex].Equals(programID) {
+			demo = ix
+			break
+		}
+	}
+	if demo == nil {
+		t.Fatal("transaction_demo.json 中没有 Raydium AMM v4 指令")
+	}
+	if len(demo.Accounts) != 18 {
+		t.Fatalf("示例指令账户数为 %d，期望 18", len(demo.Accounts))
+	}
+	accounts, err := demo.ResolveInstructionAccounts(&tx.Message)
+	if err != nil {
+		t.Fatalf("解析示例指令账户失败: %v", err)
+	}
+	key := func(i int) solana.PublicKey { return accounts[i].PublicKey }
+
+	keys := &RaydiumPoolKeys{
+		ID:               key(1),
+		Authority:        key(2),
+		OpenOrders:       key(3),
+		TargetOrders:     key(4),
+		BaseVault:        key(5),
+		QuoteVault:       key(6),
+		MarketProgramID:  key(7),
+		MarketID:         key(8),
+		MarketBids:       key(9),
+		MarketAsks:       key(10),
+		MarketEventQueue: key(11),
+		MarketBaseVault:  key(12),
+		MarketQuoteVault: key(13),
+		MarketAuthority:  key(14),
+	}
+	if !keys.Authority.Equals(solana.MustPublicKeyFromBase58(RaydiumAMMAuthority)) {
+		t.Errorf("示例中的 AMM authority 为 %s，与常量 %s 不一致", keys.Authority, RaydiumAMMAuthority)
+	}
+
+	data := []byte(demo.Data)
+	if len(data) != 17 || data[0] != RaydiumSwapBaseIn {
+		t.Fatalf("示例指令数据不是 swapBaseIn: %x", data)
+	}
+	amountIn := binary.LittleEndian.Uint64(data[1:9])
+	minAmountOut := binary.LittleEndian.Uint64(data[9:17])
+
+	ix := NewRaydiumSwapBaseInInstruction(keys, key(15), key(16), key(17), amountIn, minAmountOut)
+	if !ix.ProgramID().Equals(programID) {
+		t.Errorf("program id 为 %s，期望 %s", ix.ProgramID(), programID)
+	}
+	built, err := ix.Data()
+	if err != nil {
+		t.Fatalf("序列化指令数据失败: %v", err)
+	}
+	if !bytes.Equal(built, data) {
+		t.Errorf("指令数据为 %x，期望 %x", built, data)
+	}
+
+	metas := ix.Accounts()
+	if len(metas) != len(accounts) {
+		t.Fatalf("账户数为 %d，期望 %d", len(metas), len(accounts))
+	}
+	for i, meta := range metas {
+		want := accounts[i]
+		if !meta.PublicKey.Equals(want.PublicKey) {
+			t.Errorf("第 %d 个账户为 %s，期望 %s", i, meta.PublicKey, want.PublicKey)
+		}
+		// 消息中的权限是全部指令的并集，手续费支付者总是可写，指令中 owner 只需签名
+		feePayer := want.PublicKey.Equals(tx.Message.AccountKeys[0])
+		if (meta.IsWritable != want.IsWritable && !feePayer) || meta.IsSigner != want.IsSigner {
+			t.Errorf("第 %d 个账户 %s 权限为 writable=%v signer=%v，期望 writable=%v signer=%v",
+				i, meta.PublicKey, meta.IsWritable, meta.IsSigner, want.IsWritable, want.IsSigner)
+		}
+	}
+}
+
+func TestComputeRaydiumAmountOut(t *testing.T) {
+	tests := []struct {
+		name                            string
+		amountIn, reserveIn, reserveOut uint64
+		want                            uint64
+	}{
+		// 0.25% 手续费: 1000 扣除 3（向上取整）后 997 * 1e6 / (1e6 + 997)
+		{"普通兑换", 1000, 1_000_000, 1_000_000, 996},
+		{"储备为 0", 1000, 0, 1_000_000, 0},
+		{"输入为 0", 0, 1_000_000, 1_000_000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeRaydiumAmountOut(tt.amountIn, tt.reserveIn, tt.reserveOut, 25, 10_000)
+			if got != tt.want {
+				t.Errorf("ComputeRaydiumAmountOut = %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
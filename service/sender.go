@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"meme/core"
+	"sort"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	DefaultComputeUnitLimit    = 200_000
+	DefaultPriorityFeeCap      = 5_000_000 // micro-lamports / CU
+	DefaultPriorityFeePercent  = 75        // 取最近优先费的第 75 百分位
+	DefaultRebroadcastInterval = 2 * time.Second
+	DefaultMaxSendAttempts     = 3
+)
+
+// ErrBlockhashExpired 表示交易在区块哈希过期前未能上链
+var ErrBlockhashExpired = errors.New("blockhash expired before confirmation")
+
+// SendResult 表示一笔交易的最终发送结果
+type SendResult struct {
+	Signature   solana.Signature
+	Slot        uint64 // 上链所在 slot
+	Fee         uint64 // 实际支付的手续费 (lamports)
+	PriorityFee uint64 // 设置的优先费 (micro-lamports / CU)
+	Status      rpc.ConfirmationStatusType
+	Attempts    int
+	Err         error // 链上执行错误
+}
+
+// TransactionSender 负责签名、广播交易并跟踪确认状态
+type TransactionSender struct {
+	client *rpc.Client
+	logger *slog.Logger
+
+	ComputeUnitLimit    uint32
+	PriorityFeeCap      uint64
+	PriorityFeePercent  int
+	RebroadcastInterval time.Duration
+	MaxAttempts         int
+	Commitment          rpc.ConfirmationStatusType
+}
+
+// NewTransactionSender 创建一个新的交易发送器
+func NewTransactionSender(client *rpc.Client, logger *slog.Logger) *TransactionSender {
+	return &TransactionSender{
+		client:              client,
+		logger:              logger,
+		ComputeUnitLimit:    DefaultComputeUnitLimit,
+		PriorityFeeCap:      DefaultPriorityFeeCap,
+		PriorityFeePercent:  DefaultPriorityFeePercent,
+		RebroadcastInterval: DefaultRebroadcastInterval,
+		MaxAttempts:         DefaultMaxSendAttempts,
+		Commitment:          rpc.ConfirmationStatusConfirmed,
+	}
+}
+
+// EstimatePriorityFee 根据 getRecentPrioritizationFees 估算优先费
+func (ts *TransactionSender) EstimatePriorityFee(ctx context.Context, accounts solana.PublicKeySlice) (uint64, error) {
+	fees, err := ts.client.GetRecentPrioritizationFees(ctx, accounts)
+	if err != nil {
+		return 0, fmt.Errorf("获取最近优先费失败: %w", err)
+	}
+
+	var values []uint64
+	for _, fee := range fees {
+		if fee.PrioritizationFee > 0 {
+			values = append(values, fee.PrioritizationFee)
+		}
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	idx := len(values) * ts.PriorityFeePercent / 100
+	if idx >= len(values) {
+		idx = len(values) - 1
+	}
+	fee := values[idx]
+	if ts.PriorityFeeCap > 0 && fee > ts.PriorityFeeCap {
+		fee = ts.PriorityFeeCap
+	}
+	return fee, nil
+}
+
+// Send 添加 ComputeBudget 指令后签名并广播交易，直到确认或区块哈希过期。
+// 区块哈希过期后使用新的区块哈希重新签名，最多尝试 MaxAttempts 次。
+func (ts *TransactionSender) Send(ctx context.Context, payer solana.PrivateKey, instructions []solana.Instruction, opts ...solana.TransactionOption) (*SendResult, error) {
+	priorityFee, err := ts.EstimatePriorityFee(ctx, writableAccounts(instructions))
+	if err != nil {
+		ts.logger.Warn("估算优先费失败，使用 0", core.LogKeyError, err)
+	}
+
+	budget := []solana.Instruction{
+		computebudget.NewSetComputeUnitLimitInstruction(ts.ComputeUnitLimit).Build(),
+		computebudget.NewSetComputeUnitPriceInstruction(priorityFee).Build(),
+	}
+	instructions = append(budget, instructions...)
+	opts = append(opts, solana.TransactionPayer(payer.PublicKey()))
+
+	for attempt := 1; attempt <= ts.MaxAttempts; attempt++ {
+		result, err := ts.sendOnce(ctx, payer, instructions, opts)
+		if errors.Is(err, ErrBlockhashExpired) {
+			ts.logger.Warn("区块哈希已过期，重新签名", "attempt", attempt, "max_attempts", ts.MaxAttempts)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.PriorityFee = priorityFee
+		result.Attempts = attempt
+		return result, nil
+	}
+	return nil, fmt.Errorf("交易发送 %d 次后仍未确认: %w", ts.MaxAttempts, ErrBlockhashExpired)
+}
+
+// sendOnce 使用最新区块哈希签名并循环广播，直到确认或区块哈希过期
+func (ts *TransactionSender) sendOnce(ctx context.Context, payer solana.PrivateKey, instructions []solana.Instruction, opts []solana.TransactionOption) (*SendResult, error) {
+	latest, err := ts.client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("获取最新区块哈希失败: %w", err)
+	}
+
+	tx, err := solana.NewTransaction(instructions, latest.Value.Blockhash, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("构造交易失败: %w", err)
+	}
+	_, err = tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(payer.PublicKey()) {
+			return &payer
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("签名交易失败: %w", err)
+	}
+
+	maxRetries := uint(0)
+	sendOpts := rpc.TransactionOpts{
+		SkipPreflight:       true,
+		PreflightCommitment: rpc.CommitmentConfirmed,
+		MaxRetries:          &maxRetries,
+	}
+	signature, err := ts.client.SendTransactionWithOpts(ctx, tx, sendOpts)
+	if err != nil {
+		return nil, fmt.Errorf("发送交易失败: %w", err)
+	}
+	ts.logger.Info("交易已广播", core.LogKeyCopySignature, signature)
+
+	ticker := time.NewTicker(ts.RebroadcastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := ts.signatureStatus(ctx, signature)
+		if err != nil {
+			ts.logger.Warn("查询交易状态失败", core.LogKeyCopySignature, signature, core.LogKeyError, err)
+		} else if status != nil {
+			if status.Err != nil {
+				return ts.result(ctx, signature, status, fmt.Errorf("交易执行失败: %v", status.Err)), nil
+			}
+			if confirmationReached(status.ConfirmationStatus, ts.Commitment) {
+				return ts.result(ctx, signature, status, nil), nil
+			}
+		}
+
+		height, err := ts.client.GetBlockHeight(ctx, rpc.CommitmentConfirmed)
+		if err != nil {
+			ts.logger.Warn("获取区块高度失败", core.LogKeyError, err)
+			continue
+		}
+		if height > latest.Value.LastValidBlockHeight {
+			return nil, ErrBlockhashExpired
+		}
+
+		if _, err := ts.client.SendTransactionWithOpts(ctx, tx, sendOpts); err != nil {
+			ts.logger.Warn("重新广播交易失败", core.LogKeyCopySignature, signature, core.LogKeyError, err)
+		}
+	}
+}
+
+// signatureStatus 查询单个签名的状态，未找到时返回 nil
+func (ts *TransactionSender) signatureStatus(ctx context.Context, signature solana.Signature) (*rpc.SignatureStatusesResult, error) {
+	statuses, err := ts.client.GetSignatureStatuses(ctx, false, signature)
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(statuses.Value) == 0 {
+		return nil, nil
+	}
+	return statuses.Value[0], nil
+}
+
+// result 组装发送结果，并尽量查询实际支付的手续费
+func (ts *TransactionSender) result(ctx context.Context, signature solana.Signature, status *rpc.SignatureStatusesResult, txErr error) *SendResult {
+	result := &SendResult{
+		Signature: signature,
+		Slot:      status.Slot,
+		Status:    status.ConfirmationStatus,
+		Err:       txErr,
+	}
+
+	tx, err := GetTransactionCached(ctx, ts.client, signature, rpc.CommitmentConfirmed)
+	if err != nil {
+		ts.logger.Warn("获取交易手续费失败", core.LogKeyCopySignature, signature, core.LogKeyError, err)
+	} else if tx.Meta != nil {
+		result.Fee = tx.Meta.Fee
+	}
+
+	ts.logger.Info("交易已确认", core.LogKeyCopySignature, signature, core.LogKeyCopySlot, result.Slot, "fee", result.Fee, "status", result.Status)
+	return result
+}
+
+// confirmationReached 判断当前确认级别是否达到目标级别
+func confirmationReached(current, target rpc.ConfirmationStatusType) bool {
+	levels := map[rpc.ConfirmationStatusType]int{
+		rpc.ConfirmationStatusProcessed: 1,
+		rpc.ConfirmationStatusConfirmed: 2,
+		rpc.ConfirmationStatusFinalized: 3,
+	}
+	return levels[current] >= levels[target] && levels[current] > 0
+}
+
+// writableAccounts 收集指令中的可写账户，用于估算优先费
+func writableAccounts(instructions []solana.Instruction) solana.PublicKeySlice {
+	var accounts solana.PublicKeySlice
+	for _, instruction := range instructions {
+		for _, meta := range instruction.Accounts() {
+			if meta.IsWritable {
+				accounts.UniqueAppend(meta.PublicKey)
+			}
+		}
+	}
+	return accounts
+}
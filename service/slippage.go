@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"meme/core"
+	"meme/global"
+	"strconv"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	RejectedTradesKey   = "follow:rejected"
+	MaxRejectedTrades   = 1000
+	DefaultMaxSlippage  = 300  // 3%
+	DefaultMaxDeviation = 1500 // 15%
+	TradeSideBuy        = "buy"
+	TradeSideSell       = "sell"
+)
+
+// LeaderFill 表示被跟单地址在一笔交易中的成交情况，金额均为最小单位
+type LeaderFill struct {
+	Wallet      solana.PublicKey
+	Mint        solana.PublicKey
+	Side        string
+	TokenAmount uint64
+	SolAmount   uint64 // lamports
+}
+
+// Price 返回每最小单位代币对应的 lamports
+func (f LeaderFill) Price() float64 {
+	if f.TokenAmount == 0 {
+		return 0
+	}
+	return float64(f.SolAmount) / float64(f.TokenAmount)
+}
+
+// RejectedTrade 表示一笔因保护规则被跳过的跟单交易
+type RejectedTrade struct {
+	Signature   string    `json:"signature"`
+	Wallet      string    `json:"wallet"`
+	Label       string    `json:"label"`
+	Mint        string    `json:"mint"`
+	Side        string    `json:"side"`
+	LeaderPrice float64   `json:"leader_price"`
+	QuotePrice  float64   `json:"quote_price"`
+	Reason      string    `json:"reason"`
+	Time        time.Time `json:"time"`
+}
+
+// SlippageGuard 在签名前检查跟单交易的滑点与价格偏离
+type SlippageGuard struct {
+	logger               *slog.Logger
+	MaxSlippageBps       uint16
+	MaxPriceDeviationBps uint16
+}
+
+// NewSlippageGuard 根据 follow 配置创建滑点保护
+func NewSlippageGuard(logger *slog.Logger) *SlippageGuard {
+	guard := &SlippageGuard{
+		logger:               logger,
+		MaxSlippageBps:       global.FollowConfig.MaxSlippageBps,
+		MaxPriceDeviationBps: global.FollowConfig.MaxPriceDeviationBps,
+	}
+	if guard.MaxSlippageBps == 0 {
+		guard.MaxSlippageBps = DefaultMaxSlippage
+	}
+	if guard.MaxPriceDeviationBps == 0 {
+		guard.MaxPriceDeviationBps = DefaultMaxDeviation
+	}
+	return guard
+}
+
+// ForWallet 返回应用了地址级滑点设置的保护规则
+func (g *SlippageGuard) ForWallet(wallet core.WalletConfig) *SlippageGuard {
+	guard := *g
+	if wallet.Follow.MaxSlippageBps > 0 {
+		guard.MaxSlippageBps = wallet.Follow.MaxSlippageBps
+	}
+	return &guard
+}
+
+// Check 校验最新报价，通过时写入最少获得数量，否则返回拒绝原因
+func (g *SlippageGuard) Check(fill *LeaderFill, quote *Quote) (string, bool) {
+	if quote == nil || quote.InAmount == 0 || quote.OutAmount == 0 {
+		return "报价无效", false
+	}
+	if quote.PriceImpactBps > g.MaxSlippageBps {
+		return fmt.Sprintf("报价价格影响 %d bps 超过最大滑点 %d bps", quote.PriceImpactBps, g.MaxSlippageBps), false
+	}
+
+	leaderPrice := fill.Price()
+	currentPrice := quotePrice(fill.Side, quote)
+	if leaderPrice > 0 {
+		deviation := math.Abs(currentPrice-leaderPrice) / leaderPrice * 10_000
+		if deviation > float64(g.MaxPriceDeviationBps) {
+			return fmt.Sprintf("当前价格偏离跟单成交价 %.0f bps，超过上限 %d bps", deviation, g.MaxPriceDeviationBps), false
+		}
+	}
+
+	quote.SlippageBps = g.MaxSlippageBps
+	quote.MinOutAmount = minOutAmount(quote.OutAmount, g.MaxSlippageBps)
+	return "", true
+}
+
+// Reject 记录被拒绝的跟单交易
+func (g *SlippageGuard) Reject(ctx context.Context, signature string, fill *LeaderFill, quote *Quote, reason string) {
+	rejected := RejectedTrade{
+		Signature: signature,
+		Reason:    reason,
+		Time:      time.Now(),
+	}
+	if fill != nil {
+		rejected.Wallet = fill.Wallet.String()
+		rejected.Label = global.SystemConfig().Label(rejected.Wallet)
+		rejected.Mint = fill.Mint.String()
+		rejected.Side = fill.Side
+		rejected.LeaderPrice = fill.Price()
+		if quote != nil {
+			rejected.QuotePrice = quotePrice(fill.Side, quote)
+		}
+	}
+	g.logger.Info("跳过跟单交易", "reason", reason)
+
+	if !global.Redis.Available() {
+		return
+	}
+	data, err := json.Marshal(rejected)
+	if err != nil {
+		g.logger.Error("序列化拒绝记录失败", core.LogKeySignature, signature, core.LogKeyError, err)
+		return
+	}
+	if err := global.Redis.LPush(ctx, RejectedTradesKey, data).Err(); err != nil {
+		g.logger.Warn("写入拒绝记录失败", core.LogKeySignature, signature, core.LogKeyError, err)
+		return
+	}
+	global.Redis.LTrim(ctx, RejectedTradesKey, 0, MaxRejectedTrades-1)
+}
+
+// quotePrice 按跟单方向把报价换算成每最小单位代币对应的 lamports
+func quotePrice(side string, quote *Quote) float64 {
+	if side == TradeSideBuy {
+		return float64(quote.InAmount) / float64(quote.OutAmount)
+	}
+	return float64(quote.OutAmount) / float64(quote.InAmount)
+}
+
+// parseLeaderFill 从交易详情中解析跟单地址对指定 mint 的成交数量与 SOL 变化
+func parseLeaderFill(tx *rpc.GetTransactionResult, wallet, mint solana.PublicKey) (*LeaderFill, error) {
+	if tx == nil || tx.Meta == nil || tx.Transaction == nil {
+		return nil, fmt.Errorf("交易详情为空")
+	}
+
+	var preAmount, postAmount uint64
+	found := false
+	for _, balance := range tx.Meta.PreTokenBalances {
+		if balance.Mint == mint && balance.Owner != nil && balance.Owner.Equals(wallet) {
+			preAmount, _ = strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+			found = true
+		}
+	}
+	for _, balance := range tx.Meta.PostTokenBalances {
+		if balance.Mint == mint && balance.Owner != nil && balance.Owner.Equals(wallet) {
+			postAmount, _ = strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+			found = true
+		}
+	}
+	if !found || preAmount == postAmount {
+		return nil, fmt.Errorf("未找到 %s 的 %s 成交记录", wallet, mint)
+	}
+
+	parsed, err := tx.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("解析交易失败: %w", err)
+	}
+	keys := append(solana.PublicKeySlice{}, parsed.Message.AccountKeys...)
+	keys = append(keys, tx.Meta.LoadedAddresses.Writable...)
+	keys = append(keys, tx.Meta.LoadedAddresses.ReadOnly...)
+
+	index := -1
+	for i, key := range keys {
+		if key.Equals(wallet) {
+			index = i
+			break
+		}
+	}
+	if index < 0 || index >= len(tx.Meta.PreBalances) || index >= len(tx.Meta.PostBalances) {
+		return nil, fmt.Errorf("交易中未找到账户 %s", wallet)
+	}
+
+	// 手续费由第一个签名者支付，计算成交金额时需要加回
+	preSol := tx.Meta.PreBalances[index]
+	postSol := tx.Meta.PostBalances[index]
+	if index == 0 {
+		postSol += tx.Meta.Fee
+	}
+
+	fill := &LeaderFill{Wallet: wallet, Mint: mint}
+	if postAmount > preAmount {
+		fill.Side = TradeSideBuy
+		fill.TokenAmount = postAmount - preAmount
+		if preSol > postSol {
+			fill.SolAmount = preSol - postSol
+		}
+	} else {
+		fill.Side = TradeSideSell
+		fill.TokenAmount = preAmount - postAmount
+		if postSol > preSol {
+			fill.SolAmount = postSol - preSol
+		}
+	}
+	return fill, nil
+}
@@ -10,17 +10,46 @@ import (
 	"github.com/gagliardetto/solana-go/programs/token"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/spf13/cobra"
-	"log"
+	"log/slog"
+	"meme/core"
+	"meme/global"
+	"meme/utils"
 	"net/http"
 )
 
 var TokenCmd = &cobra.Command{
-	Use:   "token",
+	Use:   "token <mint>...",
 	Short: "Get token metadata",
-	Run: func(cmd *cobra.Command, args []string) {
-		client := rpc.New(rpc.MainNetBeta_RPC)
-		mint := solana.MustPublicKeyFromBase58(args[0])
-		GetTokenMetadata(client, mint)
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := global.RpcClient
+		mints := make([]solana.PublicKey, 0, len(args))
+		for _, arg := range args {
+			mint, err := solana.PublicKeyFromBase58(arg)
+			if err != nil {
+				return fmt.Errorf("mint 地址无效 %q: %w", arg, err)
+			}
+			mints = append(mints, mint)
+		}
+
+		ctx := cmd.Context()
+		metadata, err := GetTokenMetadataBatch(ctx, client, mints)
+		if err != nil {
+			return err
+		}
+		decimals, err := GetTokenDecimals(ctx, client, mints)
+		if err != nil {
+			return err
+		}
+		for _, mint := range mints {
+			fmt.Printf("代币地址 (Mint): %s\n", mint)
+			fmt.Printf("代币名称: %s\n", metadata[mint].Name)
+			fmt.Printf("代币符号: %s\n", metadata[mint].Symbol)
+			fmt.Printf("元数据 URI: %s\n", metadata[mint].URI)
+			fmt.Printf("代币精度: %d\n", decimals[mint])
+			fmt.Println("--------------------------------------")
+		}
+		return nil
 	},
 }
 
@@ -31,21 +60,21 @@ type TokenMetadata struct {
 }
 
 type TokenService struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func NewTokenService(logger *log.Logger) *TokenService {
+func NewTokenService(logger *slog.Logger) *TokenService {
 	return &TokenService{
 		logger: logger,
 	}
 }
 
-func GetTokenMetadata(client *rpc.Client, mint solana.PublicKey) (TokenMetadata, token.Mint) {
-	// Metadata program ID
-	metadataProgramID := solana.MustPublicKeyFromBase58("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s")
+// Metadata program ID
+var metadataProgramID = solana.MustPublicKeyFromBase58("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s")
 
-	// Derive metadata address
-	metadataAddress, _, err := solana.FindProgramAddress(
+// findMetadataAddress 推导代币的元数据账户地址
+func findMetadataAddress(mint solana.PublicKey) (solana.PublicKey, error) {
+	address, _, err := solana.FindProgramAddress(
 		[][]byte{
 			[]byte("metadata"),
 			metadataProgramID.Bytes(),
@@ -53,6 +82,12 @@ func GetTokenMetadata(client *rpc.Client, mint solana.PublicKey) (TokenMetadata,
 		},
 		metadataProgramID,
 	)
+	return address, err
+}
+
+func GetTokenMetadata(client *rpc.Client, mint solana.PublicKey) (TokenMetadata, token.Mint) {
+	// Derive metadata address
+	metadataAddress, err := findMetadataAddress(mint)
 	if err != nil {
 		fmt.Printf("无法推导元数据地址: %v\n", err)
 		return TokenMetadata{}, token.Mint{}
@@ -81,6 +116,92 @@ func GetTokenMetadata(client *rpc.Client, mint solana.PublicKey) (TokenMetadata,
 	return decodedMetadata, mintData
 }
 
+// GetTokenMetadataBatch 通过批量请求查询多个代币的元数据，单个代币查询失败时跳过该代币
+func GetTokenMetadataBatch(ctx context.Context, client *rpc.Client, mints []solana.PublicKey) (map[solana.PublicKey]TokenMetadata, error) {
+	metadata := make(map[solana.PublicKey]TokenMetadata, len(mints))
+	var missing []solana.PublicKey
+	for _, mint := range mints {
+		var cached TokenMetadata
+		if global.Cache.Get(ctx, core.CacheMetadata, mint.String(), &cached) {
+			metadata[mint] = cached
+		} else {
+			missing = append(missing, mint)
+		}
+	}
+	if len(missing) == 0 {
+		return metadata, nil
+	}
+	mints = missing
+
+	requests := make([]utils.BatchRequest, 0, len(mints))
+	for _, mint := range mints {
+		address, err := findMetadataAddress(mint)
+		if err != nil {
+			return nil, fmt.Errorf("无法推导 %s 的元数据地址: %w", mint, err)
+		}
+		requests = append(requests, utils.BatchRequest{
+			Method: "getAccountInfo",
+			Params: []interface{}{address.String(), map[string]string{"encoding": "base64", "commitment": "confirmed"}},
+		})
+	}
+
+	results, err := utils.NewBatchClient(client).Call(ctx, requests)
+	if err != nil {
+		return nil, fmt.Errorf("批量获取元数据失败: %w", err)
+	}
+	for i, result := range results {
+		var account rpc.GetAccountInfoResult
+		if err := result.Decode(&account); err != nil || account.Value == nil {
+			fmt.Printf("获取 %s 元数据失败: %v\n", mints[i], err)
+			continue
+		}
+		metadata[mints[i]] = parseTokenMetadata(account.Value.Data.GetBinary())
+		global.Cache.Set(ctx, core.CacheMetadata, mints[i].String(), metadata[mints[i]])
+	}
+	return metadata, nil
+}
+
+// GetTokenDecimals 通过批量 getTokenSupply 查询多个代币的精度，单个代币查询失败时跳过该代币
+func GetTokenDecimals(ctx context.Context, client *rpc.Client, mints []solana.PublicKey) (map[solana.PublicKey]uint8, error) {
+	decimals := make(map[solana.PublicKey]uint8, len(mints))
+	var missing []solana.PublicKey
+	for _, mint := range mints {
+		var cached uint8
+		if global.Cache.Get(ctx, core.CacheDecimals, mint.String(), &cached) {
+			decimals[mint] = cached
+		} else {
+			missing = append(missing, mint)
+		}
+	}
+	if len(missing) == 0 {
+		return decimals, nil
+	}
+	mints = missing
+
+	requests := make([]utils.BatchRequest, 0, len(mints))
+	for _, mint := range mints {
+		requests = append(requests, utils.BatchRequest{
+			Method: "getTokenSupply",
+			Params: []interface{}{mint.String(), map[string]string{"commitment": "confirmed"}},
+		})
+	}
+
+	results, err := utils.NewBatchClient(client).Call(ctx, requests)
+	if err != nil {
+		return nil, fmt.Errorf("批量获取精度失败: %w", err)
+	}
+	for i, result := range results {
+		var supply rpc.GetTokenSupplyResult
+		if err := result.Decode(&supply); err != nil || supply.Value == nil {
+			fmt.Printf("获取 %s 精度失败: %v\n", mints[i], err)
+			continue
+		}
+		decimals[mints[i]] = supply.Value.Decimals
+		global.Cache.Set(ctx, core.CacheDecimals, mints[i].String(), supply.Value.Decimals)
+	}
+	return decimals, nil
+}
+
 func GetTokenPrice(mint solana.PublicKey) (float64, error) {
 	// 调用外部 API 获取代币价格，例如 CoinGecko 或 Serum 数据源
 	// 这是伪代码，需要替换为实际 API 调用
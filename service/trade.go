@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"meme/core"
+	"meme/global"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SOLDecimals = 9
+
+	USDCMint = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	USDTMint = "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCE8BfUJBNYb"
+)
+
+// dexPrograms 按优先级排列的 DEX 程序，聚合器排在前面，命中多个时取第一个
+var dexPrograms = []struct {
+	name      string
+	programID string
+}{
+	{"jupiter", "JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4"},
+	{"pumpfun", "6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P"},
+	{"pumpswap", "pAMMBay6oceH9fJKBRHGP5D4bD4sWpmSwMn52FMfXEA"},
+	{"raydium_amm", "675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8"},
+	{"raydium_clmm", "CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK"},
+	{"raydium_cpmm", "CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C"},
+	{"orca", "whirLbMiicVdio4qvUfM5KAg6Ct8VwpYzGff3uctyCc"},
+	{"meteora_dlmm", "LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo"},
+}
+
+// tokenDelta 表示某个代币在交易前后的余额
+type tokenDelta struct {
+	pre, post uint64
+	decimals  uint8
+}
+
+func (d tokenDelta) changed() bool { return d.pre != d.post }
+
+// amount 返回余额变化的绝对值
+func (d tokenDelta) amount() uint64 {
+	if d.post > d.pre {
+		return d.post - d.pre
+	}
+	return d.pre - d.post
+}
+
+// ParseTrades 按钱包的余额变化解析交易中的买卖。每个发生变化的非计价代币为一个 leg；
+// 计价代币优先取 SOL（含 WSOL，已扣除手续费），没有 SOL 变化时取 USDC / USDT。
+// 只有一个与计价代币方向相反的 leg 时才能确定其计价金额，否则计价金额与价格记为 0
+func ParseTrades(tx *rpc.GetTransactionResult, wallet string) ([]core.Trade, error) {
+	if tx == nil || tx.Meta == nil {
+		return nil, ErrNotTrade
+	}
+	if tx.Meta.Err != nil {
+		return nil, ErrNotTrade
+	}
+	parsed, err := tx.Transaction.GetTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("解析交易失败: %w", err)
+	}
+	keys := append(solana.PublicKeySlice{}, parsed.Message.AccountKeys...)
+	keys = append(keys, tx.Meta.LoadedAddresses.Writable...)
+	keys = append(keys, tx.Meta.LoadedAddresses.ReadOnly...)
+
+	owner, err := solana.PublicKeyFromBase58(wallet)
+	if err != nil {
+		return nil, err
+	}
+	deltas := make(map[string]*tokenDelta)
+	var mints []string // 保持出现顺序，保证 leg 序号稳定
+	add := func(balances []rpc.TokenBalance, post bool) error {
+		for _, balance := range balances {
+			if balance.Owner == nil || !balance.Owner.Equals(owner) || balance.UiTokenAmount == nil {
+				continue
+			}
+			amount, err := strconv.ParseUint(balance.UiTokenAmount.Amount, 10, 64)
+			if err != nil {
+				return fmt.Errorf("解析代币数量 %q 失败: %w", balance.UiTokenAmount.Amount, err)
+			}
+			mint := balance.Mint.String()
+			delta, ok := deltas[mint]
+			if !ok {
+				delta = &tokenDelta{decimals: balance.UiTokenAmount.Decimals}
+				deltas[mint] = delta
+				mints = append(mints, mint)
+			}
+			if post {
+				delta.post += amount
+			} else {
+				delta.pre += amount
+			}
+		}
+		return nil
+	}
+	if err := add(tx.Meta.PreTokenBalances, false); err != nil {
+		return nil, err
+	}
+	if err := add(tx.Meta.PostTokenBalances, true); err != nil {
+		return nil, err
+	}
+
+	// SOL 余额变化，钱包支付手续费时加回手续费，WSOL 合并到 SOL
+	sol := tokenDelta{decimals: SOLDecimals}
+	for i, key := range keys {
+		if !key.Equals(owner) || i >= len(tx.Meta.PreBalances) || i >= len(tx.Meta.PostBalances) {
+			continue
+		}
+		sol.pre, sol.post = tx.Meta.PreBalances[i], tx.Meta.PostBalances[i]
+		if i == 0 {
+			sol.pre -= min(tx.Meta.Fee, sol.pre)
+		}
+		break
+	}
+	if wsol, ok := deltas[solana.SolMint.String()]; ok {
+		sol.pre += wsol.pre
+		sol.post += wsol.post
+		delete(deltas, solana.SolMint.String())
+	}
+
+	quoteMint, quote := solana.SolMint.String(), sol
+	if !sol.changed() {
+		for _, stable := range []string{USDCMint, USDTMint} {
+			if delta, ok := deltas[stable]; ok && delta.changed() {
+				quoteMint, quote = stable, *delta
+				delete(deltas, stable)
+				break
+			}
+		}
+	}
+
+	var trades []core.Trade
+	opposite := 0
+	for _, mint := range mints {
+		delta, ok := deltas[mint]
+		if !ok || !delta.changed() {
+			continue
+		}
+		trade := core.Trade{
+			Signature:     parsed.Signatures[0].String(),
+			Leg:           len(trades),
+			Slot:          tx.Slot,
+			Wallet:        wallet,
+			Mint:          mint,
+			Direction:     core.TradeDirectionSell,
+			TokenAmount:   delta.amount(),
+			TokenDecimals: delta.decimals,
+			QuoteMint:     quoteMint,
+			QuoteDecimals: quote.decimals,
+			DEX:           detectDEX(keys),
+			Fee:           tx.Meta.Fee,
+		}
+		if delta.post > delta.pre {
+			trade.Direction = core.TradeDirectionBuy
+		}
+		if tx.BlockTime != nil {
+			trade.BlockTime = tx.BlockTime.Time()
+		}
+		// 买入时计价代币减少，卖出时增加
+		if (trade.Direction == core.TradeDirectionBuy) == (quote.post < quote.pre) {
+			opposite++
+		}
+		trades = append(trades, trade)
+	}
+	if len(trades) == 0 {
+		return nil, ErrNotTrade
+	}
+	if opposite == 1 && quote.changed() {
+		for i := range trades {
+			if (trades[i].Direction == core.TradeDirectionBuy) != (quote.post < quote.pre) {
+				continue
+			}
+			trades[i].QuoteAmount = quote.amount()
+			if ui := trades[i].TokenUI(); ui > 0 {
+				trades[i].Price = trades[i].QuoteUI() / ui
+			}
+		}
+	}
+	return trades, nil
+}
+
+// detectDEX 根据交易引用的程序判断 DEX，无法识别时返回 unknown
+func detectDEX(keys solana.PublicKeySlice) string {
+	for _, dex := range dexPrograms {
+		for _, key := range keys {
+			if key.String() == dex.programID {
+				return dex.name
+			}
+		}
+	}
+	return "unknown"
+}
+
+// RecordTrades 解析交易中钱包的买卖并写入交易存储，未配置存储时直接返回
+func (s *TransactionService) RecordTrades(ctx context.Context, wallet, signatureStr string) ([]core.Trade, error) {
+	if global.Store == nil {
+		return nil, nil
+	}
+	signature, err := solana.SignatureFromBase58(signatureStr)
+	if err != nil {
+		return nil, err
+	}
+	// GetTransactionLogs 已获取过该交易，这里通常命中缓存
+	tx, err := s.fetchTransaction(ctx, global.RpcClient, signature)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := ParseTrades(tx, wallet)
+	if err != nil {
+		return nil, err
+	}
+	// 记录交易时的 SOL 价格，历史盈亏按交易时价格折算 USD
+	price := currentSOLPrice(ctx, s.logger)
+	for i := range trades {
+		trades[i].SOLPriceUSD = price
+	}
+	if err := global.Store.SaveTrades(ctx, trades); err != nil {
+		return nil, err
+	}
+	s.logger.Debug("已保存交易", "legs", len(trades))
+	return trades, nil
+}
+
+var TradesCmd = &cobra.Command{
+	Use:   "trades [address|label]",
+	Short: "List recorded trades from the trade store",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := core.TradeFilter{}
+		if len(args) > 0 {
+			filter.Wallet = resolveWallet(args)
+		}
+		filter.Mint, _ = cmd.Flags().GetString("mint")
+		filter.Limit, _ = cmd.Flags().GetInt("limit")
+		if since, _ := cmd.Flags().GetDuration("since"); since > 0 {
+			filter.Since = time.Now().Add(-since)
+		}
+
+		store, err := OpenTradeStore(cmd.Context())
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		trades, err := store.Trades(cmd.Context(), filter)
+		if err != nil {
+			return fmt.Errorf("查询交易失败: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tWALLET\tDIRECTION\tMINT\tAMOUNT\tQUOTE\tPRICE\tDEX\tSIGNATURE")
+		for _, t := range trades {
+			blockTime := "-"
+			if !t.BlockTime.IsZero() {
+				blockTime = t.BlockTime.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.6g\t%.6g %s\t%.6g\t%s\t%s#%d\n", blockTime,
+				global.SystemConfig().Label(t.Wallet), t.Direction, t.Mint, t.TokenUI(), t.QuoteUI(), quoteSymbol(t.QuoteMint),
+				t.Price, t.DEX, t.Signature, t.Leg)
+		}
+		w.Flush()
+		fmt.Printf("共 %d 条交易\n", len(trades))
+		return nil
+	},
+}
+
+// OpenTradeStore 按配置打开交易存储，供读取交易的子命令使用
+func OpenTradeStore(ctx context.Context) (*core.TradeStore, error) {
+	if global.StoreConfig.Driver == "" {
+		return nil, fmt.Errorf("未配置交易存储 store.driver")
+	}
+	return core.OpenStore(ctx, global.StoreConfig, nil)
+}
+
+// quoteSymbol 返回计价代币的符号
+func quoteSymbol(mint string) string {
+	switch mint {
+	case solana.SolMint.String():
+		return "SOL"
+	case USDCMint:
+		return "USDC"
+	case USDTMint:
+		return "USDT"
+	}
+	return mint
+}
+
+func init() {
+	TradesCmd.Flags().String("mint", "", "只显示该代币的交易")
+	TradesCmd.Flags().Duration("since", 0, "只显示最近一段时间内的交易，例如 24h")
+	TradesCmd.Flags().Int("limit", 50, "最多显示的交易数，0 表示不限制")
+}
@@ -3,12 +3,13 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gagliardetto/solana-go"
-	"log"
+	"log/slog"
+	"meme/core"
 	"meme/global"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gagliardetto/solana-go/rpc"
@@ -17,10 +18,16 @@ import (
 const (
 	SPLTokenProgramID = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
 	SystemProgramID   = "11111111111111111111111111111111"
+
+	FetchTransactionTimeout = time.Minute
 )
 
+// ErrNotTrade 表示交易没有代币余额变化，不是买卖交易
+var ErrNotTrade = errors.New("非交易记录过滤")
+
 type TransactionRep struct {
 	Address string
+	Label   string
 	Amount  string
 	Mint    string
 	Type    string
@@ -28,37 +35,39 @@ type TransactionRep struct {
 
 // TransactionService 表示交易服务
 type TransactionService struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 // NewTransactionService 创建一个新的交易服务实例
-func NewTransactionService(logger *log.Logger) *TransactionService {
+func NewTransactionService(logger *slog.Logger) *TransactionService {
 	return &TransactionService{
 		logger: logger,
 	}
 }
 
-func (s *TransactionService) GetTransactionLogs(address, signatureStr string) (TransactionRep, error) {
+func (s *TransactionService) GetTransactionLogs(ctx context.Context, address, signatureStr string) (TransactionRep, error) {
 	client := global.RpcClient
 	var transactionRep, _preTransactionRep, _postTransactionRep TransactionRep
 	signature, err := solana.SignatureFromBase58(signatureStr)
 	if err != nil {
-		s.logger.Printf("解析签名交易签名失败: %v", err)
+		s.logger.Warn("解析交易签名失败", core.LogKeyError, err)
 		return transactionRep, err
 	}
 
-	txDetails, err := s.fetchTransaction(client, signature)
+	txDetails, err := s.fetchTransaction(ctx, client, signature)
 	if err != nil {
 		return transactionRep, err
 	}
 	if len(txDetails.Meta.PreTokenBalances) == 0 || len(txDetails.Meta.PostTokenBalances) == 0 {
-		return transactionRep, fmt.Errorf("非交易记录过滤")
+		return transactionRep, ErrNotTrade
 	}
 
+	label := global.SystemConfig().Label(address)
 	for _, preTokenBalance := range txDetails.Meta.PreTokenBalances {
 		if preTokenBalance.Owner.String() == address && preTokenBalance.ProgramId.String() == SPLTokenProgramID {
 			_preTransactionRep = TransactionRep{
 				Address: address,
+				Label:   label,
 				Amount:  preTokenBalance.UiTokenAmount.UiAmountString,
 				Mint:    preTokenBalance.Mint.String(),
 			}
@@ -70,17 +79,18 @@ func (s *TransactionService) GetTransactionLogs(address, signatureStr string) (T
 		if postTokenBalance.Owner.String() == address && postTokenBalance.ProgramId.String() == SPLTokenProgramID {
 			_postTransactionRep = TransactionRep{
 				Address: address,
+				Label:   label,
 				Amount:  postTokenBalance.UiTokenAmount.UiAmountString,
 				Mint:    postTokenBalance.Mint.String(),
 			}
 			break
 		}
 	}
-	s.logger.Printf("交易前数量: %v ========= 交易后数量: %v\n", _preTransactionRep.Amount, _postTransactionRep.Amount)
+	s.logger.Debug("代币余额变化", "pre_amount", _preTransactionRep.Amount, "post_amount", _postTransactionRep.Amount)
 	if _preTransactionRep.Address == "" && _postTransactionRep.Address != "" {
 		transactionRep = _postTransactionRep
 		transactionRep.Type = "buy"
-		s.logger.Printf("%s 买入数量: %s, mint: %s\n", address, transactionRep.Amount, transactionRep.Mint)
+		s.logger.Info("买入", "amount", transactionRep.Amount, core.LogKeyMint, transactionRep.Mint)
 	}
 	if _preTransactionRep.Address != "" {
 		transactionRep = _preTransactionRep
@@ -88,12 +98,12 @@ func (s *TransactionService) GetTransactionLogs(address, signatureStr string) (T
 		if _postTransactionRep.Address != "" {
 			preAmount, err := strconv.ParseFloat(_preTransactionRep.Amount, 64)
 			if err != nil {
-				s.logger.Printf("转化交易前数量失败 %v", err)
+				s.logger.Warn("转化交易前数量失败", core.LogKeyError, err)
 				return transactionRep, nil
 			}
 			postAmount, err := strconv.ParseFloat(_postTransactionRep.Amount, 64)
 			if err != nil {
-				s.logger.Printf("转化交易后数量失败 %v", err)
+				s.logger.Warn("转化交易后数量失败", core.LogKeyError, err)
 				return transactionRep, nil
 			}
 			if preAmount > postAmount {
@@ -101,67 +111,78 @@ func (s *TransactionService) GetTransactionLogs(address, signatureStr string) (T
 			} else {
 				transactionRep.Amount = fmt.Sprintf("%.2f", postAmount-preAmount)
 				transactionRep.Type = "buy"
-				s.logger.Printf("%s 买入数量: %s, mint: %s\n", address, transactionRep.Amount, transactionRep.Mint)
+				s.logger.Info("买入", "amount", transactionRep.Amount, core.LogKeyMint, transactionRep.Mint)
 				return transactionRep, nil
 			}
 		}
-		s.logger.Printf("%s 卖出数量: %s, mint: %s\n", address, transactionRep.Amount, transactionRep.Mint)
+		s.logger.Info("卖出", "amount", transactionRep.Amount, core.LogKeyMint, transactionRep.Mint)
 	}
 	return transactionRep, nil
 
 }
 
 // fetchTransaction fetches the transaction details from the Solana blockchain.
-func (s *TransactionService) fetchTransaction(client *rpc.Client, signature solana.Signature) (*rpc.GetTransactionResult, error) {
-	ctx := context.Background()
-	s.logger.Printf("开始获取交易详情...")
-
-	var tx *rpc.GetTransactionResult
-	var err error
-	maxRetries := 15             // 最大重试次数
-	baseDelay := time.Second * 2 // 初始延迟
-	maxDelay := 30 * time.Second
-
-	for i := 0; i < maxRetries; i++ {
-		maxSupportedVersion := uint64(0)
-		tx, err = client.GetTransaction(ctx, signature, &rpc.GetTransactionOpts{
-			Commitment:                     "confirmed",
-			MaxSupportedTransactionVersion: &maxSupportedVersion,
-		})
-
-		if err == nil {
-			// 请求成功，退出循环
-			txLogsJson, err := json.Marshal(tx)
-			if err != nil {
-				s.logger.Printf("交易raw日志: %v", tx)
-				s.logger.Printf("交易日志JSON序列化失败: %v", err)
-			} else {
-				s.logger.Printf("交易JSON日志: %s", txLogsJson)
-			}
-			break
+// 交易未找到时指数退避重试，被限流时按节点返回的 Retry-After 等待，总耗时不超过 FetchTransactionTimeout
+func (s *TransactionService) fetchTransaction(ctx context.Context, client *rpc.Client, signature solana.Signature) (tx *rpc.GetTransactionResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, FetchTransactionTimeout)
+	defer cancel()
+	s.logger.Debug("开始获取交易详情")
+
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
 		}
-
-		if strings.Contains(err.Error(), "not found") {
-			s.logger.Printf("交易未找到，重试中...")
-		} else if strings.Contains(err.Error(), "Too many requests") {
-			s.logger.Printf("请求速率限制。%d 秒后重试...", 1<<i) // 2^i 秒
-		} else {
-			s.logger.Printf("获取交易详情时发生错误: %v", err)
+		core.TransactionFetchDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	}()
+
+	delay := time.Second // 交易未找到时的初始延迟
+	maxDelay := 8 * time.Second
+
+	for {
+		tx, err = GetTransactionCached(ctx, client, signature, rpc.CommitmentConfirmed)
+
+		var limited *core.RateLimitError
+		var wait time.Duration
+		switch {
+		case err == nil:
+			if s.logger.Enabled(ctx, slog.LevelDebug) {
+				if txLogsJson, err := json.Marshal(tx); err != nil {
+					s.logger.Debug("交易日志JSON序列化失败", core.LogKeyError, err)
+				} else {
+					s.logger.Debug("交易JSON日志", "transaction", json.RawMessage(txLogsJson))
+				}
+			}
+		case errors.Is(err, rpc.ErrNotFound):
+			wait = delay
+			delay = min(delay*2, maxDelay)
+			s.logger.Debug("交易未找到，稍后重试", "wait", wait)
+		case errors.As(err, &limited):
+			wait = limited.RetryAfter
+			s.logger.Warn("请求速率限制，稍后重试", "wait", wait)
+		default:
+			s.logger.Error("获取交易详情时发生错误", core.LogKeyError, err)
 			return nil, fmt.Errorf("failed to fetch transaction: %w", err)
 		}
+		if err == nil {
+			break
+		}
 
-		// 计算动态延迟
-		delay := baseDelay * time.Duration(1<<i)
-		if delay > maxDelay {
-			delay = maxDelay
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			s.logger.Warn("获取交易详情超时", core.LogKeyError, err)
+			return nil, fmt.Errorf("failed to fetch transaction within %v: %w", FetchTransactionTimeout, err)
 		}
-		time.Sleep(delay)
 	}
 
 	// 检查最终结果是否有效
 	if tx == nil || tx.Meta == nil || tx.Meta.LogMessages == nil {
-		s.logger.Printf("重试后交易中未找到日志")
-		return nil, fmt.Errorf("no logs found in the transaction after retries")
+		s.logger.Warn("交易中未找到日志")
+		return nil, fmt.Errorf("no logs found in the transaction")
 	}
 
 	return tx, nil
@@ -1,68 +1,114 @@
 package utils
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
 )
 
-type RPCRequest struct {
-	JSONRPC string        `json:"jsonrpc"`
-	Method  string        `json:"method"`
-	Params  []interface{} `json:"params"`
-	ID      int           `json:"id"`
+// DefaultBatchSize 单个批量请求包含的最大请求数，多数节点限制为 100 左右
+const DefaultBatchSize = 100
+
+// ErrMissingResponse 表示批量响应中缺少某个请求 id 对应的结果
+var ErrMissingResponse = errors.New("batch response missing")
+
+// BatchCaller 发送批量 JSON-RPC 请求，*rpc.Client 实现了该接口
+type BatchCaller interface {
+	RPCCallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error)
 }
 
-func batchRequest(url string, requests []RPCRequest) ([]map[string]interface{}, error) {
-	jsonData, err := json.Marshal(requests)
-	if err != nil {
-		return nil, err
-	}
+// BatchRequest 表示批量请求中的一个请求
+type BatchRequest struct {
+	Method string
+	Params []interface{}
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
+// BatchResult 表示单个请求的结果，Err 为该请求自身的 RPC 错误，不影响其它请求
+type BatchResult struct {
+	Request BatchRequest
+	Result  json.RawMessage
+	Err     error
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// Decode 把结果解析到 out，请求失败时返回请求自身的错误
+func (r BatchResult) Decode(out interface{}) error {
+	if r.Err != nil {
+		return r.Err
 	}
-	defer resp.Body.Close()
+	if len(r.Result) == 0 || string(r.Result) == "null" {
+		return fmt.Errorf("%s: 结果为空", r.Request.Method)
+	}
+	return json.Unmarshal(r.Result, out)
+}
 
-	var response []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
+// BatchClient 把请求按 Size 分块发送，并按 id 把响应对应回请求
+type BatchClient struct {
+	caller BatchCaller
+	Size   int
+}
+
+// NewBatchClient 创建批量请求客户端
+func NewBatchClient(caller BatchCaller) *BatchClient {
+	return &BatchClient{
+		caller: caller,
+		Size:   DefaultBatchSize,
 	}
+}
 
-	return response, nil
+// Call 发送全部请求，结果与 requests 顺序一致。
+// 只有整块请求失败（网络错误、限流等）时返回 error，单个请求的 RPC 错误放在 BatchResult.Err 中
+func (c *BatchClient) Call(ctx context.Context, requests []BatchRequest) ([]BatchResult, error) {
+	size := c.Size
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	results := make([]BatchResult, len(requests))
+	for start := 0; start < len(requests); start += size {
+		end := min(start+size, len(requests))
+		if err := c.callChunk(ctx, requests[start:end], results[start:end], start); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
 }
 
-func batchTokenSupply() {
-	url := "https://api.mainnet-beta.solana.com" // Solana RPC URL
-	requests := []RPCRequest{
-		{
-			JSONRPC: "2.0",
-			Method:  "getTokenSupply",
-			Params:  []interface{}{"<mint_address_1>", map[string]string{"commitment": "confirmed"}},
-			ID:      1,
-		},
-		{
+// callChunk 发送一块请求，请求 id 为其在块内的下标
+func (c *BatchClient) callChunk(ctx context.Context, requests []BatchRequest, results []BatchResult, offset int) error {
+	batch := make(jsonrpc.RPCRequests, 0, len(requests))
+	for i, request := range requests {
+		batch = append(batch, &jsonrpc.RPCRequest{
 			JSONRPC: "2.0",
-			Method:  "getTokenSupply",
-			Params:  []interface{}{"<mint_address_2>", map[string]string{"commitment": "confirmed"}},
-			ID:      2,
-		},
+			Method:  request.Method,
+			Params:  request.Params,
+			ID:      i,
+		})
 	}
 
-	response, err := batchRequest(url, requests)
+	responses, err := c.caller.RPCCallBatch(ctx, batch)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return fmt.Errorf("批量请求 [%d, %d) 失败: %w", offset, offset+len(requests), err)
 	}
 
-	fmt.Println("Batch Request Response:", response)
+	byID := make(map[string]*jsonrpc.RPCResponse, len(responses))
+	for _, response := range responses {
+		if response != nil {
+			byID[fmt.Sprint(response.ID)] = response
+		}
+	}
+	for i, request := range requests {
+		results[i].Request = request
+		response, ok := byID[fmt.Sprint(i)]
+		switch {
+		case !ok:
+			results[i].Err = fmt.Errorf("%s: %w", request.Method, ErrMissingResponse)
+		case response.Error != nil:
+			results[i].Err = response.Error
+		default:
+			results[i].Result = response.Result
+		}
+	}
+	return nil
 }
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+type stubRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []interface{}   `json:"params"`
+}
+
+type stubResponse struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Result  interface{}       `json:"result,omitempty"`
+	Error   *jsonrpc.RPCError `json:"error,omitempty"`
+}
+
+// batchStub 是批量 JSON-RPC 节点的桩，倒序返回响应，按 respond 生成每个请求的响应
+type batchStub struct {
+	mu      sync.Mutex
+	chunks  []int // 每个批量请求包含的请求数
+	limited int   // 剩余需要返回 429 的批量请求数
+	respond func(req stubRequest) stubResponse
+}
+
+func (s *batchStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var requests []stubRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.chunks = append(s.chunks, len(requests))
+	limited := s.limited > 0
+	if limited {
+		s.limited--
+	}
+	s.mu.Unlock()
+	if limited {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, `{"jsonrpc":"2.0","error":{"code":429,"message":"Too many requests"},"id":null}`, http.StatusTooManyRequests)
+		return
+	}
+
+	responses := make([]stubResponse, 0, len(requests))
+	for i := len(requests) - 1; i >= 0; i-- {
+		response := s.respond(requests[i])
+		response.Jsonrpc, response.ID = "2.0", requests[i].ID
+		responses = append(responses, response)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// echoParam 把第一个参数作为结果返回
+func echoParam(req stubRequest) stubResponse {
+	return stubResponse{Result: req.Params[0]}
+}
+
+func newBatchStub(t *testing.T, stub *batchStub) *BatchClient {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return NewBatchClient(rpc.New(server.URL))
+}
+
+func echoRequests(n int) []BatchRequest {
+	requests := make([]BatchRequest, n)
+	for i := range requests {
+		requests[i] = BatchRequest{Method: "echo", Params: []interface{}{i}}
+	}
+	return requests
+}
+
+func TestBatchClientChunksAndOrder(t *testing.T) {
+	stub := &batchStub{respond: echoParam}
+	client := newBatchStub(t, stub)
+	client.Size = 3
+
+	results, err := client.Call(context.Background(), echoRequests(7))
+	if err != nil {
+		t.Fatalf("Call 失败: %v", err)
+	}
+	if got := stub.chunks; len(got) != 3 || got[0] != 3 || got[1] != 3 || got[2] != 1 {
+		t.Errorf("分块为 %v，期望 [3 3 1]", got)
+	}
+	if len(results) != 7 {
+		t.Fatalf("结果数为 %d，期望 7", len(results))
+	}
+	// 节点倒序返回，结果仍需与请求顺序一致
+	for i, result := range results {
+		var value int
+		if err := result.Decode(&value); err != nil {
+			t.Fatalf("第 %d 个结果解析失败: %v", i, err)
+		}
+		if value != i {
+			t.Errorf("第 %d 个结果为 %d", i, value)
+		}
+		if result.Request.Params[0] != i {
+			t.Errorf("第 %d 个结果对应的请求为 %v", i, result.Request.Params)
+		}
+	}
+}
+
+func TestBatchClientPerItemErrors(t *testing.T) {
+	stub := &batchStub{respond: func(req stubRequest) stubResponse {
+		switch req.Params[0].(float64) {
+		case 1:
+			return stubResponse{Error: &jsonrpc.RPCError{Code: -32602, Message: "Invalid param"}}
+		case 2:
+			return stubResponse{Result: json.RawMessage("null")}
+		}
+		return echoParam(req)
+	}}
+	client := newBatchStub(t, stub)
+
+	results, err := client.Call(context.Background(), echoRequests(4))
+	if err != nil {
+		t.Fatalf("单个请求失败不应导致整批失败: %v", err)
+	}
+	var rpcErr *jsonrpc.RPCError
+	if !errors.As(results[1].Err, &rpcErr) || rpcErr.Code != -32602 {
+		t.Errorf("第 1 个请求的错误为 %v，期望 RPC 错误 -32602", results[1].Err)
+	}
+	var value int
+	if err := results[1].Decode(&value); err == nil {
+		t.Error("失败的请求 Decode 应返回错误")
+	}
+	if err := results[2].Decode(&value); err == nil || !strings.Contains(err.Error(), "结果为空") {
+		t.Errorf("结果为 null 时 Decode 错误为 %v", err)
+	}
+	for _, i := range []int{0, 3} {
+		if err := results[i].Decode(&value); err != nil || value != i {
+			t.Errorf("第 %d 个请求结果为 %d, %v", i, value, err)
+		}
+	}
+}
+
+func TestBatchClientMissingResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 只返回 id 为 0 的响应
+		w.Write([]byte(`[{"jsonrpc":"2.0","id":0,"result":"ok"}]`))
+	}))
+	t.Cleanup(server.Close)
+
+	results, err := NewBatchClient(rpc.New(server.URL)).Call(context.Background(), echoRequests(2))
+	if err != nil {
+		t.Fatalf("Call 失败: %v", err)
+	}
+	if results[0].Err != nil {
+		t.Errorf("第 0 个请求错误: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrMissingResponse) {
+		t.Errorf("缺少响应时错误为 %v，期望 ErrMissingResponse", results[1].Err)
+	}
+}
+
+func TestBatchClientRateLimited(t *testing.T) {
+	stub := &batchStub{respond: echoParam, limited: 1}
+	client := newBatchStub(t, stub)
+	client.Size = 2
+
+	// 第一块被限流时整批返回错误，不返回部分结果
+	results, err := client.Call(context.Background(), echoRequests(3))
+	if err == nil {
+		t.Fatal("限流时应返回错误")
+	}
+	if results != nil {
+		t.Errorf("限流时不应返回结果: %v", results)
+	}
+	var httpErr *jsonrpc.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusTooManyRequests {
+		t.Errorf("错误为 %v，期望 HTTP 429", err)
+	}
+	if !strings.Contains(err.Error(), "[0, 2)") {
+		t.Errorf("错误中应包含失败的请求范围: %v", err)
+	}
+	if len(stub.chunks) != 1 {
+		t.Errorf("限流后不应继续发送后续分块，实际发送 %d 块", len(stub.chunks))
+	}
+
+	// 限流解除后重试成功
+	results, err = client.Call(context.Background(), echoRequests(3))
+	if err != nil || len(results) != 3 {
+		t.Fatalf("重试失败: %v", err)
+	}
+}